@@ -0,0 +1,87 @@
+package agentclient
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+
+	"github.com/Sanskarzz/k8sgptclient/k8s-agent/api"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
+)
+
+// ActionOptions configures a restart, scale, pod deletion or patch
+type ActionOptions struct {
+	// DryRun runs the action with a server-side dry-run
+	DryRun bool
+}
+
+func (o ActionOptions) query() url.Values {
+	query := url.Values{}
+	if o.DryRun {
+		query.Set("dryRun", "true")
+	}
+	return query
+}
+
+// RestartDeployment rolls the pods of a deployment like kubectl rollout restart
+func (c *Client) RestartDeployment(ctx context.Context, namespace, name string, opts ActionOptions) (*api.ApplyResponse, error) {
+	data, err := c.do(ctx, http.MethodPost, path("deployments", namespace, name, "restart"), opts.query(), nil, "")
+	return decodeActionResponse(data, err)
+}
+
+// ScaleDeployment sets the replicas of a deployment
+func (c *Client) ScaleDeployment(ctx context.Context, namespace, name string, replicas int32, opts ActionOptions) (*api.ApplyResponse, error) {
+	body, err := json.Marshal(api.ScaleRequest{Replicas: &replicas})
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode request: %w", err)
+	}
+	data, err := c.do(ctx, http.MethodPost, path("deployments", namespace, name, "scale"), opts.query(), bytes.NewReader(body), "application/json")
+	return decodeActionResponse(data, err)
+}
+
+// DeletePod deletes a pod through the eviction API. An eviction blocked by a PodDisruptionBudget
+// returns a StatusError with a 429 status code.
+func (c *Client) DeletePod(ctx context.Context, namespace, name string, opts ActionOptions) (*api.ApplyResponse, error) {
+	data, err := c.do(ctx, http.MethodDelete, path("pods", namespace, name), opts.query(), nil, "")
+	return decodeActionResponse(data, err)
+}
+
+// PatchResource patches any object, namespace is empty for cluster scoped resources. patchType is one of
+// types.JSONPatchType, types.MergePatchType or types.StrategicMergePatchType. A patch that does not match
+// the live object, e.g. a failing JSON patch test operation, returns a StatusError.
+func (c *Client) PatchResource(ctx context.Context, gvr schema.GroupVersionResource, namespace, name string, patchType types.PatchType, patch []byte, opts ActionOptions) (*api.ApplyResponse, error) {
+	data, err := c.do(ctx, http.MethodPatch, resourcePath(gvr, namespace, name), opts.query(), bytes.NewReader(patch), string(patchType))
+	return decodeActionResponse(data, err)
+}
+
+// decodeActionResponse decodes the response of an action, which the agent also returns
+// with error status codes when the action was denied or failed
+func decodeActionResponse(data []byte, err error) (*api.ApplyResponse, error) {
+	var statusErr *StatusError
+	if err != nil && !errors.As(err, &statusErr) {
+		return nil, err
+	}
+	// error bodies decode to a response without action
+	var response api.ApplyResponse
+	if decodeErr := json.Unmarshal(data, &response); decodeErr != nil {
+		if err != nil {
+			return nil, err
+		}
+		return nil, fmt.Errorf("failed to decode response: %w", decodeErr)
+	}
+	if response.Action == "" {
+		if err != nil {
+			return nil, err
+		}
+		return nil, errors.New("failed to decode response: missing action")
+	}
+	if statusErr != nil {
+		statusErr.Message = summarizeFailures([]api.ApplyResponse{response})
+	}
+	return &response, err
+}
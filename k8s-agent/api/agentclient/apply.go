@@ -0,0 +1,177 @@
+package agentclient
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/Sanskarzz/k8sgptclient/k8s-agent/api"
+)
+
+// ApplyOptions configures an apply
+type ApplyOptions struct {
+	// DryRun previews the apply with a server-side dry-run, responses carry the field diff
+	DryRun bool
+	// Atomic applies all objects or none of them
+	Atomic bool
+	// Force takes ownership of fields managed by others when true, and fails with the conflicting fields
+	// when false. nil uses the agent default, set by its --force-apply flag.
+	Force *bool
+}
+
+// Apply applies a multi-document YAML manifest and returns one response per object, in apply order.
+// The agent returns an array of responses even for a single document, it used to return a single response
+// object before multi-document applies. When some objects are denied or fail, the per object
+// responses are returned along with a StatusError, their Reason and Causes tell why each object failed.
+// A non-forced apply of fields owned by other managers fails with a 409 StatusError, the responses
+// with the conflict action list the conflicting fields.
+func (c *Client) Apply(ctx context.Context, manifest []byte, opts ApplyOptions) ([]api.ApplyResponse, error) {
+	query := url.Values{}
+	if opts.DryRun {
+		query.Set("dryRun", "true")
+	}
+	if opts.Atomic {
+		query.Set("atomic", "true")
+	}
+	if opts.Force != nil {
+		query.Set("force", strconv.FormatBool(*opts.Force))
+	}
+	data, err := c.do(ctx, http.MethodPost, "/apply", query, bytes.NewReader(manifest), "application/yaml")
+	return decodeApplyResponses(data, err)
+}
+
+// Rollback restores the objects of a revision to their state before the apply that created it
+func (c *Client) Rollback(ctx context.Context, revisionID string) ([]api.ApplyResponse, error) {
+	data, err := c.do(ctx, http.MethodPost, path("rollback", revisionID), nil, nil, "")
+	return decodeApplyResponses(data, err)
+}
+
+// Feasibility reports whether the pods of the objects of a multi-document YAML manifest with a pod template
+// can be scheduled, without applying them. Remediations can use MaxRequests to keep their requests schedulable.
+func (c *Client) Feasibility(ctx context.Context, manifest []byte) ([]api.FeasibilityReport, error) {
+	data, err := c.do(ctx, http.MethodPost, "/feasibility", nil, bytes.NewReader(manifest), "application/yaml")
+	if err != nil {
+		return nil, err
+	}
+	var reports []api.FeasibilityReport
+	if err := json.Unmarshal(data, &reports); err != nil {
+		return nil, fmt.Errorf("failed to decode response: %w", err)
+	}
+	return reports, nil
+}
+
+// VerifyImages reports whether the container images of the objects of a multi-document YAML manifest with a pod
+// template exist in their registries, with the closest tags of the tags that don't
+func (c *Client) VerifyImages(ctx context.Context, manifest []byte) ([]api.ImageReport, error) {
+	data, err := c.do(ctx, http.MethodPost, "/images/verify", nil, bytes.NewReader(manifest), "application/yaml")
+	if err != nil {
+		return nil, err
+	}
+	var reports []api.ImageReport
+	if err := json.Unmarshal(data, &reports); err != nil {
+		return nil, fmt.Errorf("failed to decode response: %w", err)
+	}
+	return reports, nil
+}
+
+// decodeApplyResponses decodes the per object responses of apply and rollback, which
+// the agent also returns with error status codes
+func decodeApplyResponses(data []byte, err error) ([]api.ApplyResponse, error) {
+	var statusErr *StatusError
+	if err != nil && !errors.As(err, &statusErr) {
+		return nil, err
+	}
+	var responses []api.ApplyResponse
+	if decodeErr := json.Unmarshal(data, &responses); decodeErr != nil {
+		if err != nil {
+			return nil, err
+		}
+		return nil, fmt.Errorf("failed to decode response: %w", decodeErr)
+	}
+	if statusErr != nil {
+		// the body holds per object responses, not an error, summarize them
+		statusErr.Message = summarizeFailures(responses)
+	}
+	return responses, err
+}
+
+// summarizeFailures joins the errors of the failed and denied objects
+func summarizeFailures(responses []api.ApplyResponse) string {
+	var failures []string
+	for _, response := range responses {
+		switch {
+		case len(response.Conflicts) > 0:
+			for _, conflict := range response.Conflicts {
+				failures = append(failures, fmt.Sprintf("%s %s/%s conflicts: %s is managed by %s",
+					response.Kind, response.Namespace, response.Name, conflict.Field, conflict.Manager))
+			}
+		case response.Error != "":
+			failures = append(failures, fmt.Sprintf("%s %s/%s: %s", response.Kind, response.Namespace, response.Name, response.Error))
+		case len(response.Violations) > 0:
+			for _, violation := range response.Violations {
+				failures = append(failures, fmt.Sprintf("%s %s/%s denied: %s", response.Kind, response.Namespace, response.Name, violation.Message))
+			}
+		}
+	}
+	return strings.Join(failures, "; ")
+}
+
+// Conflicts returns the fields owned by other managers that made the objects of a non-forced apply fail
+func Conflicts(responses []api.ApplyResponse) []api.FieldConflict {
+	var conflicts []api.FieldConflict
+	for _, response := range responses {
+		conflicts = append(conflicts, response.Conflicts...)
+	}
+	return conflicts
+}
+
+// AuditLogOptions selects the audit records to return, zero values match everything
+type AuditLogOptions struct {
+	Since     time.Time
+	Until     time.Time
+	Kind      string
+	Namespace string
+	Name      string
+	User      string
+	Operation string
+	// Limit keeps only the most recent records, 0 returns every match
+	Limit int
+}
+
+// AuditLog returns the audit records matching the options in chronological order
+func (c *Client) AuditLog(ctx context.Context, filter AuditLogOptions) ([]api.AuditRecord, error) {
+	query := url.Values{}
+	for name, value := range map[string]string{
+		"kind":      filter.Kind,
+		"namespace": filter.Namespace,
+		"name":      filter.Name,
+		"user":      filter.User,
+		"operation": filter.Operation,
+	} {
+		if value != "" {
+			query.Set(name, value)
+		}
+	}
+	if !filter.Since.IsZero() {
+		query.Set("since", filter.Since.Format(time.RFC3339))
+	}
+	if !filter.Until.IsZero() {
+		query.Set("until", filter.Until.Format(time.RFC3339))
+	}
+	if filter.Limit > 0 {
+		query.Set("limit", strconv.Itoa(filter.Limit))
+	}
+
+	var records []api.AuditRecord
+	if err := c.getJSON(ctx, "/audit", query, &records); err != nil {
+		return nil, err
+	}
+	return records, nil
+}
@@ -0,0 +1,237 @@
+// Package agentclient is a typed Go client for the k8s agent API.
+package agentclient
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/Sanskarzz/k8sgptclient/k8s-agent/api"
+)
+
+// DefaultTimeout bounds requests when Options.Timeout is not set, streams are never bounded
+const DefaultTimeout = 30 * time.Second
+
+// Options configures a Client
+type Options struct {
+	// URL is the base URL of the agent, e.g. https://k8s-agent.k8sgptclient.svc:8080
+	URL string
+	// HTTPClient sends the requests, nil uses http.DefaultClient. Its own Timeout is ignored for streams.
+	HTTPClient *http.Client
+	// TokenFile holds the bearer token sent to the agent, usually a projected service account token.
+	// It is read on every request because the kubelet rotates it. Empty sends no token.
+	TokenFile string
+	// Timeout bounds every request but streams, 0 uses DefaultTimeout and a negative value disables it
+	Timeout time.Duration
+}
+
+// Client calls the k8s agent API
+type Client struct {
+	baseURL    *url.URL
+	httpClient *http.Client
+	// streamClient is httpClient without timeout, streams live until their context is cancelled
+	streamClient *http.Client
+	tokenFile    string
+	timeout      time.Duration
+}
+
+// New creates a new Client
+func New(opts Options) (*Client, error) {
+	baseURL, err := url.Parse(strings.TrimSuffix(opts.URL, "/"))
+	if err != nil {
+		return nil, fmt.Errorf("invalid agent URL: %w", err)
+	}
+	if baseURL.Scheme != "http" && baseURL.Scheme != "https" {
+		return nil, fmt.Errorf("invalid agent URL %q, expected an http or https URL", opts.URL)
+	}
+
+	httpClient := opts.HTTPClient
+	if httpClient == nil {
+		httpClient = http.DefaultClient
+	}
+	streamClient := *httpClient
+	streamClient.Timeout = 0
+
+	timeout := opts.Timeout
+	if timeout == 0 {
+		timeout = DefaultTimeout
+	}
+
+	return &Client{
+		baseURL:      baseURL,
+		httpClient:   httpClient,
+		streamClient: &streamClient,
+		tokenFile:    opts.TokenFile,
+		timeout:      timeout,
+	}, nil
+}
+
+// StatusError is returned when the agent answers with an unexpected status code
+type StatusError struct {
+	StatusCode int
+	// Reason is the machine readable reason of the error, e.g. NotFound
+	Reason string
+	// Message is the error message returned by the agent
+	Message string
+	// Details describes the object the error is about, with the invalid fields of invalid objects
+	Details *api.ErrorDetails
+	// RequestID identifies the failed request in the agent logs and audit log
+	RequestID string
+}
+
+func (e *StatusError) Error() string {
+	if e.Message == "" {
+		return fmt.Sprintf("agent returned status %d", e.StatusCode)
+	}
+	return fmt.Sprintf("agent returned status %d: %s", e.StatusCode, e.Message)
+}
+
+// newStatusError decodes the error response of the agent, falling back to the raw body
+func newStatusError(statusCode int, body []byte) *StatusError {
+	var apiErr api.Error
+	if err := json.Unmarshal(body, &apiErr); err == nil && apiErr.Message != "" {
+		return &StatusError{
+			StatusCode: statusCode,
+			Reason:     apiErr.Reason,
+			Message:    apiErr.Message,
+			Details:    apiErr.Details,
+			RequestID:  apiErr.RequestID,
+		}
+	}
+	return &StatusError{
+		StatusCode: statusCode,
+		Message:    strings.TrimSpace(string(body)),
+	}
+}
+
+// IsNotFound reports whether err is a StatusError with a 404 status code, e.g. a missing resource
+func IsNotFound(err error) bool {
+	return statusCode(err) == http.StatusNotFound
+}
+
+// IsForbidden reports whether err is a StatusError with a 403 status code
+func IsForbidden(err error) bool {
+	return statusCode(err) == http.StatusForbidden
+}
+
+// IsConflict reports whether err is a StatusError with a 409 status code
+func IsConflict(err error) bool {
+	return statusCode(err) == http.StatusConflict
+}
+
+// IsInvalid reports whether err is a StatusError with a 422 status code, Details lists the invalid fields
+func IsInvalid(err error) bool {
+	return statusCode(err) == http.StatusUnprocessableEntity
+}
+
+func statusCode(err error) int {
+	var statusErr *StatusError
+	if errors.As(err, &statusErr) {
+		return statusErr.StatusCode
+	}
+	return 0
+}
+
+// path joins escaped path segments
+func path(segments ...string) string {
+	escaped := make([]string, len(segments))
+	for i, segment := range segments {
+		escaped[i] = url.PathEscape(segment)
+	}
+	return "/" + strings.Join(escaped, "/")
+}
+
+// newRequest creates a request to the agent authenticated with the bearer token
+func (c *Client) newRequest(ctx context.Context, method, path string, query url.Values, body io.Reader) (*http.Request, error) {
+	u := *c.baseURL
+	u.Path += path
+	u.RawQuery = query.Encode()
+
+	req, err := http.NewRequestWithContext(ctx, method, u.String(), body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %w", err)
+	}
+
+	if c.tokenFile != "" {
+		token, err := os.ReadFile(c.tokenFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read agent token: %w", err)
+		}
+		req.Header.Set("Authorization", "Bearer "+strings.TrimSpace(string(token)))
+	}
+	return req, nil
+}
+
+// do sends a request bounded by the client timeout and returns the response body.
+// Non 2xx responses return a StatusError along with the body.
+func (c *Client) do(ctx context.Context, method, path string, query url.Values, body io.Reader, contentType string) ([]byte, error) {
+	if c.timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, c.timeout)
+		defer cancel()
+	}
+
+	req, err := c.newRequest(ctx, method, path, query, body)
+	if err != nil {
+		return nil, err
+	}
+	if contentType != "" {
+		req.Header.Set("Content-Type", contentType)
+	}
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to send request: %w", err)
+	}
+	defer resp.Body.Close()
+
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read response: %w", err)
+	}
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return data, newStatusError(resp.StatusCode, data)
+	}
+	return data, nil
+}
+
+// getJSON sends a GET request and decodes the JSON response into out
+func (c *Client) getJSON(ctx context.Context, path string, query url.Values, out interface{}) error {
+	data, err := c.do(ctx, http.MethodGet, path, query, nil, "")
+	if err != nil {
+		return err
+	}
+	if err := json.Unmarshal(data, out); err != nil {
+		return fmt.Errorf("failed to decode response: %w", err)
+	}
+	return nil
+}
+
+// stream sends a request without timeout and returns the response body, which the caller must close
+func (c *Client) stream(ctx context.Context, path string, query url.Values, accept string) (io.ReadCloser, error) {
+	req, err := c.newRequest(ctx, http.MethodGet, path, query, nil)
+	if err != nil {
+		return nil, err
+	}
+	if accept != "" {
+		req.Header.Set("Accept", accept)
+	}
+
+	resp, err := c.streamClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to send request: %w", err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		defer resp.Body.Close()
+		data, _ := io.ReadAll(io.LimitReader(resp.Body, 64*1024))
+		return nil, newStatusError(resp.StatusCode, data)
+	}
+	return resp.Body, nil
+}
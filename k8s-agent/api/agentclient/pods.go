@@ -0,0 +1,114 @@
+package agentclient
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+
+	"github.com/Sanskarzz/k8sgptclient/k8s-agent/api"
+	corev1 "k8s.io/api/core/v1"
+)
+
+// ListPodsOptions selects the pods to list
+type ListPodsOptions struct {
+	// Namespace defaults to the default namespace on the agent
+	Namespace     string
+	AllNamespaces bool
+	LabelSelector string
+	FieldSelector string
+	// Limit and Continue page through the pods, pass the continue token of the previous page
+	Limit    int64
+	Continue string
+}
+
+func (o ListPodsOptions) query() url.Values {
+	query := url.Values{}
+	for name, value := range map[string]string{
+		"namespace":     o.Namespace,
+		"labelSelector": o.LabelSelector,
+		"fieldSelector": o.FieldSelector,
+		"continue":      o.Continue,
+	} {
+		if value != "" {
+			query.Set(name, value)
+		}
+	}
+	if o.AllNamespaces {
+		query.Set("allNamespaces", "true")
+	}
+	if o.Limit > 0 {
+		query.Set("limit", strconv.FormatInt(o.Limit, 10))
+	}
+	return query
+}
+
+// ListPods lists pods
+func (c *Client) ListPods(ctx context.Context, opts ListPodsOptions) (*corev1.PodList, error) {
+	var pods corev1.PodList
+	if err := c.getJSON(ctx, "/pods", opts.query(), &pods); err != nil {
+		return nil, err
+	}
+	return &pods, nil
+}
+
+// ListPodSummaries lists pods in the compact view of kubectl get pods -o wide
+func (c *Client) ListPodSummaries(ctx context.Context, opts ListPodsOptions) (*api.PodSummaryList, error) {
+	query := opts.query()
+	query.Set("view", "summary")
+	var pods api.PodSummaryList
+	if err := c.getJSON(ctx, "/pods", query, &pods); err != nil {
+		return nil, err
+	}
+	return &pods, nil
+}
+
+// PodStatus returns the status of a pod, including its probe results
+func (c *Client) PodStatus(ctx context.Context, namespace, name string) (*api.PodStatus, error) {
+	var status api.PodStatus
+	if err := c.getJSON(ctx, path("pods", namespace, name, "status"), nil, &status); err != nil {
+		return nil, err
+	}
+	return &status, nil
+}
+
+// PodYAML returns the YAML of a pod
+func (c *Client) PodYAML(ctx context.Context, namespace, name string) ([]byte, error) {
+	return c.do(ctx, http.MethodGet, path("pods", namespace, name, "yaml"), nil, nil, "")
+}
+
+// PodLogs streams the logs of a pod, opts may be nil. The caller must close the stream.
+func (c *Client) PodLogs(ctx context.Context, namespace, name string, opts *corev1.PodLogOptions) (io.ReadCloser, error) {
+	return c.stream(ctx, path("pods", namespace, name, "logs"), logQuery(opts), "")
+}
+
+// logQuery encodes the log options supported by the agent
+func logQuery(opts *corev1.PodLogOptions) url.Values {
+	query := url.Values{}
+	if opts == nil {
+		return query
+	}
+	if opts.Container != "" {
+		query.Set("container", opts.Container)
+	}
+	for name, value := range map[string]bool{
+		"follow":     opts.Follow,
+		"previous":   opts.Previous,
+		"timestamps": opts.Timestamps,
+	} {
+		if value {
+			query.Set(name, "true")
+		}
+	}
+	for name, value := range map[string]*int64{
+		"tailLines":    opts.TailLines,
+		"sinceSeconds": opts.SinceSeconds,
+		"limitBytes":   opts.LimitBytes,
+	} {
+		if value != nil {
+			query.Set(name, strconv.FormatInt(*value, 10))
+		}
+	}
+	return query
+}
@@ -0,0 +1,54 @@
+package agentclient
+
+import (
+	"context"
+	"net/http"
+	"net/url"
+
+	"github.com/Sanskarzz/k8sgptclient/k8s-agent/api"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+// coreGroup is the group name the agent uses for the core API group in paths
+const coreGroup = "core"
+
+// resourcePath returns the /resources path of an object, namespace is empty for cluster scoped resources
+func resourcePath(gvr schema.GroupVersionResource, namespace, name string) string {
+	group := gvr.Group
+	if group == "" {
+		group = coreGroup
+	}
+	if namespace == "" {
+		return path("resources", group, gvr.Version, gvr.Resource, name)
+	}
+	return path("resources", group, gvr.Version, gvr.Resource, namespace, name)
+}
+
+// ResourceYAML returns the YAML of any object, without status and noisy metadata.
+// namespace is empty for cluster scoped resources.
+func (c *Client) ResourceYAML(ctx context.Context, gvr schema.GroupVersionResource, namespace, name string) ([]byte, error) {
+	return c.do(ctx, http.MethodGet, resourcePath(gvr, namespace, name), nil, nil, "")
+}
+
+// Resource returns any object, including its status. namespace is empty for cluster scoped resources.
+func (c *Client) Resource(ctx context.Context, gvr schema.GroupVersionResource, namespace, name string) (*unstructured.Unstructured, error) {
+	query := url.Values{
+		"format":  {"json"},
+		"include": {"status"},
+	}
+	obj := &unstructured.Unstructured{}
+	if err := c.getJSON(ctx, resourcePath(gvr, namespace, name), query, &obj.Object); err != nil {
+		return nil, err
+	}
+	return obj, nil
+}
+
+// Events returns the events of an object and of the objects it owns
+func (c *Client) Events(ctx context.Context, namespace, kind, name string) (*api.ObjectEvents, error) {
+	var events api.ObjectEvents
+	if err := c.getJSON(ctx, path("events", namespace, kind, name), nil, &events); err != nil {
+		return nil, err
+	}
+	return &events, nil
+}
@@ -0,0 +1,101 @@
+package agentclient
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/url"
+	"strings"
+
+	"github.com/Sanskarzz/k8sgptclient/k8s-agent/api"
+)
+
+// Watch event types
+const (
+	Added    = "ADDED"
+	Modified = "MODIFIED"
+	Deleted  = "DELETED"
+)
+
+// maxEventSize bounds the size of a single watch event
+const maxEventSize = 1024 * 1024
+
+// ErrStreamClosed is returned when the agent closed a watch stream before the handler was done
+var ErrStreamClosed = errors.New("watch stream closed")
+
+// PodEvent is a change of a watched pod
+type PodEvent struct {
+	Type   string        `json:"type"`
+	Object api.PodStatus `json:"object"`
+}
+
+// DeploymentEvent is a change of a watched deployment
+type DeploymentEvent struct {
+	Type   string                      `json:"type"`
+	Object api.DeploymentRolloutStatus `json:"object"`
+}
+
+// WorkloadEvent is a change of a watched statefulset, daemonset, job or cronjob
+type WorkloadEvent struct {
+	Type   string             `json:"type"`
+	Object api.WorkloadStatus `json:"object"`
+}
+
+// WatchPods streams the status of the pods of a namespace, all namespaces when empty, matching the label selector.
+// handle is called for every event until it reports it is done or fails, its error is returned as is.
+func (c *Client) WatchPods(ctx context.Context, namespace, labelSelector string, handle func(PodEvent) (bool, error)) error {
+	query := url.Values{}
+	if namespace != "" {
+		query.Set("namespace", namespace)
+	}
+	if labelSelector != "" {
+		query.Set("labelSelector", labelSelector)
+	}
+	return watch(ctx, c, "/watch/pods", query, handle)
+}
+
+// WatchDeployment streams the rollout status of a deployment, see WatchPods for handle
+func (c *Client) WatchDeployment(ctx context.Context, namespace, name string, handle func(DeploymentEvent) (bool, error)) error {
+	return watch(ctx, c, path("watch", "deployments", namespace, name), nil, handle)
+}
+
+// WatchWorkload streams the status of a statefulset, daemonset, job or cronjob, see WatchPods for handle.
+// resource is the plural resource name, e.g. statefulsets.
+func (c *Client) WatchWorkload(ctx context.Context, resource, namespace, name string, handle func(WorkloadEvent) (bool, error)) error {
+	return watch(ctx, c, path("watch", resource, namespace, name), nil, handle)
+}
+
+// watch reads a server-sent events stream and calls handle with every decoded event
+func watch[T any](ctx context.Context, c *Client, path string, query url.Values, handle func(T) (bool, error)) error {
+	stream, err := c.stream(ctx, path, query, "text/event-stream")
+	if err != nil {
+		return err
+	}
+	defer stream.Close()
+
+	scanner := bufio.NewScanner(stream)
+	scanner.Buffer(make([]byte, 0, 64*1024), maxEventSize)
+	for scanner.Scan() {
+		// Only data lines carry events, skip event names, keep-alive comments and separators
+		data, ok := strings.CutPrefix(scanner.Text(), "data:")
+		if !ok {
+			continue
+		}
+		var event T
+		if err := json.Unmarshal([]byte(strings.TrimSpace(data)), &event); err != nil {
+			return fmt.Errorf("failed to decode watch event: %w", err)
+		}
+		if done, err := handle(event); done || err != nil {
+			return err
+		}
+	}
+	if ctx.Err() != nil {
+		return ctx.Err()
+	}
+	if err := scanner.Err(); err != nil {
+		return fmt.Errorf("%w: %v", ErrStreamClosed, err)
+	}
+	return ErrStreamClosed
+}
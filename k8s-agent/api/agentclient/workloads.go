@@ -0,0 +1,49 @@
+package agentclient
+
+import (
+	"context"
+	"io"
+	"net/http"
+
+	"github.com/Sanskarzz/k8sgptclient/k8s-agent/api"
+	corev1 "k8s.io/api/core/v1"
+)
+
+// DeploymentPods returns the names of the pods of a deployment
+func (c *Client) DeploymentPods(ctx context.Context, namespace, name string) (*api.DeploymentPods, error) {
+	var pods api.DeploymentPods
+	if err := c.getJSON(ctx, path("deployments", namespace, name, "pods"), nil, &pods); err != nil {
+		return nil, err
+	}
+	return &pods, nil
+}
+
+// DeploymentStatus returns the rollout status of a deployment
+func (c *Client) DeploymentStatus(ctx context.Context, namespace, name string) (*api.DeploymentRolloutStatus, error) {
+	var status api.DeploymentRolloutStatus
+	if err := c.getJSON(ctx, path("deployments", namespace, name, "status"), nil, &status); err != nil {
+		return nil, err
+	}
+	return &status, nil
+}
+
+// DeploymentYAML returns the YAML of a deployment
+func (c *Client) DeploymentYAML(ctx context.Context, namespace, name string) ([]byte, error) {
+	return c.do(ctx, http.MethodGet, path("deployments", namespace, name, "yaml"), nil, nil, "")
+}
+
+// DeploymentLogs streams the merged logs of all pods of a deployment, each line prefixed with [pod/container].
+// opts may be nil. The caller must close the stream.
+func (c *Client) DeploymentLogs(ctx context.Context, namespace, name string, opts *corev1.PodLogOptions) (io.ReadCloser, error) {
+	return c.stream(ctx, path("deployments", namespace, name, "logs"), logQuery(opts), "")
+}
+
+// WorkloadStatus returns the status of a statefulset, daemonset, job or cronjob.
+// resource is the plural resource name, e.g. statefulsets.
+func (c *Client) WorkloadStatus(ctx context.Context, resource, namespace, name string) (*api.WorkloadStatus, error) {
+	var status api.WorkloadStatus
+	if err := c.getJSON(ctx, path(resource, namespace, name, "status"), nil, &status); err != nil {
+		return nil, err
+	}
+	return &status, nil
+}
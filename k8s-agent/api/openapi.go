@@ -0,0 +1,11 @@
+package api
+
+import (
+	_ "embed"
+)
+
+// OpenAPI is the OpenAPI 3 specification of the agent API, served at /openapi.json.
+// Keep it in sync with the handlers and the types of this package.
+//
+//go:embed openapi.json
+var OpenAPI []byte
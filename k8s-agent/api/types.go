@@ -0,0 +1,325 @@
+package api
+
+import (
+	"time"
+
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+)
+
+// ProbeStatus represents the status of a probe
+type ProbeStatus struct {
+	LastProbeTime      string `json:"lastProbeTime,omitempty"`
+	LastTransitionTime string `json:"lastTransitionTime,omitempty"`
+	Status             bool   `json:"status"`
+	Failure            string `json:"failure,omitempty"`
+	SuccessCount       int32  `json:"successCount"`
+	FailureCount       int32  `json:"failureCount"`
+	Details            string `json:"details"`
+}
+
+// ContainerProbes represents the probe status for a container
+type ContainerProbes struct {
+	ContainerName string      `json:"containerName"`
+	Liveness      ProbeStatus `json:"liveness,omitempty"`
+	Readiness     ProbeStatus `json:"readiness,omitempty"`
+}
+
+// PodStatus represents the status response structure
+type PodStatus struct {
+	Name            string                   `json:"name"`
+	Namespace       string                   `json:"namespace"`
+	Phase           corev1.PodPhase          `json:"phase"`
+	Conditions      []corev1.PodCondition    `json:"conditions"`
+	ContainerStatus []corev1.ContainerStatus `json:"containerStatus"`
+	StartTime       string                   `json:"startTime,omitempty"`
+	PodIP           string                   `json:"podIP,omitempty"`
+	HostIP          string                   `json:"hostIP,omitempty"`
+	ProbeResults    []ContainerProbes        `json:"probeResults,omitempty"`
+}
+
+// DeploymentPods lists the pods of a deployment
+type DeploymentPods struct {
+	Name      string   `json:"name"`
+	Namespace string   `json:"namespace"`
+	PodNames  []string `json:"podNames"`
+}
+
+// DeploymentRolloutStatus represents the rollout status of a deployment
+type DeploymentRolloutStatus struct {
+	Name                string                       `json:"name"`
+	Namespace           string                       `json:"namespace"`
+	Generation          int64                        `json:"generation"`
+	ObservedGeneration  int64                        `json:"observedGeneration"`
+	Replicas            int32                        `json:"replicas"`
+	UpdatedReplicas     int32                        `json:"updatedReplicas"`
+	ReadyReplicas       int32                        `json:"readyReplicas"`
+	AvailableReplicas   int32                        `json:"availableReplicas"`
+	UnavailableReplicas int32                        `json:"unavailableReplicas"`
+	Conditions          []appsv1.DeploymentCondition `json:"conditions,omitempty"`
+	// Done is true once the rollout finished successfully
+	Done bool `json:"done"`
+	// Failed is true when the rollout exceeded its progress deadline
+	Failed bool `json:"failed"`
+	// Message describes the rollout progress, as printed by kubectl rollout status
+	Message string `json:"message"`
+}
+
+// WorkloadStatus represents the rollout status of a StatefulSet or DaemonSet,
+// the completion status of a Job, or the schedule status of a CronJob
+type WorkloadStatus struct {
+	Kind               string `json:"kind"`
+	Name               string `json:"name"`
+	Namespace          string `json:"namespace"`
+	Generation         int64  `json:"generation,omitempty"`
+	ObservedGeneration int64  `json:"observedGeneration,omitempty"`
+	// Owner is the Kind/name of the controller owning the workload, e.g. the CronJob of a Job
+	Owner string `json:"owner,omitempty"`
+	// Replicas is the desired number of pods: replicas of StatefulSets, scheduled pods of DaemonSets, completions of Jobs
+	Replicas          int32 `json:"replicas"`
+	UpdatedReplicas   int32 `json:"updatedReplicas"`
+	ReadyReplicas     int32 `json:"readyReplicas"`
+	AvailableReplicas int32 `json:"availableReplicas"`
+	// Active, Succeeded and FailedPods count the pods of Jobs, and the running jobs of CronJobs for Active
+	Active     int32 `json:"active,omitempty"`
+	Succeeded  int32 `json:"succeeded,omitempty"`
+	FailedPods int32 `json:"failedPods,omitempty"`
+	// Done is true once the rollout finished successfully or the job completed
+	Done bool `json:"done"`
+	// Failed is true when the job failed
+	Failed bool `json:"failed"`
+	// Message describes the progress, as printed by kubectl rollout status for StatefulSets and DaemonSets
+	Message string `json:"message"`
+}
+
+// PodSummary is the compact view of a pod, with the columns of kubectl get pods -o wide
+type PodSummary struct {
+	Name      string `json:"name"`
+	Namespace string `json:"namespace"`
+	// Status is the phase or the most relevant container state, e.g. CrashLoopBackOff
+	Status string `json:"status"`
+	// Ready is the number of ready containers out of all containers, e.g. 1/2
+	Ready    string `json:"ready"`
+	Restarts int32  `json:"restarts"`
+	Node     string `json:"node,omitempty"`
+	IP       string `json:"ip,omitempty"`
+	Age      string `json:"age"`
+	// Owner is the kind and name of the controller of the pod, e.g. ReplicaSet/nginx-7d9c6b4d5
+	Owner string `json:"owner,omitempty"`
+}
+
+// PodSummaryList is the summary view of a list of pods
+type PodSummaryList struct {
+	Items []PodSummary `json:"items"`
+	// Continue is the token to get the next page, empty on the last page
+	Continue string `json:"continue,omitempty"`
+}
+
+// Event is a Kubernetes event, merged with the events of the same object and reason
+type Event struct {
+	// Object is the kind and name of the object the event is about, e.g. Pod/nginx-7d9c6b4d5-x2x7k
+	Object         string    `json:"object"`
+	Type           string    `json:"type"`
+	Reason         string    `json:"reason"`
+	Message        string    `json:"message"`
+	Count          int32     `json:"count"`
+	FirstTimestamp time.Time `json:"firstTimestamp"`
+	LastTimestamp  time.Time `json:"lastTimestamp"`
+	Source         string    `json:"source,omitempty"`
+}
+
+// ObjectEvents represents the events of an object and its owned children
+type ObjectEvents struct {
+	Kind      string  `json:"kind"`
+	Name      string  `json:"name"`
+	Namespace string  `json:"namespace"`
+	Events    []Event `json:"events"`
+}
+
+// ApplyResponse represents the response structure for apply operation
+type ApplyResponse struct {
+	Kind      string      `json:"kind"`
+	Name      string      `json:"name"`
+	Namespace string      `json:"namespace"`
+	Action    string      `json:"action"` // "applied", "dry-run", "denied", "conflict", "failed", "skipped", "rolled-back", "restarted", "scaled", "evicted" or "patched"
+	DryRun    bool        `json:"dryRun,omitempty"`
+	Diff      []FieldDiff `json:"diff,omitempty"`
+	Error     string      `json:"error,omitempty"`
+	// Reason and Causes describe why the API server rejected the object, e.g. Invalid with the invalid fields
+	Reason string       `json:"reason,omitempty"`
+	Causes []ErrorCause `json:"causes,omitempty"`
+	// Violations lists the policy rules hit by a denied object
+	Violations []Violation `json:"violations,omitempty"`
+	// Conflicts lists the fields owned by other field managers that a non-forced apply would have changed
+	Conflicts []FieldConflict `json:"conflicts,omitempty"`
+	// RevisionID identifies the snapshot taken before the apply, pass it to /rollback/{revisionID} to undo it
+	RevisionID string `json:"revisionID,omitempty"`
+}
+
+// FieldConflict is a field owned by another field manager, e.g. a replica count managed by an HPA
+type FieldConflict struct {
+	// Field is the path of the field, e.g. .spec.replicas
+	Field string `json:"field"`
+	// Manager is the field manager owning the field, e.g. kubectl-client-side-apply or argocd-controller
+	Manager string `json:"manager"`
+	// Message is the conflict as reported by the API server
+	Message string `json:"message"`
+}
+
+// Error is the body of every error response of the agent
+type Error struct {
+	// Code is the HTTP status code of the response
+	Code int `json:"code"`
+	// Reason is the machine readable reason of the error, the reasons of Kubernetes API statuses, e.g. NotFound
+	Reason string `json:"reason"`
+	// Message is the human readable description of the error
+	Message string `json:"message"`
+	// Details describes the object the error is about, when the API server returned it
+	Details *ErrorDetails `json:"details,omitempty"`
+	// RequestID is the ID of the failed request, as in the X-Request-ID header
+	RequestID string `json:"requestID,omitempty"`
+}
+
+// ErrorDetails describes the object an error is about
+type ErrorDetails struct {
+	Group string `json:"group,omitempty"`
+	Kind  string `json:"kind,omitempty"`
+	Name  string `json:"name,omitempty"`
+	// Causes lists the individual problems, e.g. every invalid field of an object
+	Causes []ErrorCause `json:"causes,omitempty"`
+}
+
+// ErrorCause is a single problem of an error
+type ErrorCause struct {
+	// Type is the kind of problem, e.g. FieldValueRequired
+	Type    string `json:"type,omitempty"`
+	Message string `json:"message,omitempty"`
+	// Field is the path of the field at fault, e.g. spec.containers[0].image
+	Field string `json:"field,omitempty"`
+}
+
+// Violation describes a policy rule hit by an object
+type Violation struct {
+	Rule    string `json:"rule"`
+	Message string `json:"message"`
+	Path    string `json:"path,omitempty"`
+}
+
+// FeasibilityReport tells whether the pod template of an object can be scheduled, and why not
+type FeasibilityReport struct {
+	Kind      string `json:"kind"`
+	Name      string `json:"name"`
+	Namespace string `json:"namespace"`
+	// Feasible is true when a node fits the pod and the LimitRanges and ResourceQuotas of the namespace admit it
+	Feasible bool `json:"feasible"`
+	// Message summarizes why the pod can't be scheduled, as the scheduler reports it,
+	// e.g. 0/3 nodes are available: 3 Insufficient cpu.
+	Message string `json:"message,omitempty"`
+	// Requests and Limits are the resources of a single pod, LimitRange defaults included
+	Requests corev1.ResourceList `json:"requests,omitempty"`
+	Limits   corev1.ResourceList `json:"limits,omitempty"`
+	// MaxRequests are the largest requests of a single pod that a node and the namespace limits still allow,
+	// per resource, among the nodes the pod is allowed on
+	MaxRequests corev1.ResourceList `json:"maxRequests,omitempty"`
+	// Nodes reports every node, fitting or not
+	Nodes []NodeFeasibility `json:"nodes"`
+	// Violations lists the LimitRange and ResourceQuota limits the pod exceeds
+	Violations []LimitViolation `json:"violations,omitempty"`
+}
+
+// NodeFeasibility tells whether a pod fits a node
+type NodeFeasibility struct {
+	Name string `json:"name"`
+	Fits bool   `json:"fits"`
+	// Reasons lists why the pod does not fit, e.g. an untolerated taint or insufficient memory
+	Reasons []string `json:"reasons,omitempty"`
+	// Allocatable is the capacity of the node for pods, Requested what the pods running on it request,
+	// and Available the difference
+	Allocatable corev1.ResourceList `json:"allocatable"`
+	Requested   corev1.ResourceList `json:"requested"`
+	Available   corev1.ResourceList `json:"available"`
+}
+
+// LimitViolation is a LimitRange or ResourceQuota limit exceeded by a pod
+type LimitViolation struct {
+	// Source is the object setting the limit, e.g. ResourceQuota/compute
+	Source string `json:"source"`
+	// Container is the container at fault, empty for limits of the whole pod
+	Container string `json:"container,omitempty"`
+	// Resource is the limited resource, e.g. cpu or requests.memory
+	Resource string `json:"resource"`
+	Message  string `json:"message"`
+}
+
+// ImageStatus tells whether an image reference resolves in its registry
+type ImageStatus string
+
+const (
+	// ImageFound is an image the registry has a manifest for
+	ImageFound ImageStatus = "found"
+	// ImageNotFound is an image the registry has no manifest for, or denies pulling with the pull secrets of the pod
+	ImageNotFound ImageStatus = "not-found"
+	// ImageInvalid is a malformed image reference
+	ImageInvalid ImageStatus = "invalid"
+	// ImageUnverified is an image whose registry could not be queried, e.g. unreachable
+	ImageUnverified ImageStatus = "unverified"
+)
+
+// ImageReport tells whether the image of a container resolves in its registry
+type ImageReport struct {
+	Kind      string `json:"kind"`
+	Name      string `json:"name"`
+	Namespace string `json:"namespace"`
+	Container string `json:"container"`
+	// Image is the image of the container as written, e.g. busybox:lat
+	Image string `json:"image"`
+	// Reference is the fully qualified image reference, e.g. docker.io/library/busybox:lat
+	Reference string      `json:"reference,omitempty"`
+	Status    ImageStatus `json:"status"`
+	// Digest is the digest of the manifest the reference resolves to
+	Digest string `json:"digest,omitempty"`
+	// Message tells why the image was not found or could not be verified
+	Message string `json:"message,omitempty"`
+	// ClosestTags are the tags of the repository closest to a tag that was not found, closest first
+	ClosestTags []string `json:"closestTags,omitempty"`
+}
+
+// ScaleRequest is the body of the deployment scale endpoint
+type ScaleRequest struct {
+	// Replicas is required, a missing value is rejected rather than read as zero
+	Replicas *int32 `json:"replicas"`
+}
+
+// FieldDiff represents a single field that differs between the live object and the dry-run result
+type FieldDiff struct {
+	Path   string      `json:"path"`
+	Op     string      `json:"op"` // "add", "remove" or "replace"
+	Live   interface{} `json:"live,omitempty"`
+	DryRun interface{} `json:"dryRun,omitempty"`
+}
+
+// WatchEvent is sent for every change of a watched object
+type WatchEvent struct {
+	Type   string      `json:"type"`
+	Object interface{} `json:"object"`
+}
+
+// AuditRecord describes a single mutation made through the agent
+type AuditRecord struct {
+	Time       time.Time `json:"time"`
+	RequestID  string    `json:"requestID,omitempty"`
+	User       string    `json:"user,omitempty"`
+	Groups     []string  `json:"groups,omitempty"`
+	SourceIP   string    `json:"sourceIP,omitempty"`
+	Operation  string    `json:"operation"` // e.g. "apply" or "rollback"
+	APIVersion string    `json:"apiVersion"`
+	Kind       string    `json:"kind"`
+	Namespace  string    `json:"namespace,omitempty"`
+	Name       string    `json:"name"`
+	BeforeHash string    `json:"beforeHash,omitempty"`
+	AfterHash  string    `json:"afterHash,omitempty"`
+	DryRun     bool      `json:"dryRun"`
+	RevisionID string    `json:"revisionID,omitempty"`
+	Outcome    string    `json:"outcome"`
+	Error      string    `json:"error,omitempty"`
+}
@@ -0,0 +1,223 @@
+package audit
+
+import (
+	"bufio"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/Sanskarzz/k8sgptclient/k8s-agent/api"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+// maxRecordSize bounds the size of a single JSONL record when reading the log back
+const maxRecordSize = 1024 * 1024
+
+// errRecordTooLarge is returned by readRecord for a record larger than maxRecordSize
+var errRecordTooLarge = fmt.Errorf("audit record larger than %d bytes", maxRecordSize)
+
+// Record describes a single mutation made through the agent, it is returned by the audit endpoint
+type Record = api.AuditRecord
+
+// Filter selects records returned by Query, zero values match everything
+type Filter struct {
+	Since     time.Time
+	Until     time.Time
+	Kind      string
+	Namespace string
+	Name      string
+	User      string
+	Operation string
+	// Limit keeps only the most recent records, 0 returns every match
+	Limit int
+}
+
+func (f Filter) matches(record Record) bool {
+	return (f.Since.IsZero() || !record.Time.Before(f.Since)) &&
+		(f.Until.IsZero() || record.Time.Before(f.Until)) &&
+		(f.Kind == "" || f.Kind == record.Kind) &&
+		(f.Namespace == "" || f.Namespace == record.Namespace) &&
+		(f.Name == "" || f.Name == record.Name) &&
+		(f.User == "" || f.User == record.User) &&
+		(f.Operation == "" || f.Operation == record.Operation)
+}
+
+// Log is an append-only JSONL audit log
+type Log struct {
+	path string
+
+	mu sync.Mutex
+	// file is opened by Open or by the first Record
+	file *os.File
+}
+
+// New returns the audit log at path without opening it, so that only the agent recording mutations,
+// the leader of several replicas, opens the file
+func New(path string) *Log {
+	return &Log{path: path}
+}
+
+// Open opens the audit log for appending, creating it if needed
+func Open(path string) (*Log, error) {
+	l := New(path)
+	if err := l.Open(); err != nil {
+		return nil, err
+	}
+	return l, nil
+}
+
+// Open opens the log for appending, creating it if needed. Opening an open log does nothing.
+func (l *Log) Open() error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.open()
+}
+
+func (l *Log) open() error {
+	if l.file != nil {
+		return nil
+	}
+	if err := os.MkdirAll(filepath.Dir(l.path), 0o700); err != nil {
+		return fmt.Errorf("failed to create audit log directory: %w", err)
+	}
+	file, err := os.OpenFile(l.path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0o600)
+	if err != nil {
+		return fmt.Errorf("failed to open audit log: %w", err)
+	}
+	l.file = file
+	return nil
+}
+
+// Record appends a record to the log and syncs it to disk
+func (l *Log) Record(record Record) error {
+	if record.Time.IsZero() {
+		record.Time = time.Now().UTC()
+	}
+	data, err := json.Marshal(record)
+	if err != nil {
+		return fmt.Errorf("failed to marshal audit record: %w", err)
+	}
+	data = append(data, '\n')
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if err := l.open(); err != nil {
+		return err
+	}
+	if _, err := l.file.Write(data); err != nil {
+		return fmt.Errorf("failed to write audit record: %w", err)
+	}
+	if err := l.file.Sync(); err != nil {
+		return fmt.Errorf("failed to sync audit log: %w", err)
+	}
+	return nil
+}
+
+// Query returns the records matching the filter in chronological order, and the number of records skipped
+// because they can't be parsed, e.g. a record cut by a full disk. The log is read through its own handle
+// without blocking Record, a record still being written at the end of the log is left out.
+func (l *Log) Query(filter Filter) ([]Record, int, error) {
+	file, err := os.Open(l.path)
+	if errors.Is(err, os.ErrNotExist) {
+		// nothing was recorded yet
+		return []Record{}, 0, nil
+	}
+	if err != nil {
+		return nil, 0, fmt.Errorf("failed to open audit log: %w", err)
+	}
+	defer file.Close()
+
+	records := []Record{}
+	skipped := 0
+	reader := bufio.NewReaderSize(file, 64*1024)
+	for {
+		line, err := readRecord(reader)
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if errors.Is(err, errRecordTooLarge) {
+			skipped++
+			continue
+		}
+		if err != nil {
+			return nil, 0, fmt.Errorf("failed to read audit log: %w", err)
+		}
+		var record Record
+		if err := json.Unmarshal(line, &record); err != nil {
+			skipped++
+			continue
+		}
+		if !filter.matches(record) {
+			continue
+		}
+		records = append(records, record)
+		if filter.Limit > 0 && len(records) > filter.Limit {
+			records = records[1:]
+		}
+	}
+	return records, skipped, nil
+}
+
+// readRecord reads the next complete line of the log, io.EOF is returned at the end of the log
+// and for a last line missing its newline
+func readRecord(reader *bufio.Reader) ([]byte, error) {
+	var line []byte
+	tooLarge := false
+	for {
+		chunk, err := reader.ReadSlice('\n')
+		// the rest of a record too large to keep is read to get to the next one
+		if !tooLarge {
+			line = append(line, chunk...)
+			if len(line) > maxRecordSize {
+				tooLarge, line = true, nil
+			}
+		}
+		switch {
+		case err == nil && tooLarge:
+			return nil, errRecordTooLarge
+		case err == nil:
+			return line, nil
+		case errors.Is(err, bufio.ErrBufferFull):
+			continue
+		default:
+			return nil, err
+		}
+	}
+}
+
+// Close closes the audit log
+func (l *Log) Close() error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.file == nil {
+		return nil
+	}
+	return l.file.Close()
+}
+
+// Hash returns a stable hash of the object content, ignoring fields that change on every write.
+// A nil object hashes to the empty string.
+func Hash(obj *unstructured.Unstructured) string {
+	if obj == nil {
+		return ""
+	}
+	content := obj.DeepCopy()
+	content.SetManagedFields(nil)
+	content.SetResourceVersion("")
+	content.SetGeneration(0)
+	unstructured.RemoveNestedField(content.Object, "status")
+	// encoding/json sorts map keys, so the encoding is stable
+	data, err := json.Marshal(content.Object)
+	if err != nil {
+		return ""
+	}
+	sum := sha256.Sum256(data)
+	return "sha256:" + hex.EncodeToString(sum[:])
+}
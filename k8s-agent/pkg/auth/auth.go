@@ -0,0 +1,153 @@
+package auth
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	authenticationv1 "k8s.io/api/authentication/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// User represents an authenticated caller
+type User struct {
+	Name   string   `json:"name"`
+	UID    string   `json:"uid,omitempty"`
+	Groups []string `json:"groups,omitempty"`
+}
+
+// Authenticator validates a bearer token and returns the user it belongs to
+type Authenticator interface {
+	Authenticate(ctx context.Context, token string) (*User, error)
+}
+
+// ErrUnauthenticated is returned when a token is not valid
+var ErrUnauthenticated = errors.New("unauthenticated")
+
+type contextKey struct{}
+
+// WithUser returns a copy of the context carrying the authenticated user
+func WithUser(ctx context.Context, user *User) context.Context {
+	return context.WithValue(ctx, contextKey{}, user)
+}
+
+// UserFromContext returns the authenticated user stored in the context, if any
+func UserFromContext(ctx context.Context) (*User, bool) {
+	user, ok := ctx.Value(contextKey{}).(*User)
+	return user, ok
+}
+
+// maxCachedReviews bounds the reviews cached at once, an arbitrary review is evicted when the cache is full
+const maxCachedReviews = 10000
+
+type cachedReview struct {
+	user    *User
+	expires time.Time
+}
+
+// TokenReviewer authenticates bearer tokens through the Kubernetes TokenReview API
+type TokenReviewer struct {
+	client    client.Client
+	audiences []string
+	ttl       time.Duration
+
+	mu    sync.Mutex
+	cache map[string]cachedReview
+	// swept is when expired reviews were last removed from the cache
+	swept time.Time
+}
+
+// NewTokenReviewer creates a new TokenReviewer. Successful reviews are cached for ttl
+// so that every request does not result in a call to the API server.
+func NewTokenReviewer(client client.Client, audiences []string, ttl time.Duration) *TokenReviewer {
+	return &TokenReviewer{
+		client:    client,
+		audiences: audiences,
+		ttl:       ttl,
+		cache:     map[string]cachedReview{},
+	}
+}
+
+// Authenticate validates the token with a TokenReview
+func (t *TokenReviewer) Authenticate(ctx context.Context, token string) (*User, error) {
+	// Never keep the raw token around
+	sum := sha256.Sum256([]byte(token))
+	key := hex.EncodeToString(sum[:])
+
+	t.mu.Lock()
+	if cached, ok := t.cache[key]; ok {
+		if time.Now().Before(cached.expires) {
+			t.mu.Unlock()
+			return cached.user, nil
+		}
+		delete(t.cache, key)
+	}
+	t.mu.Unlock()
+
+	review := &authenticationv1.TokenReview{
+		Spec: authenticationv1.TokenReviewSpec{
+			Token:     token,
+			Audiences: t.audiences,
+		},
+	}
+	if err := t.client.Create(ctx, review); err != nil {
+		return nil, fmt.Errorf("failed to create token review: %w", err)
+	}
+	if !review.Status.Authenticated {
+		if review.Status.Error != "" {
+			return nil, fmt.Errorf("%w: %s", ErrUnauthenticated, review.Status.Error)
+		}
+		return nil, ErrUnauthenticated
+	}
+	if len(t.audiences) > 0 && !intersects(t.audiences, review.Status.Audiences) {
+		return nil, fmt.Errorf("%w: token audiences %v do not match %v", ErrUnauthenticated, review.Status.Audiences, t.audiences)
+	}
+
+	user := &User{
+		Name:   review.Status.User.Username,
+		UID:    review.Status.User.UID,
+		Groups: review.Status.User.Groups,
+	}
+
+	t.mu.Lock()
+	t.store(key, user)
+	t.mu.Unlock()
+
+	return user, nil
+}
+
+// store caches a review, removing the expired reviews at most once per ttl so that tokens that are not
+// used again don't stay in the cache. The caller holds the lock.
+func (t *TokenReviewer) store(key string, user *User) {
+	now := time.Now()
+	if now.Sub(t.swept) >= t.ttl {
+		for k, cached := range t.cache {
+			if !now.Before(cached.expires) {
+				delete(t.cache, k)
+			}
+		}
+		t.swept = now
+	}
+	if len(t.cache) >= maxCachedReviews {
+		for k := range t.cache {
+			delete(t.cache, k)
+			break
+		}
+	}
+	t.cache[key] = cachedReview{user: user, expires: now.Add(t.ttl)}
+}
+
+func intersects(a, b []string) bool {
+	for _, x := range a {
+		for _, y := range b {
+			if x == y {
+				return true
+			}
+		}
+	}
+	return false
+}
@@ -0,0 +1,73 @@
+package auth
+
+import (
+	"strings"
+)
+
+// Level is the access level required by a route
+type Level int
+
+const (
+	// Read allows calling endpoints that only read cluster state
+	Read Level = iota
+	// Write allows calling endpoints that mutate cluster state, it implies Read
+	Write
+)
+
+func (l Level) String() string {
+	if l == Write {
+		return "write"
+	}
+	return "read"
+}
+
+// groupPrefix marks a subject as a group rather than a user name
+const groupPrefix = "group:"
+
+// Authorizer decides whether a user can access routes of a given level.
+// Subjects are user names, e.g. system:serviceaccount:k8sgptclient:remediation-server,
+// or group names prefixed with "group:", e.g. group:system:serviceaccounts:k8sgptclient.
+type Authorizer struct {
+	readers map[string]bool
+	writers map[string]bool
+}
+
+// NewAuthorizer creates a new Authorizer from the read-only and write subjects
+func NewAuthorizer(readers, writers []string) *Authorizer {
+	toSet := func(subjects []string) map[string]bool {
+		set := map[string]bool{}
+		for _, subject := range subjects {
+			if subject = strings.TrimSpace(subject); subject != "" {
+				set[subject] = true
+			}
+		}
+		return set
+	}
+	return &Authorizer{
+		readers: toSet(readers),
+		writers: toSet(writers),
+	}
+}
+
+// Authorize returns true if the user is allowed to access routes of the given level
+func (a *Authorizer) Authorize(user *User, level Level) bool {
+	if user == nil {
+		return false
+	}
+	if matches(a.writers, user) {
+		return true
+	}
+	return level == Read && matches(a.readers, user)
+}
+
+func matches(subjects map[string]bool, user *User) bool {
+	if subjects[user.Name] {
+		return true
+	}
+	for _, group := range user.Groups {
+		if subjects[groupPrefix+group] {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,56 @@
+package auth
+
+import (
+	"errors"
+	"net/http"
+	"strings"
+
+	"github.com/Sanskarzz/k8sgptclient/k8s-agent/pkg/server"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+)
+
+// Middleware authenticates the bearer token of every request and checks the caller
+// is authorized for the route level before calling next
+func Middleware(authenticator Authenticator, authorizer *Authorizer, level Level, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		logger := log.FromContext(r.Context()).WithName("auth").WithValues(
+			"path", r.URL.Path,
+			"level", level.String(),
+		)
+
+		// Extract bearer token
+		header := r.Header.Get("Authorization")
+		token, found := strings.CutPrefix(header, "Bearer ")
+		if !found || strings.TrimSpace(token) == "" {
+			logger.Info("Missing bearer token")
+			w.Header().Set("WWW-Authenticate", "Bearer")
+			server.WriteError(w, r, http.StatusUnauthorized, "Unauthorized")
+			return
+		}
+
+		// Authenticate caller
+		user, err := authenticator.Authenticate(r.Context(), strings.TrimSpace(token))
+		if err != nil {
+			if errors.Is(err, ErrUnauthenticated) {
+				logger.Info("Invalid bearer token", "reason", err.Error())
+				w.Header().Set("WWW-Authenticate", "Bearer")
+				server.WriteError(w, r, http.StatusUnauthorized, "Unauthorized")
+				return
+			}
+			logger.Error(err, "Failed to authenticate request")
+			server.WriteError(w, r, http.StatusInternalServerError, "Failed to authenticate request")
+			return
+		}
+		logger = logger.WithValues("user", user.Name)
+
+		// Authorize caller
+		if !authorizer.Authorize(user, level) {
+			logger.Info("Request forbidden")
+			server.WriteError(w, r, http.StatusForbidden, "Forbidden")
+			return
+		}
+
+		logger.V(1).Info("Request authorized")
+		next.ServeHTTP(w, r.WithContext(WithUser(r.Context(), user)))
+	})
+}
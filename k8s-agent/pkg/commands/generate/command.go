@@ -0,0 +1,16 @@
+package generate
+
+import (
+	rbac "github.com/Sanskarzz/k8sgptclient/k8s-agent/pkg/commands/generate/rbac"
+	"github.com/spf13/cobra"
+)
+
+func Command() *cobra.Command {
+	command := &cobra.Command{
+		Use:   "generate",
+		Short: "Generate k8sgptclient manifests",
+	}
+	// command to generate the agent RBAC
+	command.AddCommand(rbac.Command())
+	return command
+}
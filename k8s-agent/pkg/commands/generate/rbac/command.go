@@ -0,0 +1,52 @@
+package rbac
+
+import (
+	"fmt"
+
+	"github.com/Sanskarzz/k8sgptclient/k8s-agent/pkg/rbac"
+	"github.com/spf13/cobra"
+	"sigs.k8s.io/yaml"
+)
+
+func Command() *cobra.Command {
+	var opts rbac.Options
+	command := &cobra.Command{
+		Use:   "rbac",
+		Short: "Print the roles and bindings of the agent",
+		Long: "Print the roles and bindings of the agent as YAML. With --namespaces the agent permissions are granted " +
+			"by a Role in each namespace, to match an agent started with the same --namespaces, and the ClusterRole " +
+			"only allows authenticating callers. The Roles of the agent namespace, for the snapshot store, the leader " +
+			"election Lease and the redaction key, are always printed.",
+		Args: cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			namespaces, err := rbac.NormalizeNamespaces(opts.Namespaces)
+			if err != nil {
+				return err
+			}
+			opts.Namespaces = namespaces
+
+			out := cmd.OutOrStdout()
+			for _, obj := range rbac.Objects(opts) {
+				data, err := yaml.Marshal(obj)
+				if err != nil {
+					return fmt.Errorf("failed to marshal %s %s: %w", obj.GetObjectKind().GroupVersionKind().Kind, obj.GetName(), err)
+				}
+				if _, err := fmt.Fprintf(out, "---\n%s", data); err != nil {
+					return err
+				}
+			}
+			return nil
+		},
+	}
+
+	command.Flags().StringSliceVar(&opts.Namespaces, "namespaces", nil, "Namespaces served by the agent (empty grants access to all namespaces)")
+	command.Flags().BoolVar(&opts.Scheduling, "scheduling", true, "Grant cluster wide read access to nodes and pods, used by /feasibility to check whether pods fit the nodes")
+	command.Flags().StringSliceVar(&opts.ImagePullSecrets, "image-pull-secrets", nil, "Names of the image pull secrets /images/verify may read (empty verifies images anonymously)")
+	command.Flags().StringVar(&opts.RedactionKeySecret, "redaction-key-secret", "k8s-agent-redaction-key", "Secret of the agent namespace holding the redaction key, like the agent flag (empty leaves out its permissions)")
+	command.Flags().StringVar(&opts.Name, "role-name", "k8s-agent-role", "Name of the roles")
+	command.Flags().StringVar(&opts.BindingName, "binding-name", "k8sgptclient-binding", "Name of the role bindings")
+	command.Flags().StringVar(&opts.ServiceAccount, "service-account", "k8s-agent", "Service account the agent runs as")
+	command.Flags().StringVar(&opts.ServiceAccountNamespace, "service-account-namespace", "k8sgptclient", "Namespace of the agent service account")
+
+	return command
+}
@@ -1,6 +1,7 @@
 package root
 
 import (
+	"github.com/Sanskarzz/k8sgptclient/k8s-agent/pkg/commands/generate"
 	"github.com/Sanskarzz/k8sgptclient/k8s-agent/pkg/commands/serve"
 	"github.com/spf13/cobra"
 )
@@ -11,5 +12,6 @@ func Command() *cobra.Command {
 		Short: "k8sgptclient is a client for k8sgpt",
 	}
 	root.AddCommand(serve.Command())
+	root.AddCommand(generate.Command())
 	return root
 }
@@ -1,22 +1,67 @@
 package agent
 
 import (
+	"bytes"
 	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"os"
+	"time"
 
+	"github.com/Sanskarzz/k8sgptclient/k8s-agent/pkg/audit"
+	"github.com/Sanskarzz/k8sgptclient/k8s-agent/pkg/auth"
+	"github.com/Sanskarzz/k8sgptclient/k8s-agent/pkg/leader"
+	"github.com/Sanskarzz/k8sgptclient/k8s-agent/pkg/metrics"
+	"github.com/Sanskarzz/k8sgptclient/k8s-agent/pkg/policy"
 	"github.com/Sanskarzz/k8sgptclient/k8s-agent/pkg/probes"
+	"github.com/Sanskarzz/k8sgptclient/k8s-agent/pkg/rbac"
+	"github.com/Sanskarzz/k8sgptclient/k8s-agent/pkg/redact"
+	"github.com/Sanskarzz/k8sgptclient/k8s-agent/pkg/registry"
+	"github.com/Sanskarzz/k8sgptclient/k8s-agent/pkg/server"
+	"github.com/Sanskarzz/k8sgptclient/k8s-agent/pkg/server/handlers"
 	"github.com/Sanskarzz/k8sgptclient/k8s-agent/pkg/signals"
+	"github.com/Sanskarzz/k8sgptclient/k8s-agent/pkg/snapshot"
 	"github.com/spf13/cobra"
 	"go.uber.org/multierr"
 	"k8s.io/apimachinery/pkg/util/wait"
+	"k8s.io/client-go/kubernetes"
 	"k8s.io/client-go/tools/clientcmd"
 	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/cache"
 	"sigs.k8s.io/controller-runtime/pkg/log"
-	"sigs.k8s.io/controller-runtime/pkg/metrics/server"
+	metricsserver "sigs.k8s.io/controller-runtime/pkg/metrics/server"
 )
 
 func Command() *cobra.Command {
 	var httpAddress string
 	var kubeConfigOverrides clientcmd.ConfigOverrides
+	var authEnabled bool
+	var authAudiences []string
+	var authCacheTTL time.Duration
+	var readers []string
+	var writers []string
+	var tlsOpts server.TLSOptions
+	var policyFile string
+	var snapshotStore string
+	var snapshotNamespace string
+	var snapshotDir string
+	var snapshotRetention int
+	var auditLogFile string
+	var forceApply bool
+	var redactEnabled bool
+	var redactionKeyFile string
+	var redactionKeySecret string
+	var leaderElect bool
+	var leaderElectionNamespace string
+	var leaderElectionID string
+	var advertiseAddress string
+	var leaderTLSServerName string
+	var namespaces []string
+	var insecureRegistries []string
+	var internalRegistries []string
+	var registryTimeout time.Duration
 	command := &cobra.Command{
 		Use:   "agent",
 		Short: "Start k8sgptclient Serve Agent",
@@ -24,11 +69,55 @@ func Command() *cobra.Command {
 			// Get logger for agent component
 			logger := log.Log.WithName("agent")
 
+			// Validate TLS flags
+			if (tlsOpts.CertFile == "") != (tlsOpts.KeyFile == "") {
+				return errors.New("--tls-cert-file and --tls-key-file must be set together")
+			}
+			if tlsOpts.ClientCAFile != "" && !tlsOpts.Enabled() {
+				return errors.New("--tls-client-ca-file requires --tls-cert-file and --tls-key-file")
+			}
+
+			// Validate served namespaces
+			namespaces, err := rbac.NormalizeNamespaces(namespaces)
+			if err != nil {
+				return err
+			}
+
+			if leaderElect && advertiseAddress == "" {
+				address, err := defaultAdvertiseAddress(httpAddress)
+				if err != nil {
+					return err
+				}
+				advertiseAddress = address
+			}
+
 			// Log startup information
 			logger.Info("Starting k8sgptclient agent",
 				"httpAddress", httpAddress,
+				"authEnabled", authEnabled,
+				"tls", tlsOpts.Enabled(),
+				"mtls", tlsOpts.MutualTLS(),
+				"policyFile", policyFile,
+				"snapshotStore", snapshotStore,
+				"auditLogFile", auditLogFile,
+				"forceApply", forceApply,
+				"redact", redactEnabled,
+				"leaderElect", leaderElect,
+				"namespaces", namespaces,
 			)
 
+			// Load apply policy
+			var applyPolicy *policy.Policy
+			if policyFile != "" {
+				logger.Info("Loading apply policy", "file", policyFile)
+				loaded, err := policy.Load(policyFile)
+				if err != nil {
+					logger.Error(err, "Failed to load apply policy")
+					return err
+				}
+				applyPolicy = loaded
+			}
+
 			// setup signals aware context
 			return signals.Do(context.Background(), func(ctx context.Context) error {
 				// track errors
@@ -46,15 +135,56 @@ func Command() *cobra.Command {
 						return err
 					}
 
-					// create a manager
-					logger.Info("Creating controller manager")
-					mgr, err := ctrl.NewManager(config, ctrl.Options{
+					// create a clientset for the APIs not covered by the controller-runtime client (e.g. pod logs)
+					clientset, err := kubernetes.NewForConfig(config)
+					if err != nil {
+						logger.Error(err, "Failed to create clientset")
+						return err
+					}
+
+					options := ctrl.Options{
 						Scheme: nil, // we'll use the default scheme
 						Logger: logger.WithName("manager"),
-						Metrics: server.Options{
+						Metrics: metricsserver.Options{
 							BindAddress: ":8081", // Change the metrics server port
 						},
-					})
+					}
+					// cache the objects of the served namespaces only, the agent may not be allowed to list the others
+					if len(namespaces) > 0 {
+						logger.Info("Restricting agent to namespaces", "namespaces", namespaces)
+						options.Cache.DefaultNamespaces = map[string]cache.Config{}
+						for _, namespace := range namespaces {
+							options.Cache.DefaultNamespaces[namespace] = cache.Config{}
+						}
+					}
+					// create the Lease lock electing the agent serving mutating endpoints
+					var identity string
+					if leaderElect {
+						hostname, err := os.Hostname()
+						if err != nil {
+							logger.Error(err, "Failed to get hostname")
+							return err
+						}
+						identity = leader.Identity(hostname, advertiseAddress)
+						logger.Info("Enabling leader election",
+							"namespace", leaderElectionNamespace,
+							"lease", leaderElectionID,
+							"identity", identity,
+						)
+						lock, err := leader.NewLock(clientset, leaderElectionNamespace, leaderElectionID, identity)
+						if err != nil {
+							logger.Error(err, "Failed to create leader election lock")
+							return err
+						}
+						options.LeaderElection = true
+						options.LeaderElectionResourceLockInterface = lock
+						// step down on shutdown so that another agent takes over without waiting for the Lease to expire
+						options.LeaderElectionReleaseOnCancel = true
+					}
+
+					// create a manager
+					logger.Info("Creating controller manager")
+					mgr, err := ctrl.NewManager(config, options)
 					if err != nil {
 						logger.Error(err, "Failed to create manager")
 						return err
@@ -80,15 +210,145 @@ func Command() *cobra.Command {
 						}
 					})
 
+					// create authenticator and authorizer
+					var authenticator auth.Authenticator
+					if authEnabled {
+						logger.Info("Enabling TokenReview authentication",
+							"audiences", authAudiences,
+							"readers", readers,
+							"writers", writers,
+						)
+						authenticator = auth.NewTokenReviewer(mgr.GetClient(), authAudiences, authCacheTTL)
+					}
+					authorizer := auth.NewAuthorizer(readers, writers)
+
+					// create snapshot store
+					var snapshots snapshot.Store
+					switch snapshotStore {
+					case "configmap":
+						logger.Info("Using ConfigMap snapshot store", "namespace", snapshotNamespace, "retention", snapshotRetention)
+						snapshots = snapshot.NewConfigMapStore(mgr.GetClient(), mgr.GetAPIReader(), snapshotNamespace, snapshotRetention)
+					case "file":
+						logger.Info("Using file snapshot store", "dir", snapshotDir, "retention", snapshotRetention)
+						snapshots, err = snapshot.NewFileStore(snapshotDir, snapshotRetention)
+						if err != nil {
+							logger.Error(err, "Failed to create snapshot store")
+							return err
+						}
+					case "none":
+						logger.Info("Snapshots are disabled")
+					default:
+						return fmt.Errorf("invalid snapshot store %q, expected one of configmap, file, none", snapshotStore)
+					}
+
+					// open audit log, replicas leave it to the leader which records the mutations
+					var auditLog *audit.Log
+					if auditLogFile != "" {
+						auditLog = audit.New(auditLogFile)
+						defer auditLog.Close()
+						if !leaderElect {
+							logger.Info("Opening audit log", "file", auditLogFile)
+							if err := auditLog.Open(); err != nil {
+								logger.Error(err, "Failed to open audit log")
+								return err
+							}
+						}
+					} else {
+						logger.Info("Audit log is disabled")
+					}
+
+					// create redactor
+					var redactor *redact.Redactor
+					if redactEnabled {
+						var key []byte
+						switch {
+						case redactionKeyFile != "":
+							logger.Info("Loading redaction key", "file", redactionKeyFile)
+							data, err := os.ReadFile(redactionKeyFile)
+							if err != nil {
+								logger.Error(err, "Failed to read redaction key")
+								return err
+							}
+							key = bytes.TrimSpace(data)
+						case redactionKeySecret != "":
+							logger.Info("Loading redaction key", "namespace", podNamespace(), "secret", redactionKeySecret)
+							key, err = redact.LoadOrCreateKey(ctx, clientset, podNamespace(), redactionKeySecret)
+							if err != nil {
+								logger.Error(err, "Failed to load redaction key")
+								return err
+							}
+						}
+						redactor, err = redact.New(key)
+						if err != nil {
+							logger.Error(err, "Failed to create redactor")
+							return err
+						}
+					}
+
+					// forward mutating requests to the leader
+					var elector *leader.Elector
+					var forwarder *leader.Forwarder
+					if leaderElect {
+						elector = leader.NewElector(clientset, leaderElectionNamespace, leaderElectionID, identity, mgr.Elected())
+						group.Start(func() {
+							select {
+							case <-ctx.Done():
+							case <-mgr.Elected():
+								logger.Info("Elected as leader", "identity", identity)
+								metrics.Leader.Set(1)
+								if auditLog != nil {
+									logger.Info("Opening audit log", "file", auditLogFile)
+									if err := auditLog.Open(); err != nil {
+										// mutations fail to be recorded until the log can be opened
+										logger.Error(err, "Failed to open audit log")
+									}
+								}
+							}
+						})
+
+						scheme := "http"
+						transport := http.DefaultTransport.(*http.Transport).Clone()
+						if tlsOpts.Enabled() {
+							scheme = "https"
+							transport.TLSClientConfig, err = server.NewForwardTLSConfig(ctx, tlsOpts, leaderTLSServerName)
+							if err != nil {
+								logger.Error(err, "Failed to configure forwarding TLS")
+								return err
+							}
+						}
+						forwarder = leader.NewForwarder(elector, scheme, transport)
+					}
+
 					// create http server
 					logger.Info("Creating HTTP server", "address", httpAddress)
-					http := probes.NewServer(httpAddress, mgr)
+					handler := handlers.NewClientHandler(mgr.GetClient(), clientset,
+						handlers.WithAPIReader(mgr.GetAPIReader()),
+						handlers.WithPolicy(applyPolicy),
+						handlers.WithSnapshotStore(snapshots),
+						handlers.WithAuditLog(auditLog),
+						handlers.WithInformers(mgr.GetCache()),
+						handlers.WithForceApply(forceApply),
+						handlers.WithRedactor(redactor),
+						handlers.WithNamespaces(namespaces),
+						handlers.WithRegistry(registry.NewClient(registry.Options{
+							Insecure: insecureRegistries,
+							Internal: internalRegistries,
+							Timeout:  registryTimeout,
+						})),
+					)
+					httpServer := probes.NewServer(httpAddress, mgr, handler, probes.Options{
+						TLS:           tlsOpts,
+						Authenticator: authenticator,
+						Authorizer:    authorizer,
+						Elector:       elector,
+						Forwarder:     forwarder,
+					})
 					// run server
 					group.StartWithContext(ctx, func(ctx context.Context) {
 						// cancel context at the end
 						defer cancel()
 						logger.Info("Starting HTTP server")
-						httpErr = http.Run(ctx)
+						httpErr = httpServer.Run(ctx)
 						if httpErr != nil {
 							logger.Error(httpErr, "HTTP server stopped with error")
 						} else {
@@ -110,7 +370,57 @@ func Command() *cobra.Command {
 	}
 
 	command.Flags().StringVar(&httpAddress, "http-address", ":8080", "Address to listen on")
+	command.Flags().StringVar(&tlsOpts.CertFile, "tls-cert-file", "", "Serving certificate file, enables https (reloaded on change)")
+	command.Flags().StringVar(&tlsOpts.KeyFile, "tls-key-file", "", "Serving private key file (reloaded on change)")
+	command.Flags().StringVar(&tlsOpts.ClientCAFile, "tls-client-ca-file", "", "CA bundle used to verify client certificates, enables mutual TLS on API endpoints (reloaded on change)")
+	command.Flags().StringVar(&policyFile, "policy-file", "", "Apply policy file restricting namespaces, kinds and fields (empty allows everything)")
+	command.Flags().StringVar(&snapshotStore, "snapshot-store", "configmap", "Where objects are snapshotted before apply for rollback: configmap, file or none")
+	command.Flags().StringVar(&snapshotNamespace, "snapshot-namespace", podNamespace(), "Namespace of the ConfigMap snapshot store")
+	command.Flags().StringVar(&snapshotDir, "snapshot-dir", "/var/lib/k8s-agent/snapshots", "Directory of the file snapshot store")
+	command.Flags().IntVar(&snapshotRetention, "snapshot-retention", 100, "Number of revisions kept by the snapshot store (0 keeps everything)")
+	command.Flags().StringVar(&auditLogFile, "audit-log-file", "/var/lib/k8s-agent/audit.jsonl", "Append-only JSONL audit log of mutations made through the agent (empty disables auditing)")
+	command.Flags().BoolVar(&forceApply, "force-apply", true, "Take ownership of fields managed by others on apply by default, when false conflicts are reported with status 409 unless the request sets force=true")
+	command.Flags().BoolVar(&redactEnabled, "redact", true, "Replace sensitive values (secret env values and flags, credentials, Secret data) with tokens in returned objects, restored on apply and patch")
+	command.Flags().StringVar(&redactionKeyFile, "redaction-key-file", "", "Key used to derive redaction tokens, share it between agents so that tokens survive restarts (empty uses a random key)")
+	command.Flags().StringVar(&redactionKeySecret, "redaction-key-secret", "k8s-agent-redaction-key", "Secret of the agent namespace holding the redaction key, created with a random key if missing, used when --redaction-key-file is empty (empty uses a random key)")
+	command.Flags().BoolVar(&leaderElect, "leader-elect", true, "Elect a leader with a Lease, mutating endpoints are served by the leader and forwarded to it by the other replicas")
+	command.Flags().StringVar(&leaderElectionNamespace, "leader-election-namespace", podNamespace(), "Namespace of the leader election Lease")
+	command.Flags().StringVar(&leaderElectionID, "leader-election-id", "k8s-agent", "Name of the leader election Lease")
+	command.Flags().StringVar(&advertiseAddress, "advertise-address", "", "Address other replicas forward requests to when this agent is the leader (defaults to $POD_IP with the port of --http-address)")
+	command.Flags().StringVar(&leaderTLSServerName, "leader-tls-server-name", "k8s-agent."+podNamespace()+".svc", "Name verified in the serving certificate of the leader when forwarding over https, replicas share their serving certificate")
+	command.Flags().StringSliceVar(&namespaces, "namespaces", nil, "Namespaces served by the agent, objects of other namespaces and cluster scoped objects are neither cached nor served (empty serves all namespaces), see generate rbac for matching roles")
+	command.Flags().StringSliceVar(&insecureRegistries, "insecure-registries", nil, "Registries queried over plain http when verifying images, e.g. registry.local:5000, they may be on internal addresses")
+	command.Flags().StringSliceVar(&internalRegistries, "internal-registries", nil, "Registries allowed on loopback, private and link-local addresses when verifying images, e.g. registry.local or localhost:5000")
+	command.Flags().DurationVar(&registryTimeout, "registry-timeout", 10*time.Second, "Timeout of the verification of an image against its registry")
+	command.Flags().BoolVar(&authEnabled, "auth-enabled", true, "Authenticate API requests with bearer tokens validated through the TokenReview API")
+	command.Flags().StringSliceVar(&authAudiences, "auth-audiences", []string{"k8s-agent"}, "Audiences accepted in bearer tokens")
+	command.Flags().DurationVar(&authCacheTTL, "auth-cache-ttl", time.Minute, "How long successful token reviews are cached")
+	command.Flags().StringSliceVar(&readers, "auth-readers", nil, "Users (or groups prefixed with group:) allowed to call read-only endpoints")
+	command.Flags().StringSliceVar(&writers, "auth-writers", []string{"system:serviceaccount:k8sgptclient:remediation-server"}, "Users (or groups prefixed with group:) allowed to call all endpoints, including /apply")
 	clientcmd.BindOverrideFlags(&kubeConfigOverrides, command.Flags(), clientcmd.RecommendedConfigOverrideFlags("kube-"))
 
 	return command
 }
+
+// defaultAdvertiseAddress returns the pod IP, or the hostname outside of a pod, with the port of the http address
+func defaultAdvertiseAddress(httpAddress string) (string, error) {
+	_, port, err := net.SplitHostPort(httpAddress)
+	if err != nil {
+		return "", fmt.Errorf("invalid --http-address %q: %w", httpAddress, err)
+	}
+	host := os.Getenv("POD_IP")
+	if host == "" {
+		if host, err = os.Hostname(); err != nil {
+			return "", fmt.Errorf("failed to get hostname: %w", err)
+		}
+	}
+	return net.JoinHostPort(host, port), nil
+}
+
+// podNamespace returns the namespace the agent runs in, as exposed through the downward API
+func podNamespace() string {
+	if namespace := os.Getenv("POD_NAMESPACE"); namespace != "" {
+		return namespace
+	}
+	return "k8sgptclient"
+}
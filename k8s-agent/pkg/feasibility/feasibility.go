@@ -0,0 +1,145 @@
+// Package feasibility tells whether a pod can be scheduled, the way the scheduler and the admission plugins
+// would decide: node resources, taints, node selectors and affinity, LimitRanges and ResourceQuotas.
+// Pod affinity, topology spread constraints and volumes are not checked.
+package feasibility
+
+import (
+	"strings"
+
+	"github.com/Sanskarzz/k8sgptclient/k8s-agent/api"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+)
+
+// Cluster is the state the feasibility of a pod is checked against
+type Cluster struct {
+	Nodes []corev1.Node
+	// Pods are the pods bound to the nodes, terminated pods are ignored
+	Pods []corev1.Pod
+	// LimitRanges and ResourceQuotas are the ones of the pod namespace
+	LimitRanges    []corev1.LimitRange
+	ResourceQuotas []corev1.ResourceQuota
+}
+
+// Check reports whether the pod can be scheduled. The containers of pod are defaulted by the LimitRanges
+// like on admission. The report is about a single pod, replicas of a workload are not added up.
+func Check(pod *corev1.Pod, cluster Cluster) api.FeasibilityReport {
+	pod = pod.DeepCopy()
+	applyDefaults(&pod.Spec, cluster.LimitRanges)
+	podRequests := podResources(&pod.Spec, requests)
+	podLimits := podResources(&pod.Spec, limits)
+
+	report := api.FeasibilityReport{
+		Kind:        "Pod",
+		Name:        pod.Name,
+		Namespace:   pod.Namespace,
+		Requests:    podRequests,
+		Limits:      podLimits,
+		MaxRequests: corev1.ResourceList{},
+		Nodes:       make([]api.NodeFeasibility, 0, len(cluster.Nodes)),
+	}
+
+	// Sum up the requests of the pods running on each node
+	requested := map[string]corev1.ResourceList{}
+	for i := range cluster.Pods {
+		running := &cluster.Pods[i]
+		if running.Spec.NodeName == "" || running.Status.Phase == corev1.PodSucceeded || running.Status.Phase == corev1.PodFailed {
+			continue
+		}
+		list, ok := requested[running.Spec.NodeName]
+		if !ok {
+			list = corev1.ResourceList{}
+			requested[running.Spec.NodeName] = list
+		}
+		addResources(list, podResources(&running.Spec, requests))
+		addResources(list, corev1.ResourceList{corev1.ResourcePods: resource.MustParse("1")})
+	}
+
+	// Check every node, the largest requests are the resources available on the nodes the pod is allowed on
+	var unfits [][]unfit
+	fits := false
+	for i := range cluster.Nodes {
+		node := &cluster.Nodes[i]
+		allocatable := node.Status.Allocatable
+		available := corev1.ResourceList{}
+		for name, quantity := range allocatable {
+			free := quantity.DeepCopy()
+			free.Sub(requested[node.Name][name])
+			if free.Sign() < 0 {
+				free = resource.Quantity{Format: quantity.Format}
+			}
+			available[name] = free
+		}
+
+		reasons := checkPlacement(pod, node)
+		if len(reasons) == 0 {
+			maxResources(report.MaxRequests, available)
+		}
+		reasons = append(reasons, checkResources(podRequests, allocatable, available)...)
+
+		nodeReport := api.NodeFeasibility{
+			Name:        node.Name,
+			Fits:        len(reasons) == 0,
+			Allocatable: allocatable,
+			Requested:   nonZero(requested[node.Name]),
+			Available:   available,
+		}
+		for _, reason := range reasons {
+			nodeReport.Reasons = append(nodeReport.Reasons, reason.detail)
+		}
+		report.Nodes = append(report.Nodes, nodeReport)
+		if nodeReport.Fits {
+			fits = true
+		} else {
+			unfits = append(unfits, reasons)
+		}
+	}
+	// the pod count is not a request the pod can change
+	delete(report.MaxRequests, corev1.ResourcePods)
+
+	// Check the namespace limits, quotas also cap the largest requests
+	for i := range cluster.LimitRanges {
+		report.Violations = append(report.Violations, checkLimitRange(&cluster.LimitRanges[i], &pod.Spec)...)
+		for _, item := range cluster.LimitRanges[i].Spec.Limits {
+			if item.Type == corev1.LimitTypePod {
+				for name, max := range item.Max {
+					if _, ok := report.MaxRequests[name]; ok {
+						minResource(report.MaxRequests, name, max)
+					}
+				}
+			}
+		}
+	}
+	usage := quotaUsage(podRequests, podLimits)
+	for i := range cluster.ResourceQuotas {
+		quota := &cluster.ResourceQuotas[i]
+		if !quotaMatches(quota, &pod.Spec) {
+			continue
+		}
+		violations, remaining := checkQuota(quota, &pod.Spec, usage)
+		report.Violations = append(report.Violations, violations...)
+		for name, left := range remaining {
+			if _, ok := report.MaxRequests[name]; ok {
+				if left.Sign() < 0 {
+					left = resource.Quantity{Format: left.Format}
+				}
+				minResource(report.MaxRequests, name, left)
+			}
+		}
+	}
+	if len(report.MaxRequests) == 0 {
+		report.MaxRequests = nil
+	}
+
+	// Summarize
+	report.Feasible = fits && len(report.Violations) == 0
+	var messages []string
+	for _, violation := range report.Violations {
+		messages = append(messages, violation.Message)
+	}
+	report.Message = strings.Join(messages, "; ")
+	if !fits {
+		report.Message = strings.TrimSpace(summarize(len(cluster.Nodes), unfits) + " " + report.Message)
+	}
+	return report
+}
@@ -0,0 +1,283 @@
+package feasibility
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/Sanskarzz/k8sgptclient/k8s-agent/api"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+)
+
+// standardResources are the resources quotas limit by their plain name as well as with the requests. prefix
+var standardResources = []corev1.ResourceName{corev1.ResourceCPU, corev1.ResourceMemory, corev1.ResourceEphemeralStorage}
+
+// checkLimitRange reports the minimums, maximums and limit to request ratios of a LimitRange exceeded by the pod,
+// with the messages of the LimitRanger admission plugin
+func checkLimitRange(limitRange *corev1.LimitRange, spec *corev1.PodSpec) []api.LimitViolation {
+	source := "LimitRange/" + limitRange.Name
+	var violations []api.LimitViolation
+	for _, item := range limitRange.Spec.Limits {
+		switch item.Type {
+		case corev1.LimitTypeContainer:
+			for _, containers := range [][]corev1.Container{spec.InitContainers, spec.Containers} {
+				for _, container := range containers {
+					for _, violation := range checkLimitRangeItem(item, "Container", container.Resources.Requests, container.Resources.Limits) {
+						violation.Source = source
+						violation.Container = container.Name
+						violations = append(violations, violation)
+					}
+				}
+			}
+		case corev1.LimitTypePod:
+			for _, violation := range checkLimitRangeItem(item, "Pod", podResources(spec, requests), podResources(spec, limits)) {
+				violation.Source = source
+				violations = append(violations, violation)
+			}
+		}
+	}
+	return violations
+}
+
+// checkLimitRangeItem checks the requests and limits of a container or pod against a LimitRange item
+func checkLimitRangeItem(item corev1.LimitRangeItem, scope string, requests, limits corev1.ResourceList) []api.LimitViolation {
+	var violations []api.LimitViolation
+	violate := func(name corev1.ResourceName, format string, args ...interface{}) {
+		violations = append(violations, api.LimitViolation{Resource: string(name), Message: fmt.Sprintf(format, args...)})
+	}
+
+	for _, name := range sortedNames(item.Min) {
+		min := item.Min[name]
+		request, hasRequest := requests[name]
+		limit, hasLimit := limits[name]
+		switch {
+		case !hasRequest:
+			violate(name, "minimum %s usage per %s is %s. No request is specified", name, scope, min.String())
+		case request.Cmp(min) < 0:
+			violate(name, "minimum %s usage per %s is %s, but request is %s", name, scope, min.String(), request.String())
+		}
+		if hasLimit && limit.Cmp(min) < 0 {
+			violate(name, "minimum %s usage per %s is %s, but limit is %s", name, scope, min.String(), limit.String())
+		}
+	}
+
+	for _, name := range sortedNames(item.Max) {
+		max := item.Max[name]
+		request, hasRequest := requests[name]
+		limit, hasLimit := limits[name]
+		if !hasLimit {
+			violate(name, "maximum %s usage per %s is %s. No limit is specified", name, scope, max.String())
+		} else if limit.Cmp(max) > 0 {
+			violate(name, "maximum %s usage per %s is %s, but limit is %s", name, scope, max.String(), limit.String())
+		}
+		if hasRequest && request.Cmp(max) > 0 {
+			violate(name, "maximum %s usage per %s is %s, but request is %s", name, scope, max.String(), request.String())
+		}
+	}
+
+	for _, name := range sortedNames(item.MaxLimitRequestRatio) {
+		ratio := item.MaxLimitRequestRatio[name]
+		request, hasRequest := requests[name]
+		limit, hasLimit := limits[name]
+		if !hasRequest || !hasLimit || request.IsZero() {
+			violate(name, "%s max limit to request ratio per %s is %s, but no request or limit is specified", name, scope, ratio.String())
+			continue
+		}
+		actual := float64(limit.MilliValue()) / float64(request.MilliValue())
+		if actual > float64(ratio.MilliValue())/1000 {
+			violate(name, "%s max limit to request ratio per %s is %s, but provided ratio is %f", name, scope, ratio.String(), actual)
+		}
+	}
+	return violations
+}
+
+// quotaUsage returns the usage a pod adds to the resources tracked by quotas
+func quotaUsage(podRequests, podLimits corev1.ResourceList) corev1.ResourceList {
+	usage := corev1.ResourceList{
+		corev1.ResourcePods:               resource.MustParse("1"),
+		corev1.ResourceName("count/pods"): resource.MustParse("1"),
+	}
+	for name, quantity := range podRequests {
+		usage[corev1.ResourceName("requests."+string(name))] = quantity
+	}
+	for _, name := range standardResources {
+		if quantity, ok := podRequests[name]; ok {
+			usage[name] = quantity
+		}
+		if quantity, ok := podLimits[name]; ok {
+			usage[corev1.ResourceName("limits."+string(name))] = quantity
+		}
+	}
+	return usage
+}
+
+// quotaMatches returns true when the scopes of quota select the pod. Scopes are ANDed.
+func quotaMatches(quota *corev1.ResourceQuota, spec *corev1.PodSpec) bool {
+	for _, scope := range quota.Spec.Scopes {
+		if !scopeMatches(scope, spec) {
+			return false
+		}
+	}
+	if quota.Spec.ScopeSelector == nil {
+		return true
+	}
+	for _, requirement := range quota.Spec.ScopeSelector.MatchExpressions {
+		switch requirement.Operator {
+		case corev1.ScopeSelectorOpExists:
+			if !scopeMatches(requirement.ScopeName, spec) {
+				return false
+			}
+		case corev1.ScopeSelectorOpDoesNotExist:
+			if scopeMatches(requirement.ScopeName, spec) {
+				return false
+			}
+		case corev1.ScopeSelectorOpIn, corev1.ScopeSelectorOpNotIn:
+			// only the priority class scope has values
+			if requirement.ScopeName != corev1.ResourceQuotaScopePriorityClass {
+				return false
+			}
+			in := false
+			for _, value := range requirement.Values {
+				in = in || value == spec.PriorityClassName
+			}
+			if in != (requirement.Operator == corev1.ScopeSelectorOpIn) {
+				return false
+			}
+		default:
+			return false
+		}
+	}
+	return true
+}
+
+// scopeMatches returns true when a quota scope selects the pod
+func scopeMatches(scope corev1.ResourceQuotaScope, spec *corev1.PodSpec) bool {
+	switch scope {
+	case corev1.ResourceQuotaScopeTerminating:
+		return spec.ActiveDeadlineSeconds != nil && *spec.ActiveDeadlineSeconds >= 0
+	case corev1.ResourceQuotaScopeNotTerminating:
+		return spec.ActiveDeadlineSeconds == nil || *spec.ActiveDeadlineSeconds < 0
+	case corev1.ResourceQuotaScopeBestEffort:
+		return isBestEffort(spec)
+	case corev1.ResourceQuotaScopeNotBestEffort:
+		return !isBestEffort(spec)
+	case corev1.ResourceQuotaScopePriorityClass:
+		return spec.PriorityClassName != ""
+	default:
+		return false
+	}
+}
+
+// isBestEffort returns true when no container requests nor limits cpu or memory
+func isBestEffort(spec *corev1.PodSpec) bool {
+	for _, containers := range [][]corev1.Container{spec.InitContainers, spec.Containers} {
+		for _, container := range containers {
+			for _, list := range []corev1.ResourceList{container.Resources.Requests, container.Resources.Limits} {
+				for _, name := range []corev1.ResourceName{corev1.ResourceCPU, corev1.ResourceMemory} {
+					if quantity, ok := list[name]; ok && !quantity.IsZero() {
+						return false
+					}
+				}
+			}
+		}
+	}
+	return true
+}
+
+// checkQuota reports the hard limits of a quota the pod would exceed, with the messages of the ResourceQuota
+// admission plugin, and returns what is left of the quota for the requests of a single pod
+func checkQuota(quota *corev1.ResourceQuota, spec *corev1.PodSpec, usage corev1.ResourceList) ([]api.LimitViolation, corev1.ResourceList) {
+	source := "ResourceQuota/" + quota.Name
+	hard := quota.Status.Hard
+	if hard == nil {
+		hard = quota.Spec.Hard
+	}
+
+	var violations []api.LimitViolation
+	// every container must set the requests and limits a quota tracks
+	for _, name := range sortedNames(hard) {
+		resourceName, pick := requiredResource(name)
+		if pick == nil {
+			continue
+		}
+		var missing []string
+		for _, containers := range [][]corev1.Container{spec.InitContainers, spec.Containers} {
+			for _, container := range containers {
+				if _, ok := pick(container.Resources)[resourceName]; !ok {
+					missing = append(missing, container.Name)
+				}
+			}
+		}
+		if len(missing) > 0 {
+			violations = append(violations, api.LimitViolation{
+				Source:   source,
+				Resource: string(name),
+				Message:  fmt.Sprintf("failed quota: %s: must specify %s for: %s", quota.Name, name, strings.Join(missing, ",")),
+			})
+		}
+	}
+
+	remaining := corev1.ResourceList{}
+	for _, name := range sortedNames(hard) {
+		requested, ok := usage[name]
+		if !ok {
+			continue
+		}
+		limit := hard[name]
+		used := quota.Status.Used[name]
+		left := limit.DeepCopy()
+		left.Sub(used)
+		if resourceName, ok := requestedResource(name); ok {
+			minResource(remaining, resourceName, left)
+		}
+		total := used.DeepCopy()
+		total.Add(requested)
+		if total.Cmp(limit) > 0 {
+			violations = append(violations, api.LimitViolation{
+				Source:   source,
+				Resource: string(name),
+				Message: fmt.Sprintf("exceeded quota: %s, requested: %s=%s, used: %s=%s, limited: %s=%s",
+					quota.Name, name, requested.String(), name, used.String(), name, limit.String()),
+			})
+		}
+	}
+	return violations, remaining
+}
+
+// requiredResource returns the container resource a quota resource requires every container to set,
+// nil when it requires none
+func requiredResource(name corev1.ResourceName) (corev1.ResourceName, func(corev1.ResourceRequirements) corev1.ResourceList) {
+	for _, standard := range standardResources {
+		switch name {
+		case standard, corev1.ResourceName("requests." + string(standard)):
+			return standard, requests
+		case corev1.ResourceName("limits." + string(standard)):
+			return standard, limits
+		}
+	}
+	return "", nil
+}
+
+// requestedResource returns the resource requested by pods that a quota resource tracks, e.g. cpu for requests.cpu
+func requestedResource(name corev1.ResourceName) (corev1.ResourceName, bool) {
+	if trimmed, ok := strings.CutPrefix(string(name), "requests."); ok {
+		return corev1.ResourceName(trimmed), true
+	}
+	for _, standard := range standardResources {
+		if name == standard {
+			return standard, true
+		}
+	}
+	return "", false
+}
+
+// sortedNames returns the resource names of list, sorted
+func sortedNames(list corev1.ResourceList) []corev1.ResourceName {
+	names := make([]corev1.ResourceName, 0, len(list))
+	for name := range list {
+		names = append(names, name)
+	}
+	sort.Slice(names, func(i, j int) bool { return names[i] < names[j] })
+	return names
+}
@@ -0,0 +1,201 @@
+package feasibility
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/selection"
+)
+
+// Reasons the scheduler reports nodes by, as in its FailedScheduling events
+const (
+	reasonNodeName         = "node(s) didn't match the requested node name"
+	reasonUnschedulable    = "node(s) were unschedulable"
+	reasonNodeAffinity     = "node(s) didn't match Pod's node affinity/selector"
+	reasonTooManyPods      = "Too many pods"
+	reasonInsufficient     = "Insufficient %s"
+	reasonUntoleratedTaint = "node(s) had untolerated taint {%s: %s}"
+)
+
+// unfit is a reason a pod does not fit a node. reason is shared by every node failing the same way,
+// detail tells the values of this node.
+type unfit struct {
+	reason string
+	detail string
+}
+
+// nodeSelectorOperators maps node selector operators to label selector operators
+var nodeSelectorOperators = map[corev1.NodeSelectorOperator]selection.Operator{
+	corev1.NodeSelectorOpIn:           selection.In,
+	corev1.NodeSelectorOpNotIn:        selection.NotIn,
+	corev1.NodeSelectorOpExists:       selection.Exists,
+	corev1.NodeSelectorOpDoesNotExist: selection.DoesNotExist,
+	corev1.NodeSelectorOpGt:           selection.GreaterThan,
+	corev1.NodeSelectorOpLt:           selection.LessThan,
+}
+
+// checkPlacement reports why pod is not allowed on node: node name, cordon, taints, node selector and node affinity
+func checkPlacement(pod *corev1.Pod, node *corev1.Node) []unfit {
+	var reasons []unfit
+	if pod.Spec.NodeName != "" && pod.Spec.NodeName != node.Name {
+		reasons = append(reasons, unfit{reasonNodeName, fmt.Sprintf("pod requests node %s", pod.Spec.NodeName)})
+	}
+
+	if node.Spec.Unschedulable && !tolerates(pod, &corev1.Taint{Key: corev1.TaintNodeUnschedulable, Effect: corev1.TaintEffectNoSchedule}) {
+		reasons = append(reasons, unfit{reasonUnschedulable, "node is cordoned"})
+	}
+
+	for i := range node.Spec.Taints {
+		taint := &node.Spec.Taints[i]
+		if taint.Effect == corev1.TaintEffectPreferNoSchedule || tolerates(pod, taint) {
+			continue
+		}
+		reasons = append(reasons, unfit{
+			fmt.Sprintf(reasonUntoleratedTaint, taint.Key, taint.Value),
+			fmt.Sprintf("untolerated taint %s", taint.ToString()),
+		})
+	}
+
+	if len(pod.Spec.NodeSelector) > 0 && !labels.SelectorFromSet(pod.Spec.NodeSelector).Matches(labels.Set(node.Labels)) {
+		reasons = append(reasons, unfit{reasonNodeAffinity, fmt.Sprintf("node labels don't match nodeSelector %s", labels.Set(pod.Spec.NodeSelector))})
+	}
+	if affinity := pod.Spec.Affinity; affinity != nil && affinity.NodeAffinity != nil {
+		if required := affinity.NodeAffinity.RequiredDuringSchedulingIgnoredDuringExecution; required != nil && !matchesNodeSelector(required, node) {
+			reasons = append(reasons, unfit{reasonNodeAffinity, "node doesn't match the required node affinity"})
+		}
+	}
+	return reasons
+}
+
+// checkResources reports the resources requested by the pod that the node has not available
+func checkResources(requests corev1.ResourceList, allocatable, available corev1.ResourceList) []unfit {
+	var reasons []unfit
+	if pods, ok := available[corev1.ResourcePods]; ok && pods.Value() < 1 {
+		reasons = append(reasons, unfit{reasonTooManyPods, fmt.Sprintf("too many pods: %s allocatable", quantityString(allocatable, corev1.ResourcePods))})
+	}
+
+	for _, name := range sortedNames(requests) {
+		request := requests[name]
+		if request.IsZero() || name == corev1.ResourcePods {
+			continue
+		}
+		if free, ok := available[name]; ok && request.Cmp(free) <= 0 {
+			continue
+		}
+		reasons = append(reasons, unfit{
+			fmt.Sprintf(reasonInsufficient, name),
+			fmt.Sprintf("insufficient %s: requested %s, available %s of %s allocatable", name, request.String(),
+				quantityString(available, name), quantityString(allocatable, name)),
+		})
+	}
+	return reasons
+}
+
+// tolerates returns true when a toleration of the pod tolerates taint
+func tolerates(pod *corev1.Pod, taint *corev1.Taint) bool {
+	for i := range pod.Spec.Tolerations {
+		if pod.Spec.Tolerations[i].ToleratesTaint(taint) {
+			return true
+		}
+	}
+	return false
+}
+
+// matchesNodeSelector returns true when node matches one of the terms of selector
+func matchesNodeSelector(selector *corev1.NodeSelector, node *corev1.Node) bool {
+	for _, term := range selector.NodeSelectorTerms {
+		if matchesNodeSelectorTerm(term, node) {
+			return true
+		}
+	}
+	return false
+}
+
+// matchesNodeSelectorTerm returns true when node matches all the requirements of term, an empty term matches nothing
+func matchesNodeSelectorTerm(term corev1.NodeSelectorTerm, node *corev1.Node) bool {
+	if len(term.MatchExpressions) == 0 && len(term.MatchFields) == 0 {
+		return false
+	}
+	if !matchesRequirements(term.MatchExpressions, labels.Set(node.Labels)) {
+		return false
+	}
+	// metadata.name is the only supported field, with the In and NotIn operators
+	for _, field := range term.MatchFields {
+		if field.Key != "metadata.name" || len(field.Values) != 1 {
+			return false
+		}
+		switch field.Operator {
+		case corev1.NodeSelectorOpIn:
+			if field.Values[0] != node.Name {
+				return false
+			}
+		case corev1.NodeSelectorOpNotIn:
+			if field.Values[0] == node.Name {
+				return false
+			}
+		default:
+			return false
+		}
+	}
+	return true
+}
+
+// matchesRequirements returns true when set matches every requirement, invalid requirements match nothing
+func matchesRequirements(requirements []corev1.NodeSelectorRequirement, set labels.Set) bool {
+	for _, requirement := range requirements {
+		operator, ok := nodeSelectorOperators[requirement.Operator]
+		if !ok {
+			return false
+		}
+		if operator == selection.GreaterThan || operator == selection.LessThan {
+			// label selectors require integer values
+			if len(requirement.Values) != 1 {
+				return false
+			}
+			if _, err := strconv.ParseInt(requirement.Values[0], 10, 64); err != nil {
+				return false
+			}
+		}
+		parsed, err := labels.NewRequirement(requirement.Key, operator, requirement.Values)
+		if err != nil || !parsed.Matches(set) {
+			return false
+		}
+	}
+	return true
+}
+
+// quantityString returns the quantity of name in list, 0 when it has none
+func quantityString(list corev1.ResourceList, name corev1.ResourceName) string {
+	quantity, ok := list[name]
+	if !ok {
+		return "0"
+	}
+	return quantity.String()
+}
+
+// summarize aggregates the reasons of every node like the scheduler, e.g. 0/3 nodes are available: 3 Insufficient cpu.
+func summarize(nodes int, reasons [][]unfit) string {
+	counts := map[string]int{}
+	for _, nodeReasons := range reasons {
+		seen := map[string]bool{}
+		for _, reason := range nodeReasons {
+			if !seen[reason.reason] {
+				seen[reason.reason] = true
+				counts[reason.reason]++
+			}
+		}
+	}
+	parts := make([]string, 0, len(counts))
+	for reason, count := range counts {
+		parts = append(parts, fmt.Sprintf("%d %s", count, reason))
+	}
+	sort.Strings(parts)
+	if len(parts) == 0 {
+		return fmt.Sprintf("0/%d nodes are available.", nodes)
+	}
+	return fmt.Sprintf("0/%d nodes are available: %s.", nodes, strings.Join(parts, ", "))
+}
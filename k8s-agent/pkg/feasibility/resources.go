@@ -0,0 +1,110 @@
+package feasibility
+
+import (
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+)
+
+// isSidecar returns true for init containers that keep running along the containers of the pod
+func isSidecar(container *corev1.Container) bool {
+	return container.RestartPolicy != nil && *container.RestartPolicy == corev1.ContainerRestartPolicyAlways
+}
+
+// requests and limits pick the resources of a container
+func requests(resources corev1.ResourceRequirements) corev1.ResourceList { return resources.Requests }
+func limits(resources corev1.ResourceRequirements) corev1.ResourceList   { return resources.Limits }
+
+// podResources returns the requests or limits of a pod the way the scheduler computes them: the largest of
+// the containers with the sidecars and of each init container with the sidecars started before it, plus the overhead
+func podResources(spec *corev1.PodSpec, pick func(corev1.ResourceRequirements) corev1.ResourceList) corev1.ResourceList {
+	total := corev1.ResourceList{}
+	for _, container := range spec.Containers {
+		addResources(total, pick(container.Resources))
+	}
+
+	sidecars := corev1.ResourceList{}
+	initPeak := corev1.ResourceList{}
+	for i := range spec.InitContainers {
+		container := &spec.InitContainers[i]
+		if isSidecar(container) {
+			addResources(sidecars, pick(container.Resources))
+			continue
+		}
+		running := sidecars.DeepCopy()
+		addResources(running, pick(container.Resources))
+		maxResources(initPeak, running)
+	}
+	addResources(total, sidecars)
+	maxResources(total, initPeak)
+	addResources(total, spec.Overhead)
+	return total
+}
+
+// applyDefaults defaults the resources of the containers like the API server and the LimitRanger admission
+// plugin do: missing requests are set to the limits, then missing limits and requests to the LimitRange defaults
+func applyDefaults(spec *corev1.PodSpec, limitRanges []corev1.LimitRange) {
+	for _, containers := range [][]corev1.Container{spec.InitContainers, spec.Containers} {
+		for i := range containers {
+			resources := &containers[i].Resources
+			setMissing(&resources.Requests, resources.Limits)
+			for _, limitRange := range limitRanges {
+				for _, item := range limitRange.Spec.Limits {
+					if item.Type != corev1.LimitTypeContainer {
+						continue
+					}
+					setMissing(&resources.Limits, item.Default)
+					setMissing(&resources.Requests, item.DefaultRequest)
+				}
+			}
+		}
+	}
+}
+
+// setMissing copies the resources of defaults that list does not set
+func setMissing(list *corev1.ResourceList, defaults corev1.ResourceList) {
+	for name, quantity := range defaults {
+		if _, ok := (*list)[name]; ok {
+			continue
+		}
+		if *list == nil {
+			*list = corev1.ResourceList{}
+		}
+		(*list)[name] = quantity.DeepCopy()
+	}
+}
+
+// addResources adds the resources of src to dst
+func addResources(dst, src corev1.ResourceList) {
+	for name, quantity := range src {
+		total := dst[name]
+		total.Add(quantity)
+		dst[name] = total
+	}
+}
+
+// maxResources raises the resources of dst to the ones of src
+func maxResources(dst, src corev1.ResourceList) {
+	for name, quantity := range src {
+		if current, ok := dst[name]; !ok || quantity.Cmp(current) > 0 {
+			dst[name] = quantity.DeepCopy()
+		}
+	}
+}
+
+// minResource lowers the resource of dst to quantity, setting it when dst has none
+func minResource(dst corev1.ResourceList, name corev1.ResourceName, quantity resource.Quantity) {
+	if current, ok := dst[name]; !ok || quantity.Cmp(current) < 0 {
+		dst[name] = quantity.DeepCopy()
+	}
+}
+
+// nonZero returns the resources of list that are not zero
+func nonZero(list corev1.ResourceList) corev1.ResourceList {
+	result := corev1.ResourceList{}
+	for name, quantity := range list {
+		if !quantity.IsZero() {
+			result[name] = quantity
+		}
+	}
+	return result
+}
@@ -0,0 +1,90 @@
+package leader
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httputil"
+	"net/url"
+
+	"github.com/Sanskarzz/k8sgptclient/k8s-agent/pkg/server"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+)
+
+// ForwardedByHeader carries the identity of the agent that forwarded a request to the leader
+const ForwardedByHeader = "X-K8s-Agent-Forwarded-By"
+
+// Forwarder serves requests on the leader and forwards them to the leader on the other agents
+type Forwarder struct {
+	elector *Elector
+	// scheme is the scheme of the leader address, http or https
+	scheme    string
+	transport http.RoundTripper
+}
+
+// NewForwarder creates a Forwarder sending requests to the leader with the given scheme and transport
+func NewForwarder(elector *Elector, scheme string, transport http.RoundTripper) *Forwarder {
+	return &Forwarder{
+		elector:   elector,
+		scheme:    scheme,
+		transport: transport,
+	}
+}
+
+// Wrap serves requests with next on the leader and forwards them to the leader otherwise.
+// The caller credentials are forwarded, the leader authenticates and authorizes the request again.
+func (f *Forwarder) Wrap(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		logger := log.FromContext(r.Context()).WithName("leader")
+
+		if f.elector.IsLeader() {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		// never forward twice, the sender saw this agent as the leader while the leadership is changing
+		if by := r.Header.Get(ForwardedByHeader); by != "" {
+			logger.Info("Received forwarded request but not the leader", "from", by)
+			w.Header().Set("Retry-After", "1")
+			server.WriteError(w, r, http.StatusServiceUnavailable, "Not the leader, leader election in progress")
+			return
+		}
+
+		// the leader is unknown when the Lease can't be read, like while no leader is elected
+		holder, address, err := f.elector.Leader(r.Context())
+		if err != nil {
+			logger.Error(err, "Failed to get leader")
+			w.Header().Set("Retry-After", "5")
+			server.WriteError(w, r, http.StatusServiceUnavailable, "Failed to get leader, leader election in progress")
+			return
+		}
+		if address == "" {
+			logger.Info("No leader to forward request to", "holder", holder)
+			w.Header().Set("Retry-After", "5")
+			server.WriteError(w, r, http.StatusServiceUnavailable, "No leader elected, leader election in progress")
+			return
+		}
+
+		logger.V(1).Info("Forwarding request to leader", "leader", holder)
+		proxy := &httputil.ReverseProxy{
+			Rewrite: func(pr *httputil.ProxyRequest) {
+				pr.SetURL(&url.URL{Scheme: f.scheme, Host: address})
+				pr.SetXForwarded()
+				pr.Out.Header.Set(ForwardedByHeader, f.elector.Identity())
+				// keep the request ID of this agent so that both logs correlate
+				pr.Out.Header.Set(server.RequestIDHeader, server.RequestIDFromContext(r.Context()))
+			},
+			Transport: f.transport,
+			ModifyResponse: func(resp *http.Response) error {
+				// the request ID header is already set by this agent
+				resp.Header.Del(server.RequestIDHeader)
+				return nil
+			},
+			ErrorHandler: func(w http.ResponseWriter, r *http.Request, err error) {
+				logger.Error(err, "Failed to forward request to leader", "leader", holder)
+				w.Header().Set("Retry-After", "1")
+				server.WriteError(w, r, http.StatusBadGateway, fmt.Sprintf("Failed to forward request to leader %s", holder))
+			},
+		}
+		proxy.ServeHTTP(w, r)
+	})
+}
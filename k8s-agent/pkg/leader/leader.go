@@ -0,0 +1,114 @@
+package leader
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/tools/leaderelection/resourcelock"
+)
+
+// recordTTL is how long the leader read from the Lease is reused, so that forwarded requests don't all read it
+const recordTTL = time.Second
+
+// Identity returns the leader election identity of an agent, it carries the address other agents forward to
+func Identity(hostname, address string) string {
+	return hostname + "_" + address
+}
+
+// address returns the address carried by an identity, empty if it has none
+func address(identity string) string {
+	index := strings.LastIndex(identity, "_")
+	if index < 0 {
+		return ""
+	}
+	return identity[index+1:]
+}
+
+// NewLock creates the Lease lock the agents are elected with
+func NewLock(clientset kubernetes.Interface, namespace, name, identity string) (resourcelock.Interface, error) {
+	return resourcelock.New(resourcelock.LeasesResourceLock, namespace, name,
+		clientset.CoreV1(), clientset.CoordinationV1(),
+		resourcelock.ResourceLockConfig{Identity: identity},
+	)
+}
+
+// Elector tells whether this agent is the leader and where the leader is
+type Elector struct {
+	clientset kubernetes.Interface
+	namespace string
+	name      string
+	identity  string
+	elected   <-chan struct{}
+
+	mu       sync.Mutex
+	holder   string
+	readTime time.Time
+}
+
+// NewElector creates an Elector for the Lease namespace/name. elected is closed once this agent,
+// running with identity, is elected, usually the manager Elected channel.
+func NewElector(clientset kubernetes.Interface, namespace, name, identity string, elected <-chan struct{}) *Elector {
+	return &Elector{
+		clientset: clientset,
+		namespace: namespace,
+		name:      name,
+		identity:  identity,
+		elected:   elected,
+	}
+}
+
+// Identity returns the identity of this agent
+func (e *Elector) Identity() string {
+	return e.identity
+}
+
+// IsLeader returns true once this agent is elected. The manager stops when the leadership is lost,
+// so an elected agent stays the leader until it exits.
+func (e *Elector) IsLeader() bool {
+	select {
+	case <-e.elected:
+		return true
+	default:
+		return false
+	}
+}
+
+// Leader returns the identity and address of the current leader, empty when there is no leader,
+// e.g. while the Lease of a leader that went away expires
+func (e *Elector) Leader(ctx context.Context) (string, string, error) {
+	if e.IsLeader() {
+		return e.identity, address(e.identity), nil
+	}
+
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if time.Since(e.readTime) < recordTTL {
+		return e.holder, address(e.holder), nil
+	}
+
+	lease, err := e.clientset.CoordinationV1().Leases(e.namespace).Get(ctx, e.name, metav1.GetOptions{})
+	if err != nil {
+		if !apierrors.IsNotFound(err) {
+			return "", "", fmt.Errorf("failed to get leader lease: %w", err)
+		}
+		// no agent was elected yet
+		e.holder, e.readTime = "", time.Now()
+		return "", "", nil
+	}
+
+	holder := ""
+	if lease.Spec.HolderIdentity != nil && lease.Spec.RenewTime != nil && lease.Spec.LeaseDurationSeconds != nil {
+		expiry := lease.Spec.RenewTime.Add(time.Duration(*lease.Spec.LeaseDurationSeconds) * time.Second)
+		if time.Now().Before(expiry) {
+			holder = *lease.Spec.HolderIdentity
+		}
+	}
+	e.holder, e.readTime = holder, time.Now()
+	return holder, address(holder), nil
+}
@@ -0,0 +1,77 @@
+package metrics
+
+import (
+	"net/http"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"sigs.k8s.io/controller-runtime/pkg/metrics"
+)
+
+// namespace prefixes every agent metric
+const namespace = "k8s_agent"
+
+var (
+	// RequestsTotal counts API requests by route, method and status code
+	RequestsTotal = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "http_requests_total",
+		Help:      "Total number of HTTP requests by route, method and status code.",
+	}, []string{"route", "method", "code"})
+
+	// RequestDuration observes API request latencies by route and method
+	RequestDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "http_request_duration_seconds",
+		Help:      "Latency of HTTP requests by route and method. Streaming routes last as long as the stream.",
+		Buckets:   []float64{0.005, 0.01, 0.025, 0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10, 30, 60},
+	}, []string{"route", "method"})
+
+	// AppliesTotal counts applied objects by kind, namespace and outcome (applied, dry-run, denied, failed, ...)
+	AppliesTotal = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "applies_total",
+		Help:      "Total number of objects submitted to /apply by kind, namespace and outcome.",
+	}, []string{"kind", "namespace", "outcome"})
+
+	// PolicyDenialsTotal counts policy violations by kind, namespace and rule
+	PolicyDenialsTotal = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "policy_denials_total",
+		Help:      "Total number of policy violations by kind, namespace and rule.",
+	}, []string{"kind", "namespace", "rule"})
+
+	// LogBytesTotal counts the bytes of logs streamed by source (pod or deployment)
+	LogBytesTotal = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "log_stream_bytes_total",
+		Help:      "Total number of log bytes streamed to clients by source.",
+	}, []string{"source"})
+
+	// Leader is 1 on the agent elected to serve mutating endpoints
+	Leader = prometheus.NewGauge(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "leader",
+		Help:      "Whether this agent is the elected leader serving mutating endpoints (1) or not (0).",
+	})
+)
+
+func init() {
+	// served by the controller-runtime metrics server
+	metrics.Registry.MustRegister(
+		RequestsTotal,
+		RequestDuration,
+		AppliesTotal,
+		PolicyDenialsTotal,
+		LogBytesTotal,
+		Leader,
+	)
+}
+
+// InstrumentRoute records the requests served by next under the given route
+func InstrumentRoute(route string, next http.Handler) http.Handler {
+	labels := prometheus.Labels{"route": route}
+	return promhttp.InstrumentHandlerDuration(RequestDuration.MustCurryWith(labels),
+		promhttp.InstrumentHandlerCounter(RequestsTotal.MustCurryWith(labels), next),
+	)
+}
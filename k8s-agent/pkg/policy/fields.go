@@ -0,0 +1,140 @@
+package policy
+
+import (
+	"fmt"
+	"strings"
+
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+// podSpecPaths maps workload kinds to the location of their pod spec
+var podSpecPaths = map[string][]string{
+	"Pod":                   {"spec"},
+	"Deployment":            {"spec", "template", "spec"},
+	"ReplicaSet":            {"spec", "template", "spec"},
+	"ReplicationController": {"spec", "template", "spec"},
+	"StatefulSet":           {"spec", "template", "spec"},
+	"DaemonSet":             {"spec", "template", "spec"},
+	"Job":                   {"spec", "template", "spec"},
+	"CronJob":               {"spec", "jobTemplate", "spec", "template", "spec"},
+}
+
+// containerFields are the pod spec fields holding containers
+var containerFields = []string{"initContainers", "containers", "ephemeralContainers"}
+
+// evaluateFields reports forbidden settings present in obj that were not already present in live,
+// so that applying an unrelated change to an existing object is not blocked. Findings are compared
+// by rule and message, which name the volume, container or service account involved.
+func (p *Policy) evaluateFields(obj, live *unstructured.Unstructured) []Violation {
+	var violations []Violation
+
+	if p.Forbid.NewServiceAccounts && obj.GetKind() == "ServiceAccount" && obj.GroupVersionKind().Group == "" && live == nil {
+		violations = append(violations, Violation{
+			Rule:    "forbid.newServiceAccounts",
+			Message: fmt.Sprintf("creating service account %q is forbidden", obj.GetName()),
+			Path:    "kind",
+		})
+	}
+
+	existing := map[string]bool{}
+	if live != nil {
+		for _, violation := range p.podSpecFindings(live) {
+			existing[violation.Rule+"|"+violation.Message] = true
+		}
+	}
+	for _, violation := range p.podSpecFindings(obj) {
+		if !existing[violation.Rule+"|"+violation.Message] {
+			violations = append(violations, violation)
+		}
+	}
+	return violations
+}
+
+// podSpecFindings reports every forbidden setting in the object pod spec
+func (p *Policy) podSpecFindings(obj *unstructured.Unstructured) []Violation {
+	specPath, ok := podSpecPaths[obj.GetKind()]
+	if !ok {
+		return nil
+	}
+	spec, found, err := unstructured.NestedMap(obj.Object, specPath...)
+	if err != nil || !found {
+		return nil
+	}
+	prefix := strings.Join(specPath, ".")
+
+	var findings []Violation
+	hostFlags := []struct {
+		enabled bool
+		field   string
+	}{
+		{p.Forbid.HostNetwork, "hostNetwork"},
+		{p.Forbid.HostPID, "hostPID"},
+		{p.Forbid.HostIPC, "hostIPC"},
+	}
+	for _, flag := range hostFlags {
+		if !flag.enabled {
+			continue
+		}
+		if value, _, _ := unstructured.NestedBool(spec, flag.field); value {
+			findings = append(findings, Violation{
+				Rule:    "forbid." + flag.field,
+				Message: fmt.Sprintf("setting %s is forbidden", flag.field),
+				Path:    prefix + "." + flag.field,
+			})
+		}
+	}
+
+	if p.Forbid.HostPath {
+		volumes, _, _ := unstructured.NestedSlice(spec, "volumes")
+		for i, volume := range volumes {
+			volumeMap, ok := volume.(map[string]interface{})
+			if !ok {
+				continue
+			}
+			if _, found := volumeMap["hostPath"]; found {
+				findings = append(findings, Violation{
+					Rule:    "forbid.hostPath",
+					Message: fmt.Sprintf("hostPath volume %q is forbidden", volumeMap["name"]),
+					Path:    fmt.Sprintf("%s.volumes[%d].hostPath", prefix, i),
+				})
+			}
+		}
+	}
+
+	if p.Forbid.Privileged {
+		for _, field := range containerFields {
+			containers, _, _ := unstructured.NestedSlice(spec, field)
+			for i, container := range containers {
+				containerMap, ok := container.(map[string]interface{})
+				if !ok {
+					continue
+				}
+				if privileged, _, _ := unstructured.NestedBool(containerMap, "securityContext", "privileged"); privileged {
+					findings = append(findings, Violation{
+						Rule:    "forbid.privileged",
+						Message: fmt.Sprintf("privileged container %q is forbidden", containerMap["name"]),
+						Path:    fmt.Sprintf("%s.%s[%d].securityContext.privileged", prefix, field, i),
+					})
+				}
+			}
+		}
+	}
+
+	if p.Forbid.NewServiceAccounts {
+		// serviceAccount is the deprecated alias of serviceAccountName, which the API server fills from it
+		reported := map[string]bool{}
+		for _, field := range []string{"serviceAccountName", "serviceAccount"} {
+			serviceAccount, _, _ := unstructured.NestedString(spec, field)
+			if serviceAccount != "" && serviceAccount != "default" && !reported[serviceAccount] {
+				reported[serviceAccount] = true
+				findings = append(findings, Violation{
+					Rule:    "forbid.newServiceAccounts",
+					Message: fmt.Sprintf("running as service account %q is forbidden", serviceAccount),
+					Path:    prefix + "." + field,
+				})
+			}
+		}
+	}
+
+	return findings
+}
@@ -0,0 +1,176 @@
+package policy
+
+import (
+	"fmt"
+	"os"
+	"path"
+
+	"github.com/Sanskarzz/k8sgptclient/k8s-agent/api"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"sigs.k8s.io/yaml"
+)
+
+// Policy restricts what the agent is allowed to apply
+type Policy struct {
+	// RequireNamespace denies namespaced objects without a namespace instead of defaulting them to "default"
+	RequireNamespace bool `json:"requireNamespace,omitempty"`
+	// Namespaces restricts the namespaces objects can be applied to
+	Namespaces NamespaceRules `json:"namespaces,omitempty"`
+	// Kinds restricts the group/version/kinds that can be applied
+	Kinds KindRules `json:"kinds,omitempty"`
+	// Forbid lists field changes that are never allowed
+	Forbid ForbiddenFields `json:"forbid,omitempty"`
+}
+
+// NamespaceRules holds namespace glob patterns, deny takes precedence over allow.
+// An empty allow list allows every namespace.
+type NamespaceRules struct {
+	Allow []string `json:"allow,omitempty"`
+	Deny  []string `json:"deny,omitempty"`
+}
+
+// KindRules holds group/version/kind patterns, deny takes precedence over allow.
+// An empty allow list allows every kind.
+type KindRules struct {
+	Allow []GVKPattern `json:"allow,omitempty"`
+	Deny  []GVKPattern `json:"deny,omitempty"`
+}
+
+// GVKPattern matches a group/version/kind, each field is a glob pattern and an empty field matches anything
+// except for Group where "" is the core group. Use "*" to match any group.
+type GVKPattern struct {
+	Group   string `json:"group"`
+	Version string `json:"version,omitempty"`
+	Kind    string `json:"kind,omitempty"`
+}
+
+func (p GVKPattern) String() string {
+	return fmt.Sprintf("%s/%s/%s", p.Group, orAny(p.Version), orAny(p.Kind))
+}
+
+// ForbiddenFields lists pod spec settings that can't be introduced by an apply
+type ForbiddenFields struct {
+	Privileged         bool `json:"privileged,omitempty"`
+	HostNetwork        bool `json:"hostNetwork,omitempty"`
+	HostPID            bool `json:"hostPID,omitempty"`
+	HostIPC            bool `json:"hostIPC,omitempty"`
+	HostPath           bool `json:"hostPath,omitempty"`
+	NewServiceAccounts bool `json:"newServiceAccounts,omitempty"`
+}
+
+// Violation describes a policy rule hit by an object, it is part of the apply response
+type Violation = api.Violation
+
+// Load reads a policy file
+func Load(file string) (*Policy, error) {
+	data, err := os.ReadFile(file)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read policy file: %w", err)
+	}
+	var policy Policy
+	if err := yaml.UnmarshalStrict(data, &policy); err != nil {
+		return nil, fmt.Errorf("failed to parse policy file: %w", err)
+	}
+	// Validate patterns up front so a typo doesn't silently disable a rule
+	for _, pattern := range append(policy.Namespaces.Allow, policy.Namespaces.Deny...) {
+		if _, err := path.Match(pattern, ""); err != nil {
+			return nil, fmt.Errorf("invalid namespace pattern %q: %w", pattern, err)
+		}
+	}
+	for _, pattern := range append(policy.Kinds.Allow, policy.Kinds.Deny...) {
+		for _, field := range []string{pattern.Group, pattern.Version, pattern.Kind} {
+			if _, err := path.Match(field, ""); err != nil {
+				return nil, fmt.Errorf("invalid kind pattern %s: %w", pattern, err)
+			}
+		}
+	}
+	return &policy, nil
+}
+
+// Evaluate returns the rules violated by applying obj over live. live is nil when the object does not exist yet.
+// A nil policy allows everything.
+func (p *Policy) Evaluate(obj, live *unstructured.Unstructured) []Violation {
+	if p == nil {
+		return nil
+	}
+	var violations []Violation
+	violations = append(violations, p.evaluateNamespace(obj)...)
+	violations = append(violations, p.evaluateKind(obj)...)
+	violations = append(violations, p.evaluateFields(obj, live)...)
+	return violations
+}
+
+func (p *Policy) evaluateNamespace(obj *unstructured.Unstructured) []Violation {
+	namespace := obj.GetNamespace()
+	// cluster scoped objects are restricted through kind rules
+	if namespace == "" {
+		return nil
+	}
+	for _, pattern := range p.Namespaces.Deny {
+		if match(pattern, namespace) {
+			return []Violation{{
+				Rule:    "namespaces.deny",
+				Message: fmt.Sprintf("namespace %q matches denied pattern %q", namespace, pattern),
+				Path:    "metadata.namespace",
+			}}
+		}
+	}
+	if len(p.Namespaces.Allow) == 0 {
+		return nil
+	}
+	for _, pattern := range p.Namespaces.Allow {
+		if match(pattern, namespace) {
+			return nil
+		}
+	}
+	return []Violation{{
+		Rule:    "namespaces.allow",
+		Message: fmt.Sprintf("namespace %q is not in the allowed namespaces %v", namespace, p.Namespaces.Allow),
+		Path:    "metadata.namespace",
+	}}
+}
+
+func (p *Policy) evaluateKind(obj *unstructured.Unstructured) []Violation {
+	gvk := obj.GroupVersionKind()
+	name := fmt.Sprintf("%s %s", obj.GetAPIVersion(), gvk.Kind)
+	matches := func(pattern GVKPattern) bool {
+		return match(pattern.Group, gvk.Group) &&
+			match(orAny(pattern.Version), gvk.Version) &&
+			match(orAny(pattern.Kind), gvk.Kind)
+	}
+	for _, pattern := range p.Kinds.Deny {
+		if matches(pattern) {
+			return []Violation{{
+				Rule:    "kinds.deny",
+				Message: fmt.Sprintf("%s matches denied kind %s", name, pattern),
+				Path:    "kind",
+			}}
+		}
+	}
+	if len(p.Kinds.Allow) == 0 {
+		return nil
+	}
+	for _, pattern := range p.Kinds.Allow {
+		if matches(pattern) {
+			return nil
+		}
+	}
+	return []Violation{{
+		Rule:    "kinds.allow",
+		Message: fmt.Sprintf("%s is not an allowed kind", name),
+		Path:    "kind",
+	}}
+}
+
+// match matches a value against a glob pattern, invalid patterns are rejected by Load
+func match(pattern, value string) bool {
+	matched, _ := path.Match(pattern, value)
+	return matched
+}
+
+func orAny(pattern string) string {
+	if pattern == "" {
+		return "*"
+	}
+	return pattern
+}
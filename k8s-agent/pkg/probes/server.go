@@ -3,24 +3,100 @@ package probes
 import (
 	"context"
 	"net/http"
+	"strings"
 
+	"github.com/Sanskarzz/k8sgptclient/k8s-agent/pkg/auth"
+	"github.com/Sanskarzz/k8sgptclient/k8s-agent/pkg/leader"
+	"github.com/Sanskarzz/k8sgptclient/k8s-agent/pkg/metrics"
 	"github.com/Sanskarzz/k8sgptclient/k8s-agent/pkg/server"
 	"github.com/Sanskarzz/k8sgptclient/k8s-agent/pkg/server/handlers"
 	ctrl "sigs.k8s.io/controller-runtime"
 	"sigs.k8s.io/controller-runtime/pkg/log"
 )
 
-func NewServer(addr string, mgr ctrl.Manager) server.ServerFunc {
+// Options configures how the agent http server is exposed
+type Options struct {
+	// TLS configures https serving, API endpoints also require a client certificate when it has a client CA
+	TLS server.TLSOptions
+	// Authenticator validates bearer tokens, nil serves API endpoints without authentication
+	Authenticator auth.Authenticator
+	// Authorizer decides which callers can access read and write endpoints
+	Authorizer *auth.Authorizer
+	// Elector tells whether a leader is elected, nil when leader election is disabled
+	Elector *leader.Elector
+	// Forwarder forwards mutating requests to the leader, nil serves them locally
+	Forwarder *leader.Forwarder
+}
+
+// NewServer creates the agent http server serving the API endpoints of the given handler
+func NewServer(addr string, mgr ctrl.Manager, handler *handlers.ClientHandler, opts Options) server.ServerFunc {
 	return func(ctx context.Context) error {
 		logger := log.FromContext(ctx).WithName("probes")
 
+		// protect wraps API endpoints with client certificate verification, authentication and authorization
+		protect := func(level auth.Level, handler http.Handler) http.Handler {
+			if opts.Authenticator != nil {
+				handler = auth.Middleware(opts.Authenticator, opts.Authorizer, level, handler)
+			}
+			if opts.TLS.MutualTLS() {
+				handler = server.RequireClientCert(handler)
+			}
+			return handler
+		}
+		if opts.Authenticator == nil {
+			logger.Info("Authentication is disabled, API endpoints are not protected")
+		}
+
+		// leaderOnly serves endpoints that mutate the cluster or read state kept by the leader (audit log, file
+		// snapshots) on the leader only, so that concurrent applies can't race
+		leaderOnly := func(handler http.Handler) http.Handler {
+			if opts.Forwarder == nil {
+				return handler
+			}
+			return opts.Forwarder.Wrap(handler)
+		}
+
+		// mutating serves mutating endpoints on the leader one request at a time, so that the live objects
+		// checked against the policy and diffed can't be changed by a concurrent request
+		mutations := make(chan struct{}, 1)
+		mutating := func(next http.Handler) http.Handler {
+			return leaderOnly(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				select {
+				case mutations <- struct{}{}:
+					defer func() { <-mutations }()
+				case <-r.Context().Done():
+					return
+				}
+				next.ServeHTTP(w, r)
+			}))
+		}
+
+		// stream closes watch streams on shutdown, graceful shutdown would otherwise wait for them until it times out
+		streamCtx, stopStreams := context.WithCancel(context.Background())
+		defer stopStreams()
+		stream := func(handler http.Handler) http.Handler {
+			return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				ctx, cancel := context.WithCancel(r.Context())
+				defer cancel()
+				stop := context.AfterFunc(streamCtx, cancel)
+				defer stop()
+				handler.ServeHTTP(w, r.WithContext(ctx))
+			})
+		}
+
 		// create mux
 		logger.Info("Creating new server mux")
 		mux := http.NewServeMux()
+		// handle registers a route, recording its requests in the metrics
+		handle := func(pattern string, handler http.Handler) {
+			// the route label is the path pattern, without the method
+			route := pattern[strings.Index(pattern, " ")+1:]
+			mux.Handle(pattern, metrics.InstrumentRoute(route, handler))
+		}
 
 		// register health check that verifies manager health
 		logger.Info("Registering health check endpoint", "path", "/livez")
-		mux.Handle("GET /livez", handlers.Healthy(func() bool {
+		handle("GET /livez", handlers.Healthy(func() bool {
 			healthy := mgr.GetCache().WaitForCacheSync(ctx)
 			logger.V(1).Info("Health check executed",
 				"endpoint", "/livez",
@@ -31,7 +107,9 @@ func NewServer(addr string, mgr ctrl.Manager) server.ServerFunc {
 
 		// register ready check
 		logger.Info("Registering readiness check endpoint", "path", "/readyz")
-		mux.Handle("GET /readyz", handlers.Ready(func() bool {
+		handle("GET /readyz", handlers.Ready(func() bool {
+			// the leader is not checked: every replica serves reads and answers mutating requests,
+			// with 503 while no leader is elected, so that the Service keeps endpoints during a leader change
 			ready := mgr.GetCache().WaitForCacheSync(ctx)
 			logger.V(1).Info("Readiness check executed",
 				"endpoint", "/readyz",
@@ -41,37 +119,121 @@ func NewServer(addr string, mgr ctrl.Manager) server.ServerFunc {
 		}))
 
 		// API endpoints
+		// Returns the OpenAPI specification of the API.
+		logger.Info("Registering OpenAPI endpoint", "path", "/openapi.json")
+		handle("GET /openapi.json", protect(auth.Read, handlers.OpenAPI()))
+
 		// Accepts a YAML manifest and applies it to the cluster.
 		logger.Info("Registering apply endpoint", "path", "/apply")
-		mux.Handle("POST /apply", handlers.NewClientHandler(mgr.GetClient()).Apply())
+		handle("POST /apply", protect(auth.Write, mutating(handler.Apply())))
+
+		// Restores the objects of an apply to their state before the apply.
+		logger.Info("Registering rollback endpoint", "path", "/rollback/{revisionID}")
+		handle("POST /rollback/{revisionID}", protect(auth.Write, mutating(handler.Rollback())))
+
+		// Restarts, scales and evicts with the same policy and audit checks as apply.
+		logger.Info("Registering deployment restart endpoint", "path", "/deployments/{namespace}/{deploymentName}/restart")
+		handle("POST /deployments/{namespace}/{deploymentName}/restart", protect(auth.Write, mutating(handler.RestartDeployment())))
+		logger.Info("Registering deployment scale endpoint", "path", "/deployments/{namespace}/{deploymentName}/scale")
+		handle("POST /deployments/{namespace}/{deploymentName}/scale", protect(auth.Write, mutating(handler.ScaleDeployment())))
+		logger.Info("Registering pod delete endpoint", "path", "/pods/{namespace}/{podName}")
+		handle("DELETE /pods/{namespace}/{podName}", protect(auth.Write, mutating(handler.DeletePod())))
+
+		// Returns the audit log of mutations made through the agent.
+		logger.Info("Registering audit endpoint", "path", "/audit")
+		handle("GET /audit", protect(auth.Read, leaderOnly(handler.AuditLog())))
+
+		// Returns the events of an object and of the objects it owns.
+		logger.Info("Registering events endpoint", "path", "/events/{namespace}/{kind}/{name}")
+		handle("GET /events/{namespace}/{kind}/{name}", protect(auth.Read, handler.Events()))
+
+		// Returns any namespaced or cluster scoped resource, including custom resources.
+		logger.Info("Registering resource endpoint", "path", "/resources/{group}/{version}/{resource}/{namespace}/{name}")
+		handle("GET /resources/{group}/{version}/{resource}/{namespace}/{name}", protect(auth.Read, handler.Resource()))
+		handle("GET /resources/{group}/{version}/{resource}/{name}", protect(auth.Read, handler.Resource()))
+
+		// Patches any namespaced or cluster scoped resource with a JSON, merge or strategic merge patch.
+		logger.Info("Registering patch resource endpoint", "path", "/resources/{group}/{version}/{resource}/{namespace}/{name}")
+		handle("PATCH /resources/{group}/{version}/{resource}/{namespace}/{name}", protect(auth.Write, mutating(handler.PatchResource())))
+		handle("PATCH /resources/{group}/{version}/{resource}/{name}", protect(auth.Write, mutating(handler.PatchResource())))
+
+		// Reports which nodes could run the pods of a manifest and the namespace limits they exceed.
+		logger.Info("Registering feasibility endpoint", "path", "/feasibility")
+		handle("POST /feasibility", protect(auth.Read, handler.Feasibility()))
+
+		// Reports whether the images of a manifest exist in their registries, with the closest tags of missing ones.
+		logger.Info("Registering image verification endpoint", "path", "/images/verify")
+		handle("POST /images/verify", protect(auth.Read, handler.VerifyImages()))
 
 		// Lists all pods in a specified namespace.
 		logger.Info("Registering pods list endpoint", "path", "/pods")
-		mux.Handle("GET /pods", handlers.NewClientHandler(mgr.GetClient()).ListPods())
+		handle("GET /pods", protect(auth.Read, handler.ListPods()))
 
 		// Streams logs for a specific pod.
 		logger.Info("Registering pod logs endpoint", "path", "/pods/{namespace}/{podName}/logs")
-		mux.Handle("GET /pods/{namespace}/{podName}/logs", handlers.NewClientHandler(mgr.GetClient()).PodLogs())
+		handle("GET /pods/{namespace}/{podName}/logs", protect(auth.Read, handler.PodLogs()))
 
 		// Returns the status of a specific pod. including readiness and liveness probe results.
 		logger.Info("Registering pod status endpoint", "path", "/pods/{namespace}/{podName}/status")
-		mux.Handle("GET /pods/{namespace}/{podName}/status", handlers.NewClientHandler(mgr.GetClient()).PodStatus())
+		handle("GET /pods/{namespace}/{podName}/status", protect(auth.Read, handler.PodStatus()))
+
+		// Streams the status of pods as server-sent events.
+		logger.Info("Registering pods watch endpoint", "path", "/watch/pods")
+		handle("GET /watch/pods", protect(auth.Read, stream(handler.WatchPods())))
+
+		// Streams the rollout status of a workload as server-sent events.
+		for _, resource := range []string{"deployments", "statefulsets", "daemonsets", "jobs", "cronjobs"} {
+			path := "/watch/" + resource + "/{namespace}/{name}"
+			logger.Info("Registering workload watch endpoint", "path", path)
+			handle("GET "+path, protect(auth.Read, stream(handler.WatchWorkload())))
+		}
 
 		// Get pod names for a deployment
 		logger.Info("Registering deployment pods endpoint", "path", "/deployments/{namespace}/{deploymentName}/pods")
-		mux.Handle("GET /deployments/{namespace}/{deploymentName}/pods", handlers.NewClientHandler(mgr.GetClient()).DeploymentPodNames())
+		handle("GET /deployments/{namespace}/{deploymentName}/pods", protect(auth.Read, handler.DeploymentPodNames()))
+
+		// Returns the rollout status of a deployment, as computed by kubectl rollout status.
+		logger.Info("Registering deployment status endpoint", "path", "/deployments/{namespace}/{deploymentName}/status")
+		handle("GET /deployments/{namespace}/{deploymentName}/status", protect(auth.Read, handler.DeploymentStatus()))
+
+		// Returns the rollout status of statefulsets and daemonsets, and the completion status of jobs and cronjobs.
+		for _, resource := range []string{"statefulsets", "daemonsets", "jobs", "cronjobs"} {
+			path := "/" + resource + "/{namespace}/{name}/status"
+			logger.Info("Registering workload status endpoint", "path", path)
+			handle("GET "+path, protect(auth.Read, handler.WorkloadStatus()))
+		}
+
+		// Streams the merged logs of all pods of a deployment.
+		logger.Info("Registering deployment logs endpoint", "path", "/deployments/{namespace}/{deploymentName}/logs")
+		handle("GET /deployments/{namespace}/{deploymentName}/logs", protect(auth.Read, handler.DeploymentLogs()))
 
 		// Get specific deployment yaml
 		logger.Info("Registering deployment json endpoint", "path", "/deployment/{namespace}/{deploymentName}/yaml")
-		mux.Handle("GET /deployments/{namespace}/{deploymentName}/yaml", handlers.NewClientHandler(mgr.GetClient()).DeploymentYaml())
+		handle("GET /deployments/{namespace}/{deploymentName}/yaml", protect(auth.Read, handler.DeploymentYaml()))
 
 		// Get specific pod yaml
 		logger.Info("Registering pod json endpoint", "path", "/pod/{namespace}/{podName}/yaml")
-		mux.Handle("GET /pods/{namespace}/{podName}/yaml", handlers.NewClientHandler(mgr.GetClient()).PodYaml())
+		handle("GET /pods/{namespace}/{podName}/yaml", protect(auth.Read, handler.PodYaml()))
 		// create server
 		s := &http.Server{
 			Addr:    addr,
-			Handler: mux,
+			Handler: server.WithRequestID(mux),
+		}
+		s.RegisterOnShutdown(stopStreams)
+
+		// serve over https when a certificate is configured
+		if opts.TLS.Enabled() {
+			logger.Info("Configuring TLS",
+				"certFile", opts.TLS.CertFile,
+				"keyFile", opts.TLS.KeyFile,
+				"clientCAFile", opts.TLS.ClientCAFile,
+			)
+			tlsConfig, err := server.NewTLSConfig(ctx, opts.TLS)
+			if err != nil {
+				logger.Error(err, "Failed to configure TLS")
+				return err
+			}
+			s.TLSConfig = tlsConfig
 		}
 
 		// run server
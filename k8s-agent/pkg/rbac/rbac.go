@@ -0,0 +1,285 @@
+package rbac
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+
+	rbacv1 "k8s.io/api/rbac/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/validation"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// allVerbs are the verbs of the resources the agent reads and changes
+var allVerbs = []string{"get", "list", "watch", "create", "update", "patch", "delete"}
+
+// ResourceRules are the permissions the agent needs on the objects it serves, granted cluster wide
+// or in each served namespace
+var ResourceRules = []rbacv1.PolicyRule{
+	{
+		APIGroups: []string{""},
+		Resources: []string{"pods", "pods/log", "pods/status"},
+		Verbs:     allVerbs,
+	},
+	// For deleting pods through the eviction API, which honours PodDisruptionBudgets
+	{
+		APIGroups: []string{""},
+		Resources: []string{"pods/eviction"},
+		Verbs:     []string{"create"},
+	},
+	{
+		APIGroups: []string{"apps"},
+		Resources: []string{"deployments", "statefulsets", "daemonsets"},
+		Verbs:     allVerbs,
+	},
+	{
+		APIGroups: []string{"batch"},
+		Resources: []string{"jobs", "cronjobs"},
+		Verbs:     allVerbs,
+	},
+	// For collecting the events of objects and their owned children
+	{
+		APIGroups: []string{"apps"},
+		Resources: []string{"replicasets"},
+		Verbs:     []string{"get", "list", "watch"},
+	},
+	{
+		APIGroups: []string{""},
+		Resources: []string{"events"},
+		Verbs:     []string{"get", "list"},
+	},
+	// For checking pods against the limits of their namespace
+	{
+		APIGroups: []string{""},
+		Resources: []string{"limitranges", "resourcequotas"},
+		Verbs:     []string{"get", "list"},
+	},
+	// For verifying images with the image pull secrets of the service accounts of pods, the secrets themselves
+	// are granted by ImagePullSecretRules
+	{
+		APIGroups: []string{""},
+		Resources: []string{"serviceaccounts"},
+		Verbs:     []string{"get"},
+	},
+}
+
+// Allows reports whether the rules grant verb on every object of the resource of group
+func Allows(rules []rbacv1.PolicyRule, group, resource, verb string) bool {
+	for _, rule := range rules {
+		if len(rule.ResourceNames) == 0 && slices.Contains(rule.APIGroups, group) &&
+			slices.Contains(rule.Resources, resource) && slices.Contains(rule.Verbs, verb) {
+			return true
+		}
+	}
+	return false
+}
+
+// Resources lists the resources the rules grant verb on, as resource.group, subresources excepted
+func Resources(rules []rbacv1.PolicyRule, verb string) []string {
+	var resources []string
+	for _, rule := range rules {
+		if len(rule.ResourceNames) > 0 || !slices.Contains(rule.Verbs, verb) {
+			continue
+		}
+		for _, group := range rule.APIGroups {
+			for _, resource := range rule.Resources {
+				if strings.Contains(resource, "/") {
+					continue
+				}
+				if group != "" {
+					resource += "." + group
+				}
+				resources = append(resources, resource)
+			}
+		}
+	}
+	return resources
+}
+
+// ImagePullSecretRules are the permissions the agent needs to verify images with the named image pull secrets,
+// granted along ResourceRules. Other secrets are never read, nil is returned without names.
+func ImagePullSecretRules(names []string) []rbacv1.PolicyRule {
+	if len(names) == 0 {
+		return nil
+	}
+	return []rbacv1.PolicyRule{{
+		APIGroups:     []string{""},
+		Resources:     []string{"secrets"},
+		ResourceNames: names,
+		Verbs:         []string{"get"},
+	}}
+}
+
+// SchedulingRules are the cluster wide permissions the agent needs to check whether pods fit the nodes:
+// the nodes and the pods bound to them, in every namespace
+var SchedulingRules = []rbacv1.PolicyRule{
+	{
+		APIGroups: []string{""},
+		Resources: []string{"nodes"},
+		Verbs:     []string{"get", "list"},
+	},
+	{
+		APIGroups: []string{""},
+		Resources: []string{"pods"},
+		Verbs:     []string{"list"},
+	},
+}
+
+// AuthRules are the cluster wide permissions the agent needs to authenticate API callers
+var AuthRules = []rbacv1.PolicyRule{
+	{
+		APIGroups: []string{"authentication.k8s.io"},
+		Resources: []string{"tokenreviews"},
+		Verbs:     []string{"create"},
+	},
+}
+
+// SnapshotRules are the permissions the configmap snapshot store needs in the agent namespace,
+// revisions holding Secrets are stored as Secrets
+var SnapshotRules = []rbacv1.PolicyRule{
+	{
+		APIGroups: []string{""},
+		Resources: []string{"configmaps", "secrets"},
+		Verbs:     []string{"get", "list", "create", "delete"},
+	},
+}
+
+// ReplicaRules are the permissions the agent replicas need in the agent namespace to elect a leader with a Lease
+// and to share the redaction key Secret, which the first replica creates. An empty secret name leaves out the key.
+func ReplicaRules(redactionKeySecret string) []rbacv1.PolicyRule {
+	rules := []rbacv1.PolicyRule{{
+		APIGroups: []string{"coordination.k8s.io"},
+		Resources: []string{"leases"},
+		Verbs:     []string{"get", "create", "update"},
+	}}
+	if redactionKeySecret == "" {
+		return rules
+	}
+	return append(rules,
+		rbacv1.PolicyRule{
+			APIGroups: []string{""},
+			Resources: []string{"secrets"},
+			Verbs:     []string{"create"},
+		},
+		rbacv1.PolicyRule{
+			APIGroups:     []string{""},
+			Resources:     []string{"secrets"},
+			ResourceNames: []string{redactionKeySecret},
+			Verbs:         []string{"get"},
+		},
+	)
+}
+
+// Options configures the generated RBAC objects
+type Options struct {
+	// Name of the generated roles
+	Name string
+	// BindingName is the name of the generated bindings
+	BindingName string
+	// ServiceAccount and ServiceAccountNamespace identify the service account the agent runs as
+	ServiceAccount          string
+	ServiceAccountNamespace string
+	// Namespaces served by the agent, empty grants ResourceRules cluster wide
+	Namespaces []string
+	// Scheduling grants SchedulingRules, which the ResourceRules granted cluster wide already cover but for nodes
+	Scheduling bool
+	// ImagePullSecrets are the names of the image pull secrets the agent may read to verify images
+	ImagePullSecrets []string
+	// RedactionKeySecret is the name of the Secret of the agent namespace sharing the redaction key, empty when
+	// the key is not shared through a Secret
+	RedactionKeySecret string
+}
+
+// Objects returns the roles and bindings granting the agent its permissions: a ClusterRole with AuthRules
+// and SchedulingRules, ResourceRules in a Role of each served namespace, or in the ClusterRole when the agent
+// serves all namespaces, and the SnapshotRules and ReplicaRules Roles of the agent namespace
+func Objects(opts Options) []client.Object {
+	subjects := []rbacv1.Subject{{
+		Kind:      rbacv1.ServiceAccountKind,
+		Name:      opts.ServiceAccount,
+		Namespace: opts.ServiceAccountNamespace,
+	}}
+
+	resourceRules := append(append([]rbacv1.PolicyRule{}, ResourceRules...), ImagePullSecretRules(opts.ImagePullSecrets)...)
+	clusterRules := append([]rbacv1.PolicyRule{}, AuthRules...)
+	switch {
+	case len(opts.Namespaces) == 0:
+		clusterRules = append(clusterRules, resourceRules...)
+		if opts.Scheduling {
+			clusterRules = append(clusterRules, SchedulingRules[0])
+		}
+	case opts.Scheduling:
+		clusterRules = append(clusterRules, SchedulingRules...)
+	}
+	objects := []client.Object{
+		&rbacv1.ClusterRole{
+			TypeMeta:   metav1.TypeMeta{APIVersion: rbacv1.SchemeGroupVersion.String(), Kind: "ClusterRole"},
+			ObjectMeta: metav1.ObjectMeta{Name: opts.Name},
+			Rules:      clusterRules,
+		},
+		&rbacv1.ClusterRoleBinding{
+			TypeMeta:   metav1.TypeMeta{APIVersion: rbacv1.SchemeGroupVersion.String(), Kind: "ClusterRoleBinding"},
+			ObjectMeta: metav1.ObjectMeta{Name: opts.BindingName},
+			Subjects:   subjects,
+			RoleRef:    rbacv1.RoleRef{APIGroup: rbacv1.GroupName, Kind: "ClusterRole", Name: opts.Name},
+		},
+	}
+
+	for _, namespace := range opts.Namespaces {
+		objects = append(objects,
+			&rbacv1.Role{
+				TypeMeta:   metav1.TypeMeta{APIVersion: rbacv1.SchemeGroupVersion.String(), Kind: "Role"},
+				ObjectMeta: metav1.ObjectMeta{Name: opts.Name, Namespace: namespace},
+				Rules:      resourceRules,
+			},
+			&rbacv1.RoleBinding{
+				TypeMeta:   metav1.TypeMeta{APIVersion: rbacv1.SchemeGroupVersion.String(), Kind: "RoleBinding"},
+				ObjectMeta: metav1.ObjectMeta{Name: opts.BindingName, Namespace: namespace},
+				Subjects:   subjects,
+				RoleRef:    rbacv1.RoleRef{APIGroup: rbacv1.GroupName, Kind: "Role", Name: opts.Name},
+			},
+		)
+	}
+
+	// the agent namespace is the one of its service account
+	for _, role := range []struct {
+		name  string
+		rules []rbacv1.PolicyRule
+	}{
+		{name: opts.ServiceAccount + "-snapshots", rules: SnapshotRules},
+		{name: opts.ServiceAccount + "-replicas", rules: ReplicaRules(opts.RedactionKeySecret)},
+	} {
+		objects = append(objects,
+			&rbacv1.Role{
+				TypeMeta:   metav1.TypeMeta{APIVersion: rbacv1.SchemeGroupVersion.String(), Kind: "Role"},
+				ObjectMeta: metav1.ObjectMeta{Name: role.name, Namespace: opts.ServiceAccountNamespace},
+				Rules:      role.rules,
+			},
+			&rbacv1.RoleBinding{
+				TypeMeta:   metav1.TypeMeta{APIVersion: rbacv1.SchemeGroupVersion.String(), Kind: "RoleBinding"},
+				ObjectMeta: metav1.ObjectMeta{Name: role.name, Namespace: opts.ServiceAccountNamespace},
+				Subjects:   subjects,
+				RoleRef:    rbacv1.RoleRef{APIGroup: rbacv1.GroupName, Kind: "Role", Name: role.name},
+			},
+		)
+	}
+	return objects
+}
+
+// NormalizeNamespaces validates the namespaces served by the agent and returns them sorted, without duplicates
+func NormalizeNamespaces(namespaces []string) ([]string, error) {
+	var normalized []string
+	for _, namespace := range namespaces {
+		namespace = strings.TrimSpace(namespace)
+		if namespace == "" {
+			continue
+		}
+		if errs := validation.IsDNS1123Label(namespace); len(errs) > 0 {
+			return nil, fmt.Errorf("invalid namespace %q: %s", namespace, strings.Join(errs, ", "))
+		}
+		normalized = append(normalized, namespace)
+	}
+	slices.Sort(normalized)
+	return slices.Compact(normalized), nil
+}
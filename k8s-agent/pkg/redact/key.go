@@ -0,0 +1,53 @@
+package redact
+
+import (
+	"context"
+	"crypto/rand"
+	"fmt"
+
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+)
+
+// keySecretKey is the data key of the secret holding the redaction key
+const keySecretKey = "key"
+
+// LoadOrCreateKey returns the redaction key stored in the namespace/name secret, creating the secret with
+// a random key if it does not exist, so that every agent replica issues the same tokens
+func LoadOrCreateKey(ctx context.Context, clientset kubernetes.Interface, namespace, name string) ([]byte, error) {
+	secrets := clientset.CoreV1().Secrets(namespace)
+	secret, err := secrets.Get(ctx, name, metav1.GetOptions{})
+	if err == nil {
+		if len(secret.Data[keySecretKey]) == 0 {
+			return nil, fmt.Errorf("redaction key secret %s/%s has no %q key", namespace, name, keySecretKey)
+		}
+		return secret.Data[keySecretKey], nil
+	}
+	if !apierrors.IsNotFound(err) {
+		return nil, fmt.Errorf("failed to get redaction key secret: %w", err)
+	}
+
+	key := make([]byte, 32)
+	if _, err := rand.Read(key); err != nil {
+		return nil, fmt.Errorf("failed to generate redaction key: %w", err)
+	}
+	_, err = secrets.Create(ctx, &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      name,
+			Namespace: namespace,
+		},
+		Data: map[string][]byte{
+			keySecretKey: key,
+		},
+	}, metav1.CreateOptions{})
+	if apierrors.IsAlreadyExists(err) {
+		// another replica created it first
+		return LoadOrCreateKey(ctx, clientset, namespace, name)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to create redaction key secret: %w", err)
+	}
+	return key, nil
+}
@@ -0,0 +1,272 @@
+package redact
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// tokenPrefix starts every token so that they are easy to spot in prompts and manifests
+const tokenPrefix = "k8sgpt-redacted-"
+
+// tokenPattern matches the tokens replacing sensitive values
+var tokenPattern = regexp.MustCompile(tokenPrefix + `[0-9a-f]{16}`)
+
+// UnknownTokensError is returned by Restore when tokens can't be mapped back to their values,
+// e.g. tokens made up by a model, issued by another agent with a different key, or moved to another
+// field or object than the one they were issued for
+type UnknownTokensError struct {
+	Tokens []string
+}
+
+func (e *UnknownTokensError) Error() string {
+	return fmt.Sprintf("unknown redaction tokens: %s", strings.Join(e.Tokens, ", "))
+}
+
+// Redactor replaces sensitive values of objects with tokens and restores them.
+// A token is an HMAC of the object identity (group, kind, namespace and name), of the field path and of the value,
+// so agents sharing the key issue the same tokens. Values are not kept: a token is only restored from the live
+// object, into the same field of the same object it was issued for.
+type Redactor struct {
+	key []byte
+}
+
+// New creates a Redactor. An empty key is replaced with a random one, tokens then only
+// survive as long as the process.
+func New(key []byte) (*Redactor, error) {
+	if len(key) == 0 {
+		key = make([]byte, 32)
+		if _, err := rand.Read(key); err != nil {
+			return nil, fmt.Errorf("failed to generate redaction key: %w", err)
+		}
+	}
+	return &Redactor{key: key}, nil
+}
+
+// token returns the token of a value of the field at path of the object with the given identity
+func (r *Redactor) token(identity, path, value string) string {
+	mac := hmac.New(sha256.New, r.key)
+	mac.Write([]byte(identity))
+	mac.Write([]byte{0})
+	mac.Write([]byte(path))
+	mac.Write([]byte{0})
+	mac.Write([]byte(value))
+	return tokenPrefix + hex.EncodeToString(mac.Sum(nil))[:16]
+}
+
+// Redact replaces the sensitive values of an object content in place and returns how many were replaced
+func (r *Redactor) Redact(object map[string]interface{}) int {
+	identity := objectIdentity(object)
+	w := walker{mask: func(path, value string) string { return r.token(identity, path, value) }}
+	w.object(object)
+	return w.count
+}
+
+// Restore replaces the tokens of value in place with the values of live they stand for. value is the content
+// of the object or a merge or strategic merge patch of it. A token is only restored into the field it was issued
+// for, in the live object: tokens of other objects or fields, or without live object, are unknown.
+func (r *Redactor) Restore(value interface{}, live map[string]interface{}) error {
+	values := r.liveValues(live)
+	var unknown []string
+	restoreStrings(value, "", values, &unknown)
+	return unknownTokens(unknown)
+}
+
+// RestoreJSONPatch replaces the tokens of the values of JSON patch operations in place, like Restore.
+// The operation paths are resolved against live, so that list items are matched like in objects.
+func (r *Redactor) RestoreJSONPatch(operations []interface{}, live map[string]interface{}) error {
+	values := r.liveValues(live)
+	var unknown []string
+	for _, item := range operations {
+		operation, ok := item.(map[string]interface{})
+		if !ok {
+			continue
+		}
+		value, ok := operation["value"]
+		if !ok {
+			continue
+		}
+		pointer, _ := operation["path"].(string)
+		path, ok := resolvePointer(live, pointer, value)
+		if !ok {
+			// a path missing from the live object holds no redacted value
+			path = "\x00"
+		}
+		if s, ok := value.(string); ok {
+			operation["value"] = restoreString(s, path, values, &unknown)
+			continue
+		}
+		restoreStrings(value, path, values, &unknown)
+	}
+	return unknownTokens(unknown)
+}
+
+// liveValues redacts a copy of live and returns the values of its tokens, by field path
+func (r *Redactor) liveValues(live map[string]interface{}) map[string]map[string]string {
+	values := map[string]map[string]string{}
+	if live == nil {
+		return values
+	}
+	identity := objectIdentity(live)
+	w := walker{mask: func(path, value string) string {
+		token := r.token(identity, path, value)
+		if values[path] == nil {
+			values[path] = map[string]string{}
+		}
+		values[path][token] = value
+		return token
+	}}
+	w.object(deepCopy(live).(map[string]interface{}))
+	return values
+}
+
+// restoreStrings replaces in place the tokens of every string of a decoded JSON document at path, map keys excepted
+func restoreStrings(value interface{}, path string, values map[string]map[string]string, unknown *[]string) {
+	switch typed := value.(type) {
+	case map[string]interface{}:
+		for key, item := range typed {
+			itemPath := fieldPath(path, key)
+			if s, ok := item.(string); ok {
+				typed[key] = restoreString(s, itemPath, values, unknown)
+				continue
+			}
+			restoreStrings(item, itemPath, values, unknown)
+		}
+	case []interface{}:
+		for i, item := range typed {
+			itemPath := itemPath(path, item, i)
+			if s, ok := item.(string); ok {
+				typed[i] = restoreString(s, itemPath, values, unknown)
+				continue
+			}
+			restoreStrings(item, itemPath, values, unknown)
+		}
+	}
+}
+
+// restoreString replaces the tokens of s with the values issued for the field at path
+func restoreString(s, path string, values map[string]map[string]string, unknown *[]string) string {
+	return tokenPattern.ReplaceAllStringFunc(s, func(token string) string {
+		value, ok := values[path][token]
+		if !ok {
+			*unknown = append(*unknown, token)
+			return token
+		}
+		return value
+	})
+}
+
+// unknownTokens returns an UnknownTokensError with the sorted unique tokens, nil when there are none
+func unknownTokens(tokens []string) error {
+	if len(tokens) == 0 {
+		return nil
+	}
+	sort.Strings(tokens)
+	return &UnknownTokensError{Tokens: compact(tokens)}
+}
+
+// compact removes the consecutive duplicates of sorted strings
+func compact(sorted []string) []string {
+	result := sorted[:1]
+	for _, s := range sorted[1:] {
+		if s != result[len(result)-1] {
+			result = append(result, s)
+		}
+	}
+	return result
+}
+
+// objectIdentity returns the group, kind, namespace and name of an object content. The version is left out
+// so that tokens survive reading the object through another version.
+func objectIdentity(object map[string]interface{}) string {
+	apiVersion, _ := object["apiVersion"].(string)
+	group, _, found := strings.Cut(apiVersion, "/")
+	if !found {
+		group = ""
+	}
+	kind, _ := object["kind"].(string)
+	metadata, _ := object["metadata"].(map[string]interface{})
+	namespace, _ := metadata["namespace"].(string)
+	name, _ := metadata["name"].(string)
+	return strings.Join([]string{group, kind, namespace, name}, "/")
+}
+
+// fieldPath returns the path of the key field of the map at path
+func fieldPath(path, key string) string {
+	return path + "/" + strings.NewReplacer("~", "~0", "/", "~1").Replace(key)
+}
+
+// itemPath returns the path of a list item at path. Items with a name, e.g. containers and env variables,
+// are identified by their name so that paths survive reordering and strategic merge patches, others by index.
+func itemPath(path string, item interface{}, index int) string {
+	if fields, ok := item.(map[string]interface{}); ok {
+		if name, ok := fields["name"].(string); ok && name != "" {
+			return path + "[name=" + name + "]"
+		}
+	}
+	return path + "[" + strconv.Itoa(index) + "]"
+}
+
+// resolvePointer returns the field path of the JSON pointer of a patch operation, resolved against live.
+// An item added to a list is identified by its own name, if any. It returns false when live has no such field.
+func resolvePointer(live map[string]interface{}, pointer string, value interface{}) (string, bool) {
+	if pointer == "" {
+		return "", true
+	}
+	if !strings.HasPrefix(pointer, "/") {
+		return "", false
+	}
+	segments := strings.Split(pointer[1:], "/")
+	var current interface{} = live
+	path := ""
+	for i, segment := range segments {
+		segment = strings.NewReplacer("~1", "/", "~0", "~").Replace(segment)
+		last := i == len(segments)-1
+		switch typed := current.(type) {
+		case map[string]interface{}:
+			path = fieldPath(path, segment)
+			current = typed[segment]
+		case []interface{}:
+			index, err := strconv.Atoi(segment)
+			if err != nil || index < 0 || index >= len(typed) {
+				return "", false
+			}
+			item := typed[index]
+			if last {
+				// the operation value replaces or is inserted at the item
+				item = value
+			}
+			path = itemPath(path, item, index)
+			current = typed[index]
+		default:
+			return "", false
+		}
+	}
+	return path, true
+}
+
+// deepCopy copies a decoded JSON document
+func deepCopy(value interface{}) interface{} {
+	switch typed := value.(type) {
+	case map[string]interface{}:
+		copied := make(map[string]interface{}, len(typed))
+		for key, item := range typed {
+			copied[key] = deepCopy(item)
+		}
+		return copied
+	case []interface{}:
+		copied := make([]interface{}, len(typed))
+		for i, item := range typed {
+			copied[i] = deepCopy(item)
+		}
+		return copied
+	default:
+		return value
+	}
+}
@@ -0,0 +1,90 @@
+package registry
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"strings"
+)
+
+// sharedAddressSpace is the carrier-grade NAT range, used for cluster networks like private ranges
+var sharedAddressSpace = &net.IPNet{IP: net.IPv4(100, 64, 0, 0), Mask: net.CIDRMask(10, 32)}
+
+// isInternal returns true for the addresses of the local host and of the local networks,
+// which registries are not allowed on unless listed
+func isInternal(ip net.IP) bool {
+	return ip.IsLoopback() || ip.IsPrivate() || ip.IsUnspecified() ||
+		ip.IsLinkLocalUnicast() || ip.IsLinkLocalMulticast() || ip.IsInterfaceLocalMulticast() || ip.IsMulticast() ||
+		sharedAddressSpace.Contains(ip)
+}
+
+// dialer connects to registries, refusing internal addresses to hosts that are not allowed. Host names are
+// resolved once and the checked addresses dialed, so that a name can't resolve to another address in between.
+type dialer struct {
+	net.Dialer
+	// allowed are the host[:port] allowed on internal addresses
+	allowed map[string]bool
+}
+
+// DialContext connects to address, a host:port, when none of the addresses of the host is internal
+func (d *dialer) DialContext(ctx context.Context, network, address string) (net.Conn, error) {
+	host, port, err := net.SplitHostPort(address)
+	if err != nil {
+		return nil, err
+	}
+	if d.allowed[strings.ToLower(host)] || d.allowed[strings.ToLower(address)] {
+		return d.Dialer.DialContext(ctx, network, address)
+	}
+
+	addresses, err := net.DefaultResolver.LookupIPAddr(ctx, host)
+	if err != nil {
+		return nil, err
+	}
+	for _, ip := range addresses {
+		if isInternal(ip.IP) {
+			return nil, fmt.Errorf("registry %s resolves to internal address %s, list it in the internal or insecure registries to allow it", host, ip.IP)
+		}
+	}
+	var errs []error
+	for _, ip := range addresses {
+		conn, err := d.Dialer.DialContext(ctx, network, net.JoinHostPort(ip.IP.String(), port))
+		if err == nil {
+			return conn, nil
+		}
+		errs = append(errs, err)
+	}
+	if len(errs) == 0 {
+		return nil, fmt.Errorf("no address found for registry %s", host)
+	}
+	return nil, errors.Join(errs...)
+}
+
+// proxyHosts returns the host:port of the proxies of the environment, which are dialed instead of the registries
+func proxyHosts() []string {
+	var hosts []string
+	for _, target := range []string{"http://registry.invalid/", "https://registry.invalid/"} {
+		req, err := http.NewRequest(http.MethodGet, target, nil)
+		if err != nil {
+			continue
+		}
+		proxy, err := http.ProxyFromEnvironment(req)
+		if err != nil || proxy == nil {
+			continue
+		}
+		port := proxy.Port()
+		if port == "" {
+			port = map[string]string{"http": "80", "https": "443", "socks5": "1080"}[proxy.Scheme]
+		}
+		hosts = append(hosts, net.JoinHostPort(proxy.Hostname(), port))
+	}
+	return hosts
+}
+
+// newTransport returns the transport of the registry requests, dialing through d
+func newTransport(d *dialer) *http.Transport {
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.DialContext = d.DialContext
+	return transport
+}
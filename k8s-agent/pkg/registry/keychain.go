@@ -0,0 +1,172 @@
+package registry
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"path"
+	"sort"
+	"strings"
+
+	corev1 "k8s.io/api/core/v1"
+)
+
+// dockerHubHosts are the names Docker Hub credentials are stored under
+var dockerHubHosts = []string{"index.docker.io", "registry-1.docker.io", "docker.io"}
+
+// Credentials authenticate pulls from a registry
+type Credentials struct {
+	Username string
+	Password string
+	// RegistryToken is a bearer token sent as is, instead of the username and password
+	RegistryToken string
+}
+
+// dockerConfigEntry is an entry of the auths of a docker config
+type dockerConfigEntry struct {
+	Username      string `json:"username"`
+	Password      string `json:"password"`
+	Auth          string `json:"auth"`
+	RegistryToken string `json:"registrytoken"`
+}
+
+// keychainEntry holds the credentials of a docker config key, e.g. registry.example.com/team
+type keychainEntry struct {
+	host        string
+	path        string
+	credentials Credentials
+}
+
+// Keychain holds the credentials of image pull secrets, looked up like the kubelet does: by registry host,
+// with * globs in host parts, and by repository path prefix
+type Keychain struct {
+	entries []keychainEntry
+}
+
+// KeychainFromSecrets reads the credentials of kubernetes.io/dockerconfigjson and kubernetes.io/dockercfg secrets,
+// earlier secrets take precedence for the same key
+func KeychainFromSecrets(secrets []corev1.Secret) (*Keychain, error) {
+	keychain := &Keychain{}
+	seen := map[string]bool{}
+	for _, secret := range secrets {
+		var auths map[string]dockerConfigEntry
+		switch secret.Type {
+		case corev1.SecretTypeDockerConfigJson:
+			var config struct {
+				Auths map[string]dockerConfigEntry `json:"auths"`
+			}
+			if err := json.Unmarshal(secret.Data[corev1.DockerConfigJsonKey], &config); err != nil {
+				return nil, fmt.Errorf("invalid docker config in secret %s: %w", secret.Name, err)
+			}
+			auths = config.Auths
+		case corev1.SecretTypeDockercfg:
+			if err := json.Unmarshal(secret.Data[corev1.DockerConfigKey], &auths); err != nil {
+				return nil, fmt.Errorf("invalid docker config in secret %s: %w", secret.Name, err)
+			}
+		default:
+			continue
+		}
+
+		// sort the keys so that the keychain does not depend on map order
+		keys := make([]string, 0, len(auths))
+		for key := range auths {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+		for _, key := range keys {
+			credentials, err := auths[key].credentials()
+			if err != nil {
+				return nil, fmt.Errorf("invalid credentials for %s in secret %s: %w", key, secret.Name, err)
+			}
+			host, repository := parseConfigKey(key)
+			if seen[host+"/"+repository] {
+				continue
+			}
+			seen[host+"/"+repository] = true
+			keychain.entries = append(keychain.entries, keychainEntry{host: host, path: repository, credentials: credentials})
+		}
+	}
+	return keychain, nil
+}
+
+// credentials returns the credentials of the entry, decoding the auth field when username and password are not set
+func (e dockerConfigEntry) credentials() (Credentials, error) {
+	credentials := Credentials{Username: e.Username, Password: e.Password, RegistryToken: e.RegistryToken}
+	if e.Auth != "" && credentials.Username == "" && credentials.Password == "" {
+		decoded, err := base64.StdEncoding.DecodeString(e.Auth)
+		if err != nil {
+			return Credentials{}, fmt.Errorf("invalid auth: %w", err)
+		}
+		username, password, ok := strings.Cut(string(decoded), ":")
+		if !ok {
+			return Credentials{}, fmt.Errorf("invalid auth: expected username:password")
+		}
+		credentials.Username, credentials.Password = username, password
+	}
+	return credentials, nil
+}
+
+// Lookup returns the credentials of the most specific key matching the registry host and repository,
+// false when no key matches. A nil keychain has no credentials.
+func (k *Keychain) Lookup(host, repository string) (Credentials, bool) {
+	if k == nil {
+		return Credentials{}, false
+	}
+	host = normalizeHost(host)
+	var best *keychainEntry
+	for i := range k.entries {
+		entry := &k.entries[i]
+		if !matchesHost(entry.host, host) {
+			continue
+		}
+		if entry.path != "" && repository != entry.path && !strings.HasPrefix(repository, entry.path+"/") {
+			continue
+		}
+		if best == nil || len(entry.host)+len(entry.path) > len(best.host)+len(best.path) {
+			best = entry
+		}
+	}
+	if best == nil {
+		return Credentials{}, false
+	}
+	return best.credentials, true
+}
+
+// parseConfigKey splits a docker config key, e.g. https://registry.example.com/v1/ or registry.example.com/team,
+// into its host and repository path
+func parseConfigKey(key string) (host, repository string) {
+	key = strings.TrimPrefix(strings.TrimPrefix(key, "https://"), "http://")
+	host, repository, _ = strings.Cut(strings.TrimSuffix(key, "/"), "/")
+	host = normalizeHost(host)
+	// the legacy v1 and v2 API paths are not repositories
+	if repository == "v1" || repository == "v2" {
+		repository = ""
+	}
+	return host, repository
+}
+
+// normalizeHost returns docker.io for the names of Docker Hub, host otherwise
+func normalizeHost(host string) string {
+	host = strings.ToLower(host)
+	for _, dockerHub := range dockerHubHosts {
+		if host == dockerHub {
+			return "docker.io"
+		}
+	}
+	return host
+}
+
+// matchesHost returns true when host matches pattern, * globs only match within a dot separated part
+func matchesHost(pattern, host string) bool {
+	patternParts := strings.Split(pattern, ".")
+	hostParts := strings.Split(host, ".")
+	if len(patternParts) != len(hostParts) {
+		return false
+	}
+	for i := range patternParts {
+		if matched, err := path.Match(patternParts[i], hostParts[i]); err != nil || !matched {
+			return false
+		}
+	}
+	return true
+}
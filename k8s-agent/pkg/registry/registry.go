@@ -0,0 +1,160 @@
+// Package registry verifies image references against container registries through the registry v2 API,
+// authenticating with the credentials of image pull secrets like the kubelet.
+package registry
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/Sanskarzz/k8sgptclient/k8s-agent/api"
+	"github.com/distribution/reference"
+)
+
+// manifestMediaTypes are the manifests accepted when resolving a reference, image indexes included
+var manifestMediaTypes = []string{
+	"application/vnd.oci.image.index.v1+json",
+	"application/vnd.oci.image.manifest.v1+json",
+	"application/vnd.docker.distribution.manifest.list.v2+json",
+	"application/vnd.docker.distribution.manifest.v2+json",
+}
+
+// dockerHubRegistry serves the images of docker.io
+const dockerHubRegistry = "registry-1.docker.io"
+
+// errRepositoryNotFound is returned when the registry has no such repository, or denies pulling it
+var errRepositoryNotFound = errors.New("repository not found")
+
+// Options configures a Client
+type Options struct {
+	// Insecure are the registries queried over plain http, e.g. registry.local:5000.
+	// They may be on internal addresses like Internal registries.
+	Insecure []string
+	// Internal are the registries allowed on loopback, private and link-local addresses, e.g. registry.local
+	// or localhost:5000. Other registries resolving to such addresses are not contacted, nor are the internal
+	// addresses they redirect to. The proxies of the environment are trusted to do the same.
+	Internal []string
+	// Timeout bounds each verification, 0 does not
+	Timeout time.Duration
+}
+
+// Client verifies image references against their registries
+type Client struct {
+	insecure map[string]bool
+	timeout  time.Duration
+	http     *http.Client
+}
+
+// NewClient creates a Client
+func NewClient(opts Options) *Client {
+	insecure := map[string]bool{}
+	for _, host := range opts.Insecure {
+		insecure[strings.ToLower(strings.TrimSpace(host))] = true
+	}
+	allowed := map[string]bool{}
+	for _, hosts := range [][]string{opts.Insecure, opts.Internal, proxyHosts()} {
+		for _, host := range hosts {
+			allowed[strings.ToLower(strings.TrimSpace(host))] = true
+		}
+	}
+	d := &dialer{
+		Dialer:  net.Dialer{Timeout: 30 * time.Second, KeepAlive: 30 * time.Second},
+		allowed: allowed,
+	}
+	return &Client{
+		insecure: insecure,
+		timeout:  opts.Timeout,
+		http:     &http.Client{Transport: newTransport(d)},
+	}
+}
+
+// Verify resolves image in its registry with the credentials of keychain. An image without tag nor digest
+// resolves to its latest tag. When a tag is not found, the closest tags of the repository are reported.
+// Only the image fields of the report are set, not the object and container ones.
+func (c *Client) Verify(ctx context.Context, image string, keychain *Keychain) api.ImageReport {
+	report := api.ImageReport{Image: image}
+	named, err := reference.ParseNormalizedNamed(image)
+	if err != nil {
+		report.Status = api.ImageInvalid
+		report.Message = fmt.Sprintf("invalid image reference %q: %v", image, err)
+		return report
+	}
+	named = reference.TagNameOnly(named)
+	report.Reference = named.String()
+
+	// a digest takes precedence over the tag, like on pull
+	var ref, tag string
+	if digested, ok := named.(reference.Digested); ok {
+		ref = digested.Digest().String()
+	} else if tagged, ok := named.(reference.Tagged); ok {
+		ref = tagged.Tag()
+		tag = ref
+	}
+
+	if c.timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, c.timeout)
+		defer cancel()
+	}
+	s := c.newSession(reference.Domain(named), reference.Path(named), keychain)
+
+	digest, found, err := s.manifest(ctx, ref)
+	switch {
+	case errors.Is(err, errRepositoryNotFound):
+		report.Status = api.ImageNotFound
+		report.Message = fmt.Sprintf("pull access denied for %s, repository does not exist or may require credentials",
+			reference.FamiliarName(named))
+		return report
+	case err != nil:
+		report.Status = api.ImageUnverified
+		report.Message = fmt.Sprintf("failed to query registry %s: %v", reference.Domain(named), err)
+		return report
+	case found:
+		report.Status = api.ImageFound
+		report.Digest = digest
+		return report
+	}
+
+	report.Status = api.ImageNotFound
+	if tag == "" {
+		report.Message = fmt.Sprintf("manifest %s not found in %s", ref, reference.FamiliarName(named))
+		return report
+	}
+	report.Message = fmt.Sprintf("tag %s not found in %s", tag, reference.FamiliarName(named))
+	tags, err := s.tags(ctx)
+	switch {
+	case errors.Is(err, errRepositoryNotFound):
+		report.Message = fmt.Sprintf("repository %s not found", reference.FamiliarName(named))
+	case err != nil:
+		report.Message += fmt.Sprintf(", failed to list its tags: %v", err)
+	default:
+		report.ClosestTags = closestTags(tag, tags, closestTagsCount)
+	}
+	return report
+}
+
+// newSession returns a session querying a repository of a registry
+func (c *Client) newSession(host, repository string, keychain *Keychain) *session {
+	credentials, ok := keychain.Lookup(host, repository)
+	registryHost := host
+	if host == "docker.io" {
+		registryHost = dockerHubRegistry
+	}
+	insecure := c.insecure[strings.ToLower(host)]
+	scheme := "https"
+	if insecure {
+		scheme = "http"
+	}
+	return &session{
+		http:           c.http,
+		baseURL:        scheme + "://" + registryHost,
+		insecure:       insecure,
+		repository:     repository,
+		credentials:    credentials,
+		hasCredentials: ok,
+	}
+}
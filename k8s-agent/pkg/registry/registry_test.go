@@ -0,0 +1,248 @@
+package registry
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"slices"
+	"strings"
+	"sync/atomic"
+	"testing"
+
+	"github.com/Sanskarzz/k8sgptclient/k8s-agent/api"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+const (
+	testDigest   = "sha256:0123456789abcdef0123456789abcdef0123456789abcdef0123456789abcdef"
+	testUser     = "puller"
+	testPassword = "secret"
+	testToken    = "pull-token"
+)
+
+// testRegistry serves the team/app repository with the given tags, two tags per page. With realm set, requests
+// need a bearer token, which realm returns for the test credentials.
+type testRegistry struct {
+	tags  []string
+	realm func(server *httptest.Server) string
+	// requests counts the requests received
+	requests atomic.Int32
+}
+
+func (t *testRegistry) start(tb testing.TB) *httptest.Server {
+	var server *httptest.Server
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /token", func(w http.ResponseWriter, r *http.Request) {
+		user, password, ok := r.BasicAuth()
+		if !ok || user != testUser || password != testPassword {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		if scope := r.URL.Query().Get("scope"); scope != "repository:team/app:pull" {
+			tb.Errorf("unexpected token scope %q", scope)
+		}
+		_ = json.NewEncoder(w).Encode(map[string]string{"token": testToken})
+	})
+	mux.HandleFunc("HEAD /v2/team/app/manifests/{ref}", func(w http.ResponseWriter, r *http.Request) {
+		if !t.authorized(w, r, server) {
+			return
+		}
+		if !strings.Contains(r.Header.Get("Accept"), "application/vnd.oci.image.index.v1+json") {
+			tb.Errorf("manifest request does not accept image indexes: %q", r.Header.Get("Accept"))
+		}
+		if ref := r.PathValue("ref"); ref != testDigest && !slices.Contains(t.tags, ref) {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		w.Header().Set("Docker-Content-Digest", testDigest)
+	})
+	mux.HandleFunc("GET /v2/team/app/tags/list", func(w http.ResponseWriter, r *http.Request) {
+		if !t.authorized(w, r, server) {
+			return
+		}
+		// pages of two tags, starting after the last tag of the previous page
+		start := 0
+		if last := r.URL.Query().Get("last"); last != "" {
+			start = slices.Index(t.tags, last) + 1
+		}
+		end := min(start+2, len(t.tags))
+		if end < len(t.tags) {
+			w.Header().Set("Link", fmt.Sprintf(`</v2/team/app/tags/list?last=%s&n=2>; rel="next"`, t.tags[end-1]))
+		}
+		_ = json.NewEncoder(w).Encode(map[string]interface{}{"name": "team/app", "tags": t.tags[start:end]})
+	})
+	server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		t.requests.Add(1)
+		mux.ServeHTTP(w, r)
+	}))
+	tb.Cleanup(server.Close)
+	return server
+}
+
+// authorized answers a bearer challenge and returns false when the request has no valid token
+func (t *testRegistry) authorized(w http.ResponseWriter, r *http.Request, server *httptest.Server) bool {
+	if t.realm == nil || r.Header.Get("Authorization") == "Bearer "+testToken {
+		return true
+	}
+	w.Header().Set("WWW-Authenticate", fmt.Sprintf(`Bearer realm=%q,service="test"`, t.realm(server)))
+	w.WriteHeader(http.StatusUnauthorized)
+	return false
+}
+
+// host returns the host:port of the server, like in image references
+func host(server *httptest.Server) string {
+	u, _ := url.Parse(server.URL)
+	return u.Host
+}
+
+// testKeychain returns a keychain with the test credentials for the server
+func testKeychain(t *testing.T, server *httptest.Server) *Keychain {
+	t.Helper()
+	config, err := json.Marshal(map[string]interface{}{
+		"auths": map[string]interface{}{
+			host(server): map[string]string{"username": testUser, "password": testPassword},
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	keychain, err := KeychainFromSecrets([]corev1.Secret{{
+		ObjectMeta: metav1.ObjectMeta{Name: "regcred"},
+		Type:       corev1.SecretTypeDockerConfigJson,
+		Data:       map[string][]byte{corev1.DockerConfigJsonKey: config},
+	}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return keychain
+}
+
+func TestVerify(t *testing.T) {
+	tests := []struct {
+		name        string
+		tags        []string
+		realm       func(server *httptest.Server) string
+		credentials bool
+		image       string
+		status      api.ImageStatus
+		digest      string
+		closestTags []string
+		message     string
+	}{
+		{
+			name:   "tag found",
+			tags:   []string{"1.0", "1.1"},
+			image:  "team/app:1.1",
+			status: api.ImageFound,
+			digest: testDigest,
+		},
+		{
+			name:   "digest found",
+			tags:   []string{"1.0"},
+			image:  "team/app@" + testDigest,
+			status: api.ImageFound,
+			digest: testDigest,
+		},
+		{
+			name:        "tag not found with closest tags of every page",
+			tags:        []string{"1.0", "1.1", "1.25", "latest", "2.0"},
+			image:       "team/app:1.2",
+			status:      api.ImageNotFound,
+			closestTags: []string{"1.25", "1.0", "1.1", "2.0", "latest"},
+			message:     "tag 1.2 not found",
+		},
+		{
+			name:        "bearer token from the registry host",
+			tags:        []string{"1.0"},
+			realm:       func(server *httptest.Server) string { return server.URL + "/token" },
+			credentials: true,
+			image:       "team/app:1.0",
+			status:      api.ImageFound,
+			digest:      testDigest,
+		},
+		{
+			name:    "bearer token without credentials",
+			tags:    []string{"1.0"},
+			realm:   func(server *httptest.Server) string { return server.URL + "/token" },
+			image:   "team/app:1.0",
+			status:  api.ImageUnverified,
+			message: "failed to get token",
+		},
+		{
+			name:        "bearer realm on another host",
+			tags:        []string{"1.0"},
+			realm:       func(*httptest.Server) string { return "https://auth.example.com/token" },
+			credentials: true,
+			image:       "team/app:1.0",
+			status:      api.ImageUnverified,
+			message:     "bearer realm https://auth.example.com/token is not the registry",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			registry := &testRegistry{tags: tt.tags, realm: tt.realm}
+			server := registry.start(t)
+			client := NewClient(Options{Insecure: []string{host(server)}})
+			var keychain *Keychain
+			if tt.credentials {
+				keychain = testKeychain(t, server)
+			}
+
+			report := client.Verify(context.Background(), host(server)+"/"+tt.image, keychain)
+			if report.Status != tt.status {
+				t.Fatalf("status = %s, want %s, message: %s", report.Status, tt.status, report.Message)
+			}
+			if report.Digest != tt.digest {
+				t.Errorf("digest = %q, want %q", report.Digest, tt.digest)
+			}
+			if !slices.Equal(report.ClosestTags, tt.closestTags) {
+				t.Errorf("closest tags = %v, want %v", report.ClosestTags, tt.closestTags)
+			}
+			if !strings.Contains(report.Message, tt.message) {
+				t.Errorf("message = %q, want it to contain %q", report.Message, tt.message)
+			}
+		})
+	}
+}
+
+func TestVerifyInternalRegistry(t *testing.T) {
+	registry := &testRegistry{tags: []string{"1.0"}}
+	server := registry.start(t)
+	image := host(server) + "/team/app:1.0"
+
+	// loopback registries are only contacted when listed
+	report := NewClient(Options{}).Verify(context.Background(), image, nil)
+	if report.Status != api.ImageUnverified || !strings.Contains(report.Message, "internal address") {
+		t.Fatalf("status = %s, message: %s, want the internal address to be refused", report.Status, report.Message)
+	}
+	if n := registry.requests.Load(); n != 0 {
+		t.Fatalf("unlisted internal registry received %d requests", n)
+	}
+
+	// listed internal registries are dialed, over https which the plain http server doesn't speak
+	report = NewClient(Options{Internal: []string{host(server)}}).Verify(context.Background(), image, nil)
+	if report.Status != api.ImageUnverified || strings.Contains(report.Message, "internal address") {
+		t.Fatalf("status = %s, message: %s, want the https request to fail", report.Status, report.Message)
+	}
+}
+
+func TestParseChallenge(t *testing.T) {
+	scheme, params := parseChallenge(`Bearer realm="https://auth.docker.io/token",service="registry.docker.io",scope="repository:library/nginx:pull,push"`)
+	if scheme != "Bearer" {
+		t.Errorf("scheme = %q, want Bearer", scheme)
+	}
+	want := map[string]string{
+		"realm":   "https://auth.docker.io/token",
+		"service": "registry.docker.io",
+		"scope":   "repository:library/nginx:pull,push",
+	}
+	for name, value := range want {
+		if params[name] != value {
+			t.Errorf("%s = %q, want %q", name, params[name], value)
+		}
+	}
+}
@@ -0,0 +1,290 @@
+package registry
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+)
+
+const (
+	// tagPageSize and maxTagPages bound the tags listed for suggestions
+	tagPageSize = 1000
+	maxTagPages = 10
+	// closestTagsCount is the number of suggested tags
+	closestTagsCount = 5
+	// maxErrorBody bounds the registry error bodies read into messages
+	maxErrorBody = 4096
+)
+
+// tokenServices are the token services of registries on another host than the registry
+var tokenServices = map[string]string{
+	dockerHubRegistry: "auth.docker.io",
+}
+
+// session queries a repository of a registry, authenticating on the first challenge
+type session struct {
+	http    *http.Client
+	baseURL string
+	// insecure is true for registries queried over plain http
+	insecure       bool
+	repository     string
+	credentials    Credentials
+	hasCredentials bool
+	// authorization is the Authorization header obtained on the first challenge
+	authorization string
+}
+
+// manifest returns the digest of the manifest of ref, a tag or a digest, false when the registry has none
+func (s *session) manifest(ctx context.Context, ref string) (string, bool, error) {
+	header := http.Header{"Accept": {strings.Join(manifestMediaTypes, ", ")}}
+	resp, err := s.do(ctx, http.MethodHead, fmt.Sprintf("/v2/%s/manifests/%s", s.repository, ref), header)
+	if err != nil {
+		return "", false, err
+	}
+	defer resp.Body.Close()
+
+	switch resp.StatusCode {
+	case http.StatusOK:
+		return resp.Header.Get("Docker-Content-Digest"), true, nil
+	case http.StatusNotFound:
+		return "", false, nil
+	case http.StatusUnauthorized, http.StatusForbidden:
+		// registries deny unknown repositories like private ones
+		return "", false, errRepositoryNotFound
+	default:
+		return "", false, statusError(resp)
+	}
+}
+
+// tags lists the tags of the repository, following the pagination links up to maxTagPages pages
+func (s *session) tags(ctx context.Context) ([]string, error) {
+	var tags []string
+	next := fmt.Sprintf("/v2/%s/tags/list?n=%d", s.repository, tagPageSize)
+	for page := 0; next != "" && page < maxTagPages; page++ {
+		resp, err := s.do(ctx, http.MethodGet, next, nil)
+		if err != nil {
+			return nil, err
+		}
+		switch resp.StatusCode {
+		case http.StatusOK:
+		case http.StatusNotFound, http.StatusUnauthorized, http.StatusForbidden:
+			resp.Body.Close()
+			return nil, errRepositoryNotFound
+		default:
+			err := statusError(resp)
+			resp.Body.Close()
+			return nil, err
+		}
+
+		var list struct {
+			Tags []string `json:"tags"`
+		}
+		err = json.NewDecoder(resp.Body).Decode(&list)
+		resp.Body.Close()
+		if err != nil {
+			return nil, fmt.Errorf("failed to decode tags: %w", err)
+		}
+		tags = append(tags, list.Tags...)
+		next = nextLink(resp.Header.Get("Link"))
+	}
+	return tags, nil
+}
+
+// do sends a request to the registry. On a 401 challenge it authenticates and sends the request again.
+// The caller closes the response body.
+func (s *session) do(ctx context.Context, method, path string, header http.Header) (*http.Response, error) {
+	send := func() (*http.Response, error) {
+		req, err := http.NewRequestWithContext(ctx, method, s.baseURL+path, nil)
+		if err != nil {
+			return nil, err
+		}
+		for name, values := range header {
+			req.Header[name] = values
+		}
+		if s.authorization != "" {
+			req.Header.Set("Authorization", s.authorization)
+		}
+		return s.http.Do(req)
+	}
+
+	resp, err := send()
+	if err != nil || resp.StatusCode != http.StatusUnauthorized || s.authorization != "" {
+		return resp, err
+	}
+	challenge := resp.Header.Get("WWW-Authenticate")
+	authorization, err := s.authenticate(ctx, challenge)
+	if err != nil {
+		resp.Body.Close()
+		return nil, err
+	}
+	if authorization == "" {
+		// no way to authenticate, e.g. basic auth without credentials
+		return resp, nil
+	}
+	resp.Body.Close()
+	s.authorization = authorization
+	return send()
+}
+
+// authenticate answers a WWW-Authenticate challenge with the session credentials and returns the
+// Authorization header, empty when the challenge can't be answered
+func (s *session) authenticate(ctx context.Context, challenge string) (string, error) {
+	scheme, params := parseChallenge(challenge)
+	switch strings.ToLower(scheme) {
+	case "basic":
+		if !s.hasCredentials || s.credentials.Username == "" {
+			return "", nil
+		}
+		req := &http.Request{Header: http.Header{}}
+		req.SetBasicAuth(s.credentials.Username, s.credentials.Password)
+		return req.Header.Get("Authorization"), nil
+	case "bearer":
+		if s.credentials.RegistryToken != "" {
+			return "Bearer " + s.credentials.RegistryToken, nil
+		}
+		token, err := s.token(ctx, params)
+		if err != nil {
+			return "", err
+		}
+		return "Bearer " + token, nil
+	default:
+		return "", nil
+	}
+}
+
+// token gets a pull token of the repository from the token service of a bearer challenge,
+// anonymously when the session has no credentials
+func (s *session) token(ctx context.Context, params map[string]string) (string, error) {
+	realm := params["realm"]
+	if realm == "" {
+		return "", fmt.Errorf("bearer challenge without realm")
+	}
+	tokenURL, err := url.Parse(realm)
+	if err != nil {
+		return "", fmt.Errorf("invalid bearer realm %q: %w", realm, err)
+	}
+	// credentials are only sent to the registry itself
+	if err := s.checkRealm(tokenURL); err != nil {
+		return "", err
+	}
+	query := tokenURL.Query()
+	if service := params["service"]; service != "" {
+		query.Set("service", service)
+	}
+	scope := params["scope"]
+	if scope == "" {
+		scope = "repository:" + s.repository + ":pull"
+	}
+	query.Set("scope", scope)
+	tokenURL.RawQuery = query.Encode()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, tokenURL.String(), nil)
+	if err != nil {
+		return "", err
+	}
+	if s.hasCredentials && s.credentials.Username != "" {
+		req.SetBasicAuth(s.credentials.Username, s.credentials.Password)
+	}
+	resp, err := s.http.Do(req)
+	if err != nil {
+		return "", fmt.Errorf("failed to get token: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("failed to get token: %w", statusError(resp))
+	}
+
+	var body struct {
+		Token       string `json:"token"`
+		AccessToken string `json:"access_token"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&body); err != nil {
+		return "", fmt.Errorf("failed to decode token: %w", err)
+	}
+	if body.Token != "" {
+		return body.Token, nil
+	}
+	if body.AccessToken != "" {
+		return body.AccessToken, nil
+	}
+	return "", fmt.Errorf("token service returned no token")
+}
+
+// checkRealm returns an error unless the token service of a bearer challenge is on the registry host, or is
+// the known token service of the registry, over https. Insecure registries may use their own host over http.
+func (s *session) checkRealm(realm *url.URL) error {
+	registry, err := url.Parse(s.baseURL)
+	if err != nil {
+		return err
+	}
+	host := strings.ToLower(realm.Host)
+	switch {
+	case realm.Scheme == "https" && (host == strings.ToLower(registry.Host) || host == tokenServices[registry.Host]):
+		return nil
+	case realm.Scheme == "http" && s.insecure && host == strings.ToLower(registry.Host):
+		return nil
+	default:
+		return fmt.Errorf("bearer realm %s is not the registry %s over https", realm.Redacted(), registry.Host)
+	}
+}
+
+// parseChallenge parses a WWW-Authenticate header, e.g.
+// Bearer realm="https://auth.docker.io/token",service="registry.docker.io",scope="repository:library/nginx:pull"
+func parseChallenge(challenge string) (string, map[string]string) {
+	scheme, rest, _ := strings.Cut(strings.TrimSpace(challenge), " ")
+	params := map[string]string{}
+	for rest = strings.TrimSpace(rest); rest != ""; {
+		var name string
+		name, rest, _ = strings.Cut(rest, "=")
+		name = strings.ToLower(strings.TrimSpace(name))
+		var value string
+		if strings.HasPrefix(rest, `"`) {
+			// quoted values may hold commas, e.g. scopes with several actions
+			if unquoted, err := strconv.QuotedPrefix(rest); err == nil {
+				value, _ = strconv.Unquote(unquoted)
+				rest = rest[len(unquoted):]
+			} else {
+				value, rest = strings.Trim(rest, `"`), ""
+			}
+			_, rest, _ = strings.Cut(rest, ",")
+		} else {
+			value, rest, _ = strings.Cut(rest, ",")
+		}
+		if name != "" {
+			params[name] = strings.TrimSpace(value)
+		}
+		rest = strings.TrimSpace(rest)
+	}
+	return scheme, params
+}
+
+// nextLink returns the target of the next link of a Link header, e.g. </v2/nginx/tags/list?last=1.25&n=1000>; rel="next"
+func nextLink(header string) string {
+	for _, link := range strings.Split(header, ",") {
+		target, params, ok := strings.Cut(link, ";")
+		if !ok || !strings.Contains(strings.ReplaceAll(params, " ", ""), `rel="next"`) {
+			continue
+		}
+		target = strings.Trim(strings.TrimSpace(target), "<>")
+		// links are relative to the registry, absolute ones are kept to their path
+		if parsed, err := url.Parse(target); err == nil && parsed.IsAbs() {
+			target = parsed.RequestURI()
+		}
+		return target
+	}
+	return ""
+}
+
+// statusError returns an error with the status and the start of the body of an unexpected registry response
+func statusError(resp *http.Response) error {
+	body, _ := io.ReadAll(io.LimitReader(resp.Body, maxErrorBody))
+	if message := strings.TrimSpace(string(body)); message != "" {
+		return fmt.Errorf("unexpected status %s: %s", resp.Status, message)
+	}
+	return fmt.Errorf("unexpected status %s", resp.Status)
+}
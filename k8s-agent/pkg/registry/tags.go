@@ -0,0 +1,67 @@
+package registry
+
+import (
+	"sort"
+	"strings"
+)
+
+// closestTags returns up to count tags closest to tag, by edit distance. Tags extending tag or extended by it,
+// e.g. latest for lat or 1.25 for 1.25.99, come first.
+func closestTags(tag string, tags []string, count int) []string {
+	type candidate struct {
+		tag      string
+		prefix   bool
+		distance int
+	}
+	candidates := make([]candidate, 0, len(tags))
+	for _, t := range tags {
+		if t == tag {
+			continue
+		}
+		candidates = append(candidates, candidate{
+			tag:      t,
+			prefix:   strings.HasPrefix(t, tag) || strings.HasPrefix(tag, t),
+			distance: editDistance(strings.ToLower(tag), strings.ToLower(t)),
+		})
+	}
+	sort.Slice(candidates, func(i, j int) bool {
+		a, b := candidates[i], candidates[j]
+		if a.prefix != b.prefix {
+			return a.prefix
+		}
+		if a.distance != b.distance {
+			return a.distance < b.distance
+		}
+		return a.tag < b.tag
+	})
+
+	var closest []string
+	for _, c := range candidates {
+		if len(closest) == count {
+			break
+		}
+		closest = append(closest, c.tag)
+	}
+	return closest
+}
+
+// editDistance returns the Levenshtein distance between a and b
+func editDistance(a, b string) int {
+	previous := make([]int, len(b)+1)
+	current := make([]int, len(b)+1)
+	for j := range previous {
+		previous[j] = j
+	}
+	for i := 1; i <= len(a); i++ {
+		current[0] = i
+		for j := 1; j <= len(b); j++ {
+			cost := 1
+			if a[i-1] == b[j-1] {
+				cost = 0
+			}
+			current[j] = min(previous[j]+1, current[j-1]+1, previous[j-1]+cost)
+		}
+		previous, current = current, previous
+	}
+	return previous[len(b)]
+}
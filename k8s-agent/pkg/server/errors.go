@@ -0,0 +1,133 @@
+package server
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/Sanskarzz/k8sgptclient/k8s-agent/api"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+)
+
+// reasonNotImplemented is the reason of endpoints disabled by configuration, Kubernetes has no such reason
+const reasonNotImplemented = "NotImplemented"
+
+// ReasonForCode returns the Kubernetes status reason matching an HTTP status code
+func ReasonForCode(code int) string {
+	switch code {
+	case http.StatusBadRequest:
+		return string(metav1.StatusReasonBadRequest)
+	case http.StatusUnauthorized:
+		return string(metav1.StatusReasonUnauthorized)
+	case http.StatusForbidden:
+		return string(metav1.StatusReasonForbidden)
+	case http.StatusNotFound:
+		return string(metav1.StatusReasonNotFound)
+	case http.StatusMethodNotAllowed:
+		return string(metav1.StatusReasonMethodNotAllowed)
+	case http.StatusConflict:
+		return string(metav1.StatusReasonConflict)
+	case http.StatusGone:
+		return string(metav1.StatusReasonGone)
+	case http.StatusRequestEntityTooLarge:
+		return string(metav1.StatusReasonRequestEntityTooLarge)
+	case http.StatusUnsupportedMediaType:
+		return string(metav1.StatusReasonUnsupportedMediaType)
+	case http.StatusUnprocessableEntity:
+		return string(metav1.StatusReasonInvalid)
+	case http.StatusTooManyRequests:
+		return string(metav1.StatusReasonTooManyRequests)
+	case http.StatusNotImplemented:
+		return reasonNotImplemented
+	case http.StatusServiceUnavailable:
+		return string(metav1.StatusReasonServiceUnavailable)
+	case http.StatusGatewayTimeout:
+		return string(metav1.StatusReasonTimeout)
+	default:
+		return string(metav1.StatusReasonInternalError)
+	}
+}
+
+// NewError converts err to an error response. Kubernetes API errors keep their status code, reason and causes,
+// unknown kinds are not found and other errors are internal errors. message prefixes the error message.
+func NewError(message string, err error) api.Error {
+	apiErr := api.Error{
+		Code:    http.StatusInternalServerError,
+		Message: fmt.Sprintf("%s: %v", message, err),
+	}
+
+	var status apierrors.APIStatus
+	switch {
+	case errors.As(err, &status):
+		s := status.Status()
+		if s.Code != 0 {
+			apiErr.Code = int(s.Code)
+		}
+		apiErr.Reason = string(s.Reason)
+		if s.Details != nil {
+			apiErr.Details = &api.ErrorDetails{
+				Group:  s.Details.Group,
+				Kind:   s.Details.Kind,
+				Name:   s.Details.Name,
+				Causes: causes(err),
+			}
+		}
+	case meta.IsNoMatchError(err):
+		apiErr.Code = http.StatusNotFound
+	}
+	if apiErr.Reason == "" || apiErr.Reason == string(metav1.StatusReasonUnknown) {
+		apiErr.Reason = ReasonForCode(apiErr.Code)
+	}
+	return apiErr
+}
+
+// StatusCode returns the HTTP status code of the error response of err, see NewError
+func StatusCode(err error) int {
+	return NewError("", err).Code
+}
+
+// causes returns the individual problems of a Kubernetes API error, e.g. the invalid fields of an object
+func causes(err error) []api.ErrorCause {
+	var status apierrors.APIStatus
+	if !errors.As(err, &status) || status.Status().Details == nil {
+		return nil
+	}
+	var result []api.ErrorCause
+	for _, cause := range status.Status().Details.Causes {
+		result = append(result, api.ErrorCause{
+			Type:    string(cause.Type),
+			Message: cause.Message,
+			Field:   cause.Field,
+		})
+	}
+	return result
+}
+
+// WriteError writes a JSON error response with the given status code
+func WriteError(w http.ResponseWriter, r *http.Request, code int, message string) {
+	writeError(w, r, api.Error{
+		Code:    code,
+		Reason:  ReasonForCode(code),
+		Message: message,
+	})
+}
+
+// WriteAPIError writes err as a JSON error response, see NewError
+func WriteAPIError(w http.ResponseWriter, r *http.Request, message string, err error) {
+	writeError(w, r, NewError(message, err))
+}
+
+func writeError(w http.ResponseWriter, r *http.Request, apiErr api.Error) {
+	apiErr.RequestID = RequestIDFromContext(r.Context())
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	w.WriteHeader(apiErr.Code)
+	if err := json.NewEncoder(w).Encode(apiErr); err != nil {
+		log.FromContext(r.Context()).Error(err, "Failed to encode error response")
+	}
+}
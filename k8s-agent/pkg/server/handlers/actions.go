@@ -0,0 +1,291 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/Sanskarzz/k8sgptclient/k8s-agent/api"
+	"github.com/Sanskarzz/k8sgptclient/k8s-agent/pkg/metrics"
+	"github.com/Sanskarzz/k8sgptclient/k8s-agent/pkg/server"
+	"github.com/go-logr/logr"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	policyv1 "k8s.io/api/policy/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+)
+
+// restartedAtAnnotation is the pod template annotation kubectl rollout restart sets to roll pods
+const restartedAtAnnotation = "kubectl.kubernetes.io/restartedAt"
+
+// action is an imperative change of a single object, checked against the policy and audited like an apply
+type action struct {
+	// operation is recorded in the audit log, e.g. "restart"
+	operation string
+	// done is the response action on success, e.g. "restarted"
+	done string
+	// gvk and key identify the object
+	gvk schema.GroupVersionKind
+	key client.ObjectKey
+	// mutate returns the object as it will be after the action, nil when the action deletes it
+	mutate func(live *unstructured.Unstructured) (*unstructured.Unstructured, error)
+	// run performs the action, desired is the result of mutate
+	run func(ctx context.Context, live, desired *unstructured.Unstructured, dryRun bool) error
+}
+
+// RestartDeployment returns a handler for POST /deployments/{namespace}/{deploymentName}/restart endpoint.
+// It rolls the pods of the deployment like kubectl rollout restart.
+func (h *ClientHandler) RestartDeployment() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		logger := log.FromContext(r.Context()).WithName("restart-deployment")
+
+		if r.Method != http.MethodPost {
+			err := fmt.Errorf("invalid method: %s, allowed: %s", r.Method, http.MethodPost)
+			logger.Error(err, "Method not allowed")
+			server.WriteError(w, r, http.StatusMethodNotAllowed, "Method not allowed")
+			return
+		}
+
+		// Parse path parameters
+		parts := strings.Split(r.URL.Path, "/")
+		if len(parts) != 5 || parts[2] == "" || parts[3] == "" {
+			err := fmt.Errorf("invalid path: %s, expected: /deployments/{namespace}/{deploymentName}/restart", r.URL.Path)
+			logger.Error(err, "Invalid path")
+			server.WriteError(w, r, http.StatusBadRequest, "Invalid path. Expected: /deployments/{namespace}/{deploymentName}/restart")
+			return
+		}
+
+		restartedAt := time.Now().Format(time.RFC3339)
+		h.runAction(w, r, logger, action{
+			operation: "restart",
+			done:      "restarted",
+			gvk:       appsv1.SchemeGroupVersion.WithKind("Deployment"),
+			key:       client.ObjectKey{Namespace: parts[2], Name: parts[3]},
+			mutate: func(live *unstructured.Unstructured) (*unstructured.Unstructured, error) {
+				desired := live.DeepCopy()
+				if err := unstructured.SetNestedField(desired.Object, restartedAt,
+					"spec", "template", "metadata", "annotations", restartedAtAnnotation); err != nil {
+					return nil, err
+				}
+				return desired, nil
+			},
+			run: h.patchAction,
+		})
+	}
+}
+
+// ScaleDeployment returns a handler for POST /deployments/{namespace}/{deploymentName}/scale endpoint.
+// It sets the replicas of the deployment to the value of the request body.
+func (h *ClientHandler) ScaleDeployment() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		logger := log.FromContext(r.Context()).WithName("scale-deployment")
+
+		if r.Method != http.MethodPost {
+			err := fmt.Errorf("invalid method: %s, allowed: %s", r.Method, http.MethodPost)
+			logger.Error(err, "Method not allowed")
+			server.WriteError(w, r, http.StatusMethodNotAllowed, "Method not allowed")
+			return
+		}
+
+		// Parse path parameters
+		parts := strings.Split(r.URL.Path, "/")
+		if len(parts) != 5 || parts[2] == "" || parts[3] == "" {
+			err := fmt.Errorf("invalid path: %s, expected: /deployments/{namespace}/{deploymentName}/scale", r.URL.Path)
+			logger.Error(err, "Invalid path")
+			server.WriteError(w, r, http.StatusBadRequest, "Invalid path. Expected: /deployments/{namespace}/{deploymentName}/scale")
+			return
+		}
+
+		// Read the requested replicas
+		// Unknown fields are rejected so that a misspelled field doesn't scale to zero
+		var request api.ScaleRequest
+		decoder := json.NewDecoder(r.Body)
+		decoder.DisallowUnknownFields()
+		if err := decoder.Decode(&request); err != nil {
+			logger.Error(err, "Failed to decode request body")
+			server.WriteError(w, r, http.StatusBadRequest, fmt.Sprintf("Failed to decode request body: %v", err))
+			return
+		}
+		if request.Replicas == nil {
+			err := errors.New("missing replicas")
+			logger.Error(err, "Invalid replicas")
+			server.WriteError(w, r, http.StatusBadRequest, err.Error())
+			return
+		}
+		replicas := *request.Replicas
+		if replicas < 0 {
+			err := fmt.Errorf("invalid replicas: %d, must not be negative", replicas)
+			logger.Error(err, "Invalid replicas")
+			server.WriteError(w, r, http.StatusBadRequest, err.Error())
+			return
+		}
+		logger = logger.WithValues("replicas", replicas)
+
+		h.runAction(w, r, logger, action{
+			operation: "scale",
+			done:      "scaled",
+			gvk:       appsv1.SchemeGroupVersion.WithKind("Deployment"),
+			key:       client.ObjectKey{Namespace: parts[2], Name: parts[3]},
+			mutate: func(live *unstructured.Unstructured) (*unstructured.Unstructured, error) {
+				desired := live.DeepCopy()
+				if err := unstructured.SetNestedField(desired.Object, int64(replicas), "spec", "replicas"); err != nil {
+					return nil, err
+				}
+				return desired, nil
+			},
+			run: h.patchAction,
+		})
+	}
+}
+
+// DeletePod returns a handler for DELETE /pods/{namespace}/{podName} endpoint.
+// The pod is evicted, so the deletion honours PodDisruptionBudgets.
+func (h *ClientHandler) DeletePod() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		logger := log.FromContext(r.Context()).WithName("delete-pod")
+
+		if r.Method != http.MethodDelete {
+			err := fmt.Errorf("invalid method: %s, allowed: %s", r.Method, http.MethodDelete)
+			logger.Error(err, "Method not allowed")
+			server.WriteError(w, r, http.StatusMethodNotAllowed, "Method not allowed")
+			return
+		}
+
+		// Parse path parameters
+		parts := strings.Split(r.URL.Path, "/")
+		if len(parts) != 4 || parts[2] == "" || parts[3] == "" {
+			err := fmt.Errorf("invalid path: %s, expected: /pods/{namespace}/{podName}", r.URL.Path)
+			logger.Error(err, "Invalid path")
+			server.WriteError(w, r, http.StatusBadRequest, "Invalid path. Expected: /pods/{namespace}/{podName}")
+			return
+		}
+
+		h.runAction(w, r, logger, action{
+			operation: "evict",
+			done:      "evicted",
+			gvk:       corev1.SchemeGroupVersion.WithKind("Pod"),
+			key:       client.ObjectKey{Namespace: parts[2], Name: parts[3]},
+			mutate: func(*unstructured.Unstructured) (*unstructured.Unstructured, error) {
+				return nil, nil
+			},
+			run: func(ctx context.Context, live, _ *unstructured.Unstructured, dryRun bool) error {
+				eviction := &policyv1.Eviction{
+					ObjectMeta: metav1.ObjectMeta{
+						Name:      live.GetName(),
+						Namespace: live.GetNamespace(),
+					},
+					DeleteOptions: &metav1.DeleteOptions{
+						// only evict the pod that was checked against the policy
+						Preconditions: metav1.NewUIDPreconditions(string(live.GetUID())),
+					},
+				}
+				if dryRun {
+					eviction.DeleteOptions.DryRun = []string{metav1.DryRunAll}
+				}
+				// A PodDisruptionBudget blocking the eviction is reported as 429 Too Many Requests
+				return h.Clientset.PolicyV1().Evictions(live.GetNamespace()).Evict(ctx, eviction)
+			},
+		})
+	}
+}
+
+// patchAction patches the live object to the desired state
+func (h *ClientHandler) patchAction(ctx context.Context, live, desired *unstructured.Unstructured, dryRun bool) error {
+	opts := []client.PatchOption{client.FieldOwner(fieldManager)}
+	if dryRun {
+		opts = append(opts, client.DryRunAll)
+	}
+	// the live resourceVersion in the patch makes it fail with a conflict if the object changed since the policy check
+	return h.Client.Patch(ctx, desired, client.MergeFromWithOptions(live, client.MergeFromWithOptimisticLock{}), opts...)
+}
+
+// runAction checks an action against the policy, runs it, records it in the audit log and writes the response
+func (h *ClientHandler) runAction(w http.ResponseWriter, r *http.Request, logger logr.Logger, a action) {
+	opts, err := h.parseApplyOptions(r)
+	if err != nil {
+		logger.Error(err, "Invalid query parameters")
+		server.WriteError(w, r, http.StatusBadRequest, err.Error())
+		return
+	}
+	logger = logger.WithValues(
+		"kind", a.gvk.Kind,
+		"namespace", a.key.Namespace,
+		"name", a.key.Name,
+		"dryRun", opts.DryRun,
+	)
+	logger.Info("Running action", "operation", a.operation)
+	if !h.checkNamespace(w, r, logger, a.key.Namespace) {
+		return
+	}
+
+	// Get the live object, unstructured objects are read from the API server and not cached
+	live := &unstructured.Unstructured{}
+	live.SetGroupVersionKind(a.gvk)
+	if err := h.Client.Get(r.Context(), a.key, live); err != nil {
+		logger.Error(err, "Failed to get live resource")
+		server.WriteAPIError(w, r, "Failed to get live resource", err)
+		return
+	}
+	desired, err := a.mutate(live)
+	if err != nil {
+		logger.Error(err, "Failed to compute desired resource")
+		server.WriteAPIError(w, r, "Failed to compute desired resource", err)
+		return
+	}
+
+	// Check the action against the policy, deletions are checked as the object they delete
+	checked := desired
+	if checked == nil {
+		checked = live
+	}
+	response := newApplyResponse(live, a.done)
+	status := http.StatusOK
+	if violations := h.Policy.Evaluate(checked, live); len(violations) > 0 {
+		logger.Info("Action denied by policy", "violations", violations)
+		response.Action = "denied"
+		response.Violations = violations
+		for _, violation := range violations {
+			metrics.PolicyDenialsTotal.WithLabelValues(live.GetKind(), live.GetNamespace(), violation.Rule).Inc()
+		}
+		status = http.StatusForbidden
+	} else if err := a.run(r.Context(), live, desired, opts.DryRun); err != nil {
+		logger.Error(err, "Failed to run action", "operation", a.operation)
+		response.Action = "failed"
+		status = setResponseError(&response, fmt.Sprintf("Failed to %s resource", a.operation), err)
+	} else {
+		if opts.DryRun {
+			response.Action = "dry-run"
+			response.DryRun = true
+			if desired != nil {
+				response.Diff = h.diffRedacted(live, desired)
+			}
+		}
+		logger.Info("Successfully ran action", "operation", a.operation)
+	}
+
+	// Record the attempted mutation
+	var after *unstructured.Unstructured
+	if response.Action == a.done || response.Action == "dry-run" {
+		after = desired
+	}
+	h.recordAudit(r, a.operation, live, live, after, response)
+
+	// Set response headers
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+
+	// Write response
+	if err := json.NewEncoder(w).Encode(response); err != nil {
+		logger.Error(err, "Failed to encode response")
+		return
+	}
+	logger.V(1).Info("Response sent successfully")
+}
@@ -1,24 +1,58 @@
 package handlers
 
 import (
+	"bufio"
+	"bytes"
+	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"net/http"
+	"strconv"
+	"strings"
+	"time"
 
-	"k8s.io/apimachinery/pkg/api/meta"
+	"github.com/Sanskarzz/k8sgptclient/k8s-agent/api"
+	"github.com/Sanskarzz/k8sgptclient/k8s-agent/pkg/metrics"
+	"github.com/Sanskarzz/k8sgptclient/k8s-agent/pkg/policy"
+	"github.com/Sanskarzz/k8sgptclient/k8s-agent/pkg/rbac"
+	"github.com/Sanskarzz/k8sgptclient/k8s-agent/pkg/server"
+	"github.com/Sanskarzz/k8sgptclient/k8s-agent/pkg/snapshot"
+	"github.com/go-logr/logr"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
 	"k8s.io/apimachinery/pkg/runtime/serializer/yaml"
+	"k8s.io/apimachinery/pkg/util/wait"
+	utilyaml "k8s.io/apimachinery/pkg/util/yaml"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 	"sigs.k8s.io/controller-runtime/pkg/log"
 )
 
-// ApplyResponse represents the response structure for apply operation
-type ApplyResponse struct {
-	Kind      string `json:"kind"`
-	Name      string `json:"name"`
-	Namespace string `json:"namespace"`
-	Action    string `json:"action"` // "created" or "updated"
+// fieldManager owns the fields set by the agent
+const fieldManager = "k8sgptclient"
+
+// podDeletionTimeout bounds the wait for the graceful deletion of a pod recreated on rollback
+const podDeletionTimeout = 2 * time.Minute
+
+// applyOptions holds the query options of an apply request
+type applyOptions struct {
+	// DryRun validates the apply server-side and returns a diff without persisting anything
+	DryRun bool
+	// Atomic rolls back already applied objects when a later object in the batch fails
+	Atomic bool
+	// Force takes ownership of fields managed by others instead of failing with a conflict
+	Force bool
+}
+
+// appliedObject tracks an object applied in the current batch so it can be rolled back
+type appliedObject struct {
+	index int
+	obj   *unstructured.Unstructured
+	// live is the object before the apply, nil if the apply created it
+	live *unstructured.Unstructured
 }
 
 func (h *ClientHandler) Apply() http.HandlerFunc {
@@ -29,88 +63,428 @@ func (h *ClientHandler) Apply() http.HandlerFunc {
 		if r.Method != http.MethodPost {
 			err := fmt.Errorf("invalid method: %s, allowed: %s", r.Method, http.MethodGet)
 			logger.Error(err, "Method not allowed")
-			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+			server.WriteError(w, r, http.StatusMethodNotAllowed, "Method not allowed")
 			return
 		}
 
+		// Parse query options
+		opts, err := h.parseApplyOptions(r)
+		if err != nil {
+			logger.Error(err, "Invalid query parameters")
+			server.WriteError(w, r, http.StatusBadRequest, err.Error())
+			return
+		}
+		logger = logger.WithValues("dryRun", opts.DryRun, "atomic", opts.Atomic, "force", opts.Force)
+
 		// Read the YAML content
 		body, err := io.ReadAll(r.Body)
 		if err != nil {
 			logger.Error(err, "Failed to read request body")
-			http.Error(w, fmt.Sprintf("Failed to read request body: %v", err), http.StatusBadRequest)
+			server.WriteError(w, r, http.StatusBadRequest, fmt.Sprintf("Failed to read request body: %v", err))
 			return
 		}
 
 		// Log YAML content at debug level
 		logger.V(2).Info("Received YAML content", "yaml", string(body))
 
-		// Decode YAML to unstructured object
+		// Decode every YAML document to unstructured objects
 		logger.V(1).Info("Decoding YAML content")
-		decoder := yaml.NewDecodingSerializer(unstructured.UnstructuredJSONScheme)
-		obj := &unstructured.Unstructured{}
-		_, gvk, err := decoder.Decode(body, nil, obj)
+		objects, err := decodeObjects(body)
 		if err != nil {
 			logger.Error(err, "Failed to decode YAML")
-			http.Error(w, fmt.Sprintf("Failed to decode YAML: %v", err), http.StatusBadRequest)
+			server.WriteError(w, r, http.StatusBadRequest, fmt.Sprintf("Failed to decode YAML: %v", err))
 			return
 		}
-
-		// Set the GVK
-		obj.SetGroupVersionKind(*gvk)
-		logger = logger.WithValues(
-			"kind", obj.GetKind(),
-			"apiVersion", obj.GetAPIVersion(),
-		)
-
-		// Get object metadata
-		metadata, err := meta.Accessor(obj)
-		if err != nil {
-			logger.Error(err, "Failed to get object metadata")
-			http.Error(w, fmt.Sprintf("Failed to get object metadata: %v", err), http.StatusBadRequest)
+		if len(objects) == 0 {
+			err := errors.New("no objects found in request body")
+			logger.Error(err, "Failed to decode YAML")
+			server.WriteError(w, r, http.StatusBadRequest, err.Error())
 			return
 		}
 
-		logger = logger.WithValues(
-			"name", metadata.GetName(),
-			"namespace", metadata.GetNamespace(),
-		)
+		// Apply dependencies (namespaces, config, RBAC) before the workloads using them
+		sortByInstallOrder(objects)
+		logger.Info("Applying resources", "count", len(objects))
 
-		// If namespace is not set, set it to default
-		if metadata.GetNamespace() == "" {
-			obj.SetNamespace("default")
+		// Fetch live objects and check the whole batch against the policy before applying anything
+		responses := make([]api.ApplyResponse, len(objects))
+		lives := make([]*unstructured.Unstructured, len(objects))
+		status := http.StatusOK
+		for i, obj := range objects {
+			live, violations, err := h.prepareObject(r.Context(), obj)
+			switch {
+			case apierrors.IsBadRequest(err):
+				logger.Error(err, "Invalid resource", "kind", obj.GetKind(), "name", obj.GetName())
+				responses[i] = newApplyResponse(obj, "failed")
+				status = setResponseError(&responses[i], "Invalid resource", err)
+			case err != nil:
+				logger.Error(err, "Failed to get live resource", "kind", obj.GetKind(), "name", obj.GetName())
+				responses[i] = newApplyResponse(obj, "failed")
+				status = setResponseError(&responses[i], "Failed to get live resource", err)
+			case len(violations) > 0:
+				logger.Info("Resource denied by policy",
+					"kind", obj.GetKind(),
+					"name", obj.GetName(),
+					"namespace", obj.GetNamespace(),
+					"violations", violations,
+				)
+				responses[i] = newApplyResponse(obj, "denied")
+				responses[i].Violations = violations
+				for _, violation := range violations {
+					metrics.PolicyDenialsTotal.WithLabelValues(obj.GetKind(), obj.GetNamespace(), violation.Rule).Inc()
+				}
+				if status == http.StatusOK {
+					status = http.StatusForbidden
+				}
+			default:
+				responses[i] = newApplyResponse(obj, "skipped")
+				lives[i] = live
+			}
 		}
 
-		logger.Info("Applying resource")
+		// Snapshot live objects so the apply can be rolled back later
+		var revisionID string
+		if status == http.StatusOK && !opts.DryRun && h.Snapshots != nil {
+			revision := snapshot.NewRevision()
+			for i, obj := range objects {
+				revision.Add(obj, lives[i])
+			}
+			if err := h.Snapshots.Save(r.Context(), revision); err != nil {
+				logger.Error(err, "Failed to save snapshot")
+				for i := range responses {
+					responses[i].Error = fmt.Sprintf("Failed to save snapshot: %v", err)
+				}
+				status = http.StatusInternalServerError
+			} else {
+				revisionID = revision.ID
+				logger = logger.WithValues("revision", revisionID)
+				logger.Info("Saved snapshot")
+			}
+		}
 
-		// Set server-side apply field manager
-		if err := h.Client.Patch(r.Context(), obj, client.Apply, &client.PatchOptions{
-			FieldManager: "k8sgptclient",
-			Force:        &[]bool{true}[0],
-		}); err != nil {
-			logger.Error(err, "Failed to apply resource")
-			http.Error(w, fmt.Sprintf("Failed to apply resource: %v", err), http.StatusInternalServerError)
-			return
+		// Apply the batch
+		if status == http.StatusOK {
+			var applied []appliedObject
+			for i, obj := range objects {
+				if status != http.StatusOK && opts.Atomic {
+					break
+				}
+
+				response, err := h.applyObject(r.Context(), logger, obj, lives[i], opts)
+				response.RevisionID = revisionID
+				responses[i] = response
+				if err != nil {
+					if status == http.StatusOK {
+						status = server.StatusCode(err)
+					}
+					if opts.Atomic {
+						h.rollback(r.Context(), logger, applied, responses)
+					}
+					continue
+				}
+				if !opts.DryRun {
+					applied = append(applied, appliedObject{index: i, obj: obj, live: lives[i]})
+				}
+			}
 		}
 
-		logger.Info("Successfully applied resource")
+		// Record every attempted mutation
+		for i, obj := range objects {
+			var after *unstructured.Unstructured
+			switch responses[i].Action {
+			case "skipped":
+				continue
+			case "applied", "dry-run":
+				// obj holds the object as returned by the API server
+				after = obj
+			case "rolled-back":
+				after = lives[i]
+			}
+			h.recordAudit(r, "apply", obj, lives[i], after, responses[i])
+		}
 
-		// Prepare response
-		response := ApplyResponse{
-			Kind:      obj.GetKind(),
-			Name:      metadata.GetName(),
-			Namespace: metadata.GetNamespace(),
-			Action:    "applied",
+		// Count outcomes
+		for i, obj := range objects {
+			metrics.AppliesTotal.WithLabelValues(obj.GetKind(), obj.GetNamespace(), responses[i].Action).Inc()
 		}
 
 		// Set response headers
 		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(status)
 
 		// Write response
-		if err := json.NewEncoder(w).Encode(response); err != nil {
+		if err := json.NewEncoder(w).Encode(responses); err != nil {
 			logger.Error(err, "Failed to encode response")
-			http.Error(w, fmt.Sprintf("Failed to encode response: %v", err), http.StatusInternalServerError)
+			server.WriteAPIError(w, r, "Failed to encode response", err)
 			return
 		}
 		logger.V(1).Info("Response sent successfully")
 	}
 }
+
+// prepareObject resolves the object namespace, checks that it is served, fetches the live object (nil if it does not exist yet),
+// restores redacted values and evaluates the policy
+func (h *ClientHandler) prepareObject(ctx context.Context, obj *unstructured.Unstructured) (*unstructured.Unstructured, []policy.Violation, error) {
+	if err := h.checkApplicable(obj); err != nil {
+		return nil, nil, err
+	}
+	// If namespace is not set on a namespaced object, set it to default unless the policy requires one
+	if obj.GetNamespace() == "" {
+		if namespaced, err := h.Client.IsObjectNamespaced(obj); err != nil || namespaced {
+			if h.Policy != nil && h.Policy.RequireNamespace {
+				return nil, []policy.Violation{{
+					Rule:    "requireNamespace",
+					Message: "namespace must be set explicitly",
+					Path:    "metadata.namespace",
+				}}, nil
+			}
+			obj.SetNamespace(h.defaultNamespace())
+		}
+	}
+	// The agent can't read nor write objects outside of the namespaces it serves
+	if violations := h.namespaceViolations(obj.GetNamespace()); len(violations) > 0 {
+		return nil, violations, nil
+	}
+
+	// Fetch the live object so it can be diffed against, checked by the policy or restored on rollback
+	live := &unstructured.Unstructured{}
+	live.SetGroupVersionKind(obj.GroupVersionKind())
+	if err := h.Client.Get(ctx, client.ObjectKeyFromObject(obj), live); err != nil {
+		if !apierrors.IsNotFound(err) {
+			return nil, nil, err
+		}
+		live = nil
+	}
+
+	// Put back the values masked in the objects returned to the caller before checking them
+	if err := h.restoreRedacted(obj.Object, live); err != nil {
+		return nil, nil, err
+	}
+
+	return live, h.Policy.Evaluate(obj, live), nil
+}
+
+// checkApplicable fails with a bad request for kinds the agent is not granted to apply by rbac.ResourceRules,
+// so that the batch is refused before anything is applied instead of failing halfway with forbidden,
+// e.g. a Namespace or a ConfigMap sorted before the workloads
+func (h *ClientHandler) checkApplicable(obj *unstructured.Unstructured) error {
+	gvk := obj.GroupVersionKind()
+	mapping, err := h.Client.RESTMapper().RESTMapping(gvk.GroupKind(), gvk.Version)
+	if err != nil {
+		return apierrors.NewBadRequest(fmt.Sprintf("unknown kind %s: %v", gvk.String(), err))
+	}
+	if !rbac.Allows(rbac.ResourceRules, mapping.Resource.Group, mapping.Resource.Resource, "patch") {
+		return apierrors.NewBadRequest(fmt.Sprintf("kind %s is not supported, the agent can only apply %s",
+			gvk.Kind, strings.Join(rbac.Resources(rbac.ResourceRules, "patch"), ", ")))
+	}
+	return nil
+}
+
+// applyObject server-side applies a single object, live is the object before the apply (nil if it does not exist)
+func (h *ClientHandler) applyObject(ctx context.Context, logger logr.Logger, obj, live *unstructured.Unstructured, opts applyOptions) (api.ApplyResponse, error) {
+	logger = logger.WithValues(
+		"kind", obj.GetKind(),
+		"apiVersion", obj.GetAPIVersion(),
+		"name", obj.GetName(),
+		"namespace", obj.GetNamespace(),
+	)
+
+	logger.Info("Applying resource")
+
+	// Set server-side apply field manager
+	patchOpts := &client.PatchOptions{
+		FieldManager: fieldManager,
+		Force:        &opts.Force,
+	}
+	if opts.DryRun {
+		patchOpts.DryRun = []string{metav1.DryRunAll}
+	}
+	if err := h.Client.Patch(ctx, obj, client.Apply, patchOpts); err != nil {
+		if conflicts := fieldConflicts(err); len(conflicts) > 0 {
+			logger.Info("Resource conflicts with other field managers", "conflicts", conflicts)
+			response := newApplyResponse(obj, "conflict")
+			setResponseError(&response, "Resource conflicts with other field managers", err)
+			response.Conflicts = conflicts
+			return response, err
+		}
+		logger.Error(err, "Failed to apply resource")
+		response := newApplyResponse(obj, "failed")
+		setResponseError(&response, "Failed to apply resource", err)
+		return response, err
+	}
+
+	if opts.DryRun {
+		// obj now holds the object as the API server would have persisted it
+		response := newApplyResponse(obj, "dry-run")
+		response.DryRun = true
+		response.Diff = h.diffRedacted(live, obj)
+		logger.Info("Successfully dry-ran resource", "changes", len(response.Diff))
+		return response, nil
+	}
+
+	logger.Info("Successfully applied resource")
+	return newApplyResponse(obj, "applied"), nil
+}
+
+// rollback restores the objects applied so far in reverse order. Objects created by the batch
+// are deleted, objects that already existed are updated back to their previous state.
+func (h *ClientHandler) rollback(ctx context.Context, logger logr.Logger, applied []appliedObject, responses []api.ApplyResponse) {
+	for i := len(applied) - 1; i >= 0; i-- {
+		entry := applied[i]
+		logger := logger.WithValues(
+			"kind", entry.obj.GetKind(),
+			"name", entry.obj.GetName(),
+			"namespace", entry.obj.GetNamespace(),
+		)
+		logger.Info("Rolling back resource")
+
+		if err := h.restoreObject(ctx, entry.obj, entry.live); err != nil {
+			logger.Error(err, "Failed to roll back resource")
+			responses[entry.index].Error = fmt.Sprintf("Failed to roll back resource: %v", err)
+			continue
+		}
+		responses[entry.index].Action = "rolled-back"
+	}
+}
+
+// restoreObject restores obj to its previous state. A nil previous state means obj did not exist, so it is deleted.
+func (h *ClientHandler) restoreObject(ctx context.Context, obj, previous *unstructured.Unstructured) error {
+	if previous == nil {
+		return client.IgnoreNotFound(h.Client.Delete(ctx, obj))
+	}
+
+	restored := previous.DeepCopy()
+	// An empty resourceVersion makes the update unconditional
+	restored.SetResourceVersion("")
+	restored.SetUID("")
+	restored.SetManagedFields(nil)
+	err := h.Client.Update(ctx, restored)
+	if apierrors.IsInvalid(err) && isPod(restored) {
+		// most fields of a pod spec are immutable, the previous pod is recreated instead
+		return h.recreatePod(ctx, restored)
+	}
+	if !apierrors.IsNotFound(err) {
+		return err
+	}
+
+	// The object was deleted in the meantime, recreate it
+	restored.SetCreationTimestamp(metav1.Time{})
+	return h.Client.Create(ctx, restored)
+}
+
+// isPod reports whether obj is a pod
+func isPod(obj *unstructured.Unstructured) bool {
+	gvk := obj.GroupVersionKind()
+	return gvk.Group == "" && gvk.Kind == "Pod"
+}
+
+// recreatePod deletes the pod and creates the restored one once the pod is gone, the restored pod
+// keeps the name of the deleted one
+func (h *ClientHandler) recreatePod(ctx context.Context, restored *unstructured.Unstructured) error {
+	if err := client.IgnoreNotFound(h.Client.Delete(ctx, restored)); err != nil {
+		return err
+	}
+	err := wait.PollUntilContextTimeout(ctx, time.Second, podDeletionTimeout, true, func(ctx context.Context) (bool, error) {
+		current := &unstructured.Unstructured{}
+		current.SetGroupVersionKind(restored.GroupVersionKind())
+		err := h.Reader.Get(ctx, client.ObjectKeyFromObject(restored), current)
+		if apierrors.IsNotFound(err) {
+			return true, nil
+		}
+		return false, err
+	})
+	if err != nil {
+		return fmt.Errorf("pod %s/%s was not deleted: %w", restored.GetNamespace(), restored.GetName(), err)
+	}
+
+	restored.SetCreationTimestamp(metav1.Time{})
+	unstructured.RemoveNestedField(restored.Object, "status")
+	return h.Client.Create(ctx, restored)
+}
+
+// parseApplyOptions reads the apply options from the query parameters, force defaults to ForceApply
+func (h *ClientHandler) parseApplyOptions(r *http.Request) (applyOptions, error) {
+	opts := applyOptions{Force: h.ForceApply}
+	for name, target := range map[string]*bool{
+		"dryRun": &opts.DryRun,
+		"atomic": &opts.Atomic,
+		"force":  &opts.Force,
+	} {
+		value := r.URL.Query().Get(name)
+		if value == "" {
+			continue
+		}
+		parsed, err := strconv.ParseBool(value)
+		if err != nil {
+			return opts, fmt.Errorf("invalid %s parameter: %v", name, err)
+		}
+		*target = parsed
+	}
+	return opts, nil
+}
+
+// decodeObjects decodes a multi-document YAML (or JSON) body into unstructured objects,
+// expanding List kinds into their items
+func decodeObjects(body []byte) ([]*unstructured.Unstructured, error) {
+	decoder := yaml.NewDecodingSerializer(unstructured.UnstructuredJSONScheme)
+	reader := utilyaml.NewYAMLReader(bufio.NewReader(bytes.NewReader(body)))
+
+	var objects []*unstructured.Unstructured
+	for {
+		document, err := reader.Read()
+		if err != nil {
+			if err == io.EOF {
+				break
+			}
+			return nil, err
+		}
+		// Skip empty documents, e.g. a leading "---"
+		if len(bytes.TrimSpace(document)) == 0 {
+			continue
+		}
+
+		obj := &unstructured.Unstructured{}
+		_, gvk, err := decoder.Decode(document, nil, obj)
+		if err != nil {
+			return nil, err
+		}
+		obj.SetGroupVersionKind(*gvk)
+
+		if !obj.IsList() {
+			objects = append(objects, obj)
+			continue
+		}
+		list, err := obj.ToList()
+		if err != nil {
+			return nil, err
+		}
+		if err := list.EachListItem(func(item runtime.Object) error {
+			objects = append(objects, item.(*unstructured.Unstructured))
+			return nil
+		}); err != nil {
+			return nil, err
+		}
+	}
+	return objects, nil
+}
+
+func newApplyResponse(obj *unstructured.Unstructured, action string) api.ApplyResponse {
+	return api.ApplyResponse{
+		Kind:      obj.GetKind(),
+		Name:      obj.GetName(),
+		Namespace: obj.GetNamespace(),
+		Action:    action,
+	}
+}
+
+// setResponseError records err on the response, with the reason and causes returned by the API server,
+// and returns the matching HTTP status code
+func setResponseError(response *api.ApplyResponse, message string, err error) int {
+	apiErr := server.NewError(message, err)
+	response.Error = apiErr.Message
+	response.Reason = apiErr.Reason
+	if apiErr.Details != nil {
+		response.Causes = apiErr.Details.Causes
+	}
+	return apiErr.Code
+}
@@ -0,0 +1,131 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/Sanskarzz/k8sgptclient/k8s-agent/api"
+	"github.com/Sanskarzz/k8sgptclient/k8s-agent/pkg/audit"
+	"github.com/Sanskarzz/k8sgptclient/k8s-agent/pkg/auth"
+	"github.com/Sanskarzz/k8sgptclient/k8s-agent/pkg/server"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+)
+
+// recordAudit appends a mutation to the audit log. before and after are the object states
+// around the mutation, nil when the object did not exist or was not changed.
+func (h *ClientHandler) recordAudit(r *http.Request, operation string, obj, before, after *unstructured.Unstructured, response api.ApplyResponse) {
+	if h.Audit == nil {
+		return
+	}
+
+	record := audit.Record{
+		RequestID:  server.RequestIDFromContext(r.Context()),
+		Operation:  operation,
+		APIVersion: obj.GetAPIVersion(),
+		Kind:       obj.GetKind(),
+		Namespace:  obj.GetNamespace(),
+		Name:       obj.GetName(),
+		BeforeHash: audit.Hash(before),
+		AfterHash:  audit.Hash(after),
+		DryRun:     response.DryRun,
+		RevisionID: response.RevisionID,
+		Outcome:    response.Action,
+		Error:      response.Error,
+	}
+	if user, ok := auth.UserFromContext(r.Context()); ok {
+		record.User = user.Name
+		record.Groups = user.Groups
+	}
+	if host, _, err := net.SplitHostPort(r.RemoteAddr); err == nil {
+		record.SourceIP = host
+	}
+
+	if err := h.Audit.Record(record); err != nil {
+		log.FromContext(r.Context()).WithName("audit").Error(err, "Failed to record audit entry",
+			"kind", record.Kind,
+			"name", record.Name,
+			"namespace", record.Namespace,
+		)
+	}
+}
+
+// AuditLog returns a handler for GET /audit endpoint
+func (h *ClientHandler) AuditLog() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		logger := log.FromContext(r.Context()).WithName("audit")
+
+		if r.Method != http.MethodGet {
+			err := fmt.Errorf("invalid method: %s, allowed: %s", r.Method, http.MethodGet)
+			logger.Error(err, "Method not allowed")
+			server.WriteError(w, r, http.StatusMethodNotAllowed, "Method not allowed")
+			return
+		}
+
+		if h.Audit == nil {
+			logger.Info("Audit log is disabled")
+			server.WriteError(w, r, http.StatusNotImplemented, "Audit log is disabled")
+			return
+		}
+
+		// Parse filters from query parameters
+		query := r.URL.Query()
+		filter := audit.Filter{
+			Kind:      query.Get("kind"),
+			Namespace: query.Get("namespace"),
+			Name:      query.Get("name"),
+			User:      query.Get("user"),
+			Operation: query.Get("operation"),
+		}
+		for name, target := range map[string]*time.Time{
+			"since": &filter.Since,
+			"until": &filter.Until,
+		} {
+			if value := query.Get(name); value != "" {
+				parsed, err := time.Parse(time.RFC3339, value)
+				if err != nil {
+					logger.Error(err, "Invalid time parameter", name, value)
+					server.WriteError(w, r, http.StatusBadRequest, fmt.Sprintf("Invalid %s parameter, expected RFC3339: %v", name, err))
+					return
+				}
+				*target = parsed
+			}
+		}
+		if value := query.Get("limit"); value != "" {
+			limit, err := strconv.Atoi(value)
+			if err != nil || limit < 0 {
+				logger.Error(err, "Invalid limit parameter", "limit", value)
+				server.WriteError(w, r, http.StatusBadRequest, fmt.Sprintf("Invalid limit parameter: %s", value))
+				return
+			}
+			filter.Limit = limit
+		}
+
+		logger.Info("Querying audit log", "filter", filter)
+
+		records, skipped, err := h.Audit.Query(filter)
+		if err != nil {
+			logger.Error(err, "Failed to query audit log")
+			server.WriteAPIError(w, r, "Failed to query audit log", err)
+			return
+		}
+		if skipped > 0 {
+			logger.Info("Skipped unparsable audit records", "count", skipped)
+		}
+
+		// Set response headers
+		w.Header().Set("Content-Type", "application/json")
+
+		// Write response
+		if err := json.NewEncoder(w).Encode(records); err != nil {
+			logger.Error(err, "Failed to encode response")
+			server.WriteAPIError(w, r, "Failed to encode response", err)
+			return
+		}
+		logger.V(1).Info("Response sent successfully", "count", len(records))
+	}
+}
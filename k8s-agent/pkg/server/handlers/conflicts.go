@@ -0,0 +1,50 @@
+package handlers
+
+import (
+	"errors"
+	"strconv"
+	"strings"
+
+	"github.com/Sanskarzz/k8sgptclient/k8s-agent/api"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// fieldConflicts returns the fields owned by other managers that made a non-forced apply fail,
+// nil if err is not an apply conflict
+func fieldConflicts(err error) []api.FieldConflict {
+	var status apierrors.APIStatus
+	if !apierrors.IsConflict(err) || !errors.As(err, &status) || status.Status().Details == nil {
+		return nil
+	}
+	var conflicts []api.FieldConflict
+	for _, cause := range status.Status().Details.Causes {
+		if cause.Type != metav1.CauseTypeFieldManagerConflict {
+			continue
+		}
+		conflicts = append(conflicts, api.FieldConflict{
+			Field:   cause.Field,
+			Manager: conflictManager(cause.Message),
+			Message: cause.Message,
+		})
+	}
+	return conflicts
+}
+
+// conflictManager extracts the manager from a conflict message, which the API server formats as
+// `conflict with "manager"`, optionally followed by the subresource, API version and time of the update
+func conflictManager(message string) string {
+	quoted := strings.TrimPrefix(message, "conflict with ")
+	if quoted == message {
+		return ""
+	}
+	prefix, err := strconv.QuotedPrefix(quoted)
+	if err != nil {
+		return ""
+	}
+	manager, err := strconv.Unquote(prefix)
+	if err != nil {
+		return ""
+	}
+	return manager
+}
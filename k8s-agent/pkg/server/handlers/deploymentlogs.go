@@ -0,0 +1,168 @@
+package handlers
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+
+	"github.com/Sanskarzz/k8sgptclient/k8s-agent/pkg/metrics"
+	"github.com/Sanskarzz/k8sgptclient/k8s-agent/pkg/server"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+)
+
+// DeploymentLogs returns a handler for GET /deployments/{namespace}/{deploymentName}/logs endpoint.
+// It merges the logs of every container of every pod of the deployment, each line prefixed with [pod/container].
+func (h *ClientHandler) DeploymentLogs() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		logger := log.FromContext(r.Context()).WithName("deployment-logs")
+
+		// Check if the request method is GET
+		if r.Method != http.MethodGet {
+			err := fmt.Errorf("invalid method: %s, allowed: %s", r.Method, http.MethodGet)
+			logger.Error(err, "Method not allowed")
+			server.WriteError(w, r, http.StatusMethodNotAllowed, "Method not allowed")
+			return
+		}
+
+		// Parse path parameters
+		parts := strings.Split(r.URL.Path, "/")
+		if len(parts) != 5 {
+			err := fmt.Errorf("invalid path: %s, expected: /deployments/{namespace}/{deploymentName}/logs", r.URL.Path)
+			logger.Error(err, "Invalid path")
+			server.WriteError(w, r, http.StatusBadRequest, "Invalid path. Expected: /deployments/{namespace}/{deploymentName}/logs")
+			return
+		}
+		namespace := parts[2]
+		deploymentName := parts[3]
+
+		logger = logger.WithValues(
+			"namespace", namespace,
+			"deployment", deploymentName,
+		)
+		logger.Info("Getting deployment logs")
+		if !h.checkNamespace(w, r, logger, namespace) {
+			return
+		}
+
+		// Set up the pod logs options
+		podLogOpts, err := parsePodLogOptions(r.URL.Query())
+		if err != nil {
+			logger.Error(err, "Invalid log options")
+			server.WriteError(w, r, http.StatusBadRequest, fmt.Sprintf("Invalid log options: %v", err))
+			return
+		}
+
+		// Get deployment
+		var deployment appsv1.Deployment
+		if err := h.Client.Get(r.Context(), types.NamespacedName{
+			Namespace: namespace,
+			Name:      deploymentName,
+		}, &deployment); err != nil {
+			logger.Error(err, "Failed to get deployment")
+			server.WriteAPIError(w, r, "Failed to get deployment", err)
+			return
+		}
+
+		// Get pods for this deployment
+		selector, err := metav1.LabelSelectorAsSelector(deployment.Spec.Selector)
+		if err != nil {
+			logger.Error(err, "Invalid deployment selector")
+			server.WriteAPIError(w, r, "Invalid deployment selector", err)
+			return
+		}
+		var podList corev1.PodList
+		if err := h.Client.List(r.Context(), &podList, &client.ListOptions{
+			Namespace:     namespace,
+			LabelSelector: selector,
+		}); err != nil {
+			logger.Error(err, "Failed to list pods")
+			server.WriteAPIError(w, r, "Failed to list pods", err)
+			return
+		}
+
+		logger.Info("Streaming deployment logs", "pods", len(podList.Items), "follow", podLogOpts.Follow)
+
+		// Set headers for streaming response
+		w.Header().Set("Content-Type", "text/plain")
+		w.Header().Set("Transfer-Encoding", "chunked")
+
+		// Stream every container concurrently so followed logs are merged as they arrive
+		lines := make(chan string)
+		var wg sync.WaitGroup
+		for _, pod := range podList.Items {
+			for _, container := range pod.Spec.Containers {
+				if podLogOpts.Container != "" && podLogOpts.Container != container.Name {
+					continue
+				}
+				opts := podLogOpts.DeepCopy()
+				opts.Container = container.Name
+				wg.Add(1)
+				go func(pod, container string) {
+					defer wg.Done()
+					h.streamPrefixedLogs(r.Context(), namespace, pod, opts, fmt.Sprintf("[%s/%s] ", pod, container), lines)
+				}(pod.Name, container.Name)
+			}
+		}
+		go func() {
+			wg.Wait()
+			close(lines)
+		}()
+
+		// Write lines as they arrive, keep draining on write errors so streams can stop on context cancellation
+		failed := false
+		for line := range lines {
+			if failed {
+				continue
+			}
+			n, err := fmt.Fprint(w, line)
+			metrics.LogBytesTotal.WithLabelValues("deployment").Add(float64(n))
+			if err != nil {
+				logger.Error(err, "Failed to write logs")
+				failed = true
+				continue
+			}
+			if f, ok := w.(http.Flusher); ok {
+				f.Flush()
+			}
+		}
+	}
+}
+
+// streamPrefixedLogs sends the logs of a pod container line by line, each line starting with prefix.
+// Errors are reported inline so a single failing container does not abort the whole stream.
+func (h *ClientHandler) streamPrefixedLogs(ctx context.Context, namespace, pod string, opts *corev1.PodLogOptions, prefix string, lines chan<- string) {
+	send := func(line string) bool {
+		select {
+		case lines <- prefix + line:
+			return true
+		case <-ctx.Done():
+			return false
+		}
+	}
+
+	stream, err := h.Clientset.CoreV1().Pods(namespace).GetLogs(pod, opts).Stream(ctx)
+	if err != nil {
+		send(fmt.Sprintf("Error getting logs: %v\n", err))
+		return
+	}
+	defer stream.Close()
+
+	scanner := bufio.NewScanner(stream)
+	scanner.Buffer(make([]byte, 0, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		if !send(scanner.Text() + "\n") {
+			return
+		}
+	}
+	if err := scanner.Err(); err != nil && ctx.Err() == nil {
+		send(fmt.Sprintf("Error reading logs: %v\n", err))
+	}
+}
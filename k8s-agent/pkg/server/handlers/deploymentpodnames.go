@@ -6,6 +6,8 @@ import (
 	"net/http"
 	"strings"
 
+	"github.com/Sanskarzz/k8sgptclient/k8s-agent/api"
+	"github.com/Sanskarzz/k8sgptclient/k8s-agent/pkg/server"
 	appsv1 "k8s.io/api/apps/v1"
 	corev1 "k8s.io/api/core/v1"
 	"k8s.io/apimachinery/pkg/labels"
@@ -14,12 +16,6 @@ import (
 	"sigs.k8s.io/controller-runtime/pkg/log"
 )
 
-type DeploymentPods struct {
-	Name      string   `json:"name"`
-	Namespace string   `json:"namespace"`
-	PodNames  []string `json:"podNames"`
-}
-
 // DeploymentPodNames returns a handler for GET /deployments/{namespace}/{deploymentName}/pods endpoint
 func (h *ClientHandler) DeploymentPodNames() http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
@@ -30,7 +26,7 @@ func (h *ClientHandler) DeploymentPodNames() http.HandlerFunc {
 		if len(parts) != 5 {
 			err := fmt.Errorf("invalid path: %s, expected: /deployments/{namespace}/{deploymentName}/pods", r.URL.Path)
 			logger.Error(err, "Invalid path")
-			http.Error(w, "Invalid path", http.StatusBadRequest)
+			server.WriteError(w, r, http.StatusBadRequest, "Invalid path")
 			return
 		}
 
@@ -42,6 +38,9 @@ func (h *ClientHandler) DeploymentPodNames() http.HandlerFunc {
 			"deployment", deploymentName,
 		)
 		logger.Info("Getting deployment pod names")
+		if !h.checkNamespace(w, r, logger, namespace) {
+			return
+		}
 
 		// Get deployment
 		var deployment appsv1.Deployment
@@ -50,7 +49,7 @@ func (h *ClientHandler) DeploymentPodNames() http.HandlerFunc {
 			Name:      deploymentName,
 		}, &deployment); err != nil {
 			logger.Error(err, "Failed to get deployment")
-			http.Error(w, fmt.Sprintf("Failed to get deployment: %v", err), http.StatusInternalServerError)
+			server.WriteAPIError(w, r, "Failed to get deployment", err)
 			return
 		}
 
@@ -62,12 +61,12 @@ func (h *ClientHandler) DeploymentPodNames() http.HandlerFunc {
 			LabelSelector: labelSelector,
 		}); err != nil {
 			logger.Error(err, "Failed to list pods")
-			http.Error(w, fmt.Sprintf("Failed to list pods: %v", err), http.StatusInternalServerError)
+			server.WriteAPIError(w, r, "Failed to list pods", err)
 			return
 		}
 
 		// Create response
-		response := DeploymentPods{
+		response := api.DeploymentPods{
 			Name:      deploymentName,
 			Namespace: namespace,
 			PodNames:  make([]string, 0, len(podList.Items)),
@@ -84,7 +83,7 @@ func (h *ClientHandler) DeploymentPodNames() http.HandlerFunc {
 		// Write response
 		if err := json.NewEncoder(w).Encode(response); err != nil {
 			logger.Error(err, "Failed to encode response")
-			http.Error(w, fmt.Sprintf("Failed to encode response: %v", err), http.StatusInternalServerError)
+			server.WriteAPIError(w, r, "Failed to encode response", err)
 			return
 		}
 
@@ -0,0 +1,125 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/Sanskarzz/k8sgptclient/k8s-agent/api"
+	"github.com/Sanskarzz/k8sgptclient/k8s-agent/pkg/server"
+	appsv1 "k8s.io/api/apps/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+)
+
+// timedOutReason is the Progressing condition reason set when a deployment exceeds its progress deadline
+const timedOutReason = "ProgressDeadlineExceeded"
+
+// rolloutStatus computes the rollout status of a deployment the way kubectl rollout status does
+func rolloutStatus(deployment *appsv1.Deployment) api.DeploymentRolloutStatus {
+	status := api.DeploymentRolloutStatus{
+		Name:                deployment.Name,
+		Namespace:           deployment.Namespace,
+		Generation:          deployment.Generation,
+		ObservedGeneration:  deployment.Status.ObservedGeneration,
+		Replicas:            1,
+		UpdatedReplicas:     deployment.Status.UpdatedReplicas,
+		ReadyReplicas:       deployment.Status.ReadyReplicas,
+		AvailableReplicas:   deployment.Status.AvailableReplicas,
+		UnavailableReplicas: deployment.Status.UnavailableReplicas,
+		Conditions:          deployment.Status.Conditions,
+	}
+	if deployment.Spec.Replicas != nil {
+		status.Replicas = *deployment.Spec.Replicas
+	}
+
+	// The status only describes the latest spec once the controller observed it
+	if deployment.Generation > deployment.Status.ObservedGeneration {
+		status.Message = "Waiting for deployment spec update to be observed..."
+		return status
+	}
+
+	for _, condition := range deployment.Status.Conditions {
+		if condition.Type == appsv1.DeploymentProgressing && condition.Reason == timedOutReason {
+			status.Failed = true
+			status.Message = fmt.Sprintf("deployment %q exceeded its progress deadline", deployment.Name)
+			return status
+		}
+	}
+
+	switch {
+	case deployment.Status.UpdatedReplicas < status.Replicas:
+		status.Message = fmt.Sprintf("Waiting for deployment %q rollout to finish: %d out of %d new replicas have been updated...",
+			deployment.Name, deployment.Status.UpdatedReplicas, status.Replicas)
+	case deployment.Status.Replicas > deployment.Status.UpdatedReplicas:
+		status.Message = fmt.Sprintf("Waiting for deployment %q rollout to finish: %d old replicas are pending termination...",
+			deployment.Name, deployment.Status.Replicas-deployment.Status.UpdatedReplicas)
+	case deployment.Status.AvailableReplicas < deployment.Status.UpdatedReplicas:
+		status.Message = fmt.Sprintf("Waiting for deployment %q rollout to finish: %d of %d updated replicas are available...",
+			deployment.Name, deployment.Status.AvailableReplicas, deployment.Status.UpdatedReplicas)
+	default:
+		status.Done = true
+		status.Message = fmt.Sprintf("deployment %q successfully rolled out", deployment.Name)
+	}
+	return status
+}
+
+// DeploymentStatus returns a handler for GET /deployments/{namespace}/{deploymentName}/status endpoint
+func (h *ClientHandler) DeploymentStatus() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		logger := log.FromContext(r.Context()).WithName("deployment-status")
+
+		if r.Method != http.MethodGet {
+			err := fmt.Errorf("invalid method: %s, allowed: %s", r.Method, http.MethodGet)
+			logger.Error(err, "Method not allowed")
+			server.WriteError(w, r, http.StatusMethodNotAllowed, "Method not allowed")
+			return
+		}
+
+		// Parse path parameters
+		parts := strings.Split(r.URL.Path, "/")
+		if len(parts) != 5 {
+			err := fmt.Errorf("invalid path: %s, expected: /deployments/{namespace}/{deploymentName}/status", r.URL.Path)
+			logger.Error(err, "Invalid path")
+			server.WriteError(w, r, http.StatusBadRequest, "Invalid path. Expected: /deployments/{namespace}/{deploymentName}/status")
+			return
+		}
+		namespace := parts[2]
+		deploymentName := parts[3]
+
+		logger = logger.WithValues(
+			"namespace", namespace,
+			"deployment", deploymentName,
+		)
+		logger.Info("Getting deployment rollout status")
+		if !h.checkNamespace(w, r, logger, namespace) {
+			return
+		}
+
+		// Get deployment
+		var deployment appsv1.Deployment
+		if err := h.Client.Get(r.Context(), types.NamespacedName{
+			Namespace: namespace,
+			Name:      deploymentName,
+		}, &deployment); err != nil {
+			logger.Error(err, "Failed to get deployment")
+			server.WriteAPIError(w, r, "Failed to get deployment", err)
+			return
+		}
+
+		status := rolloutStatus(&deployment)
+		logger.V(1).Info("Computed rollout status", "done", status.Done, "failed", status.Failed)
+
+		// Set response headers
+		w.Header().Set("Content-Type", "application/json")
+
+		// Write response
+		if err := json.NewEncoder(w).Encode(status); err != nil {
+			logger.Error(err, "Failed to encode response")
+			server.WriteAPIError(w, r, "Failed to encode response", err)
+			return
+		}
+		logger.V(1).Info("Response sent successfully")
+	}
+}
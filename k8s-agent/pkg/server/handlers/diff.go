@@ -0,0 +1,117 @@
+package handlers
+
+import (
+	"fmt"
+	"reflect"
+	"sort"
+
+	"github.com/Sanskarzz/k8sgptclient/k8s-agent/api"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+// ignoredDiffPaths are server-managed fields that change on every write and carry no meaning for a diff
+var ignoredDiffPaths = map[string]bool{
+	"status":                     true,
+	"metadata.managedFields":     true,
+	"metadata.resourceVersion":   true,
+	"metadata.generation":        true,
+	"metadata.uid":               true,
+	"metadata.creationTimestamp": true,
+}
+
+// diffObjects returns the list of fields that differ between the live object and the dry-run result.
+// A nil live object means the resource does not exist yet, so every field is reported as added.
+func diffObjects(live, dryRun *unstructured.Unstructured) []api.FieldDiff {
+	var liveContent, dryRunContent map[string]interface{}
+	if live != nil {
+		liveContent = live.Object
+	}
+	if dryRun != nil {
+		dryRunContent = dryRun.Object
+	}
+	diffs := []api.FieldDiff{}
+	diffValues("", liveContent, dryRunContent, &diffs)
+	return diffs
+}
+
+func diffValues(path string, live, dryRun interface{}, diffs *[]api.FieldDiff) {
+	if ignoredDiffPaths[path] {
+		return
+	}
+
+	switch {
+	case live == nil && dryRun == nil:
+		return
+	case live == nil:
+		*diffs = append(*diffs, api.FieldDiff{Path: path, Op: "add", DryRun: pruneIgnored(path, dryRun)})
+		return
+	case dryRun == nil:
+		*diffs = append(*diffs, api.FieldDiff{Path: path, Op: "remove", Live: pruneIgnored(path, live)})
+		return
+	}
+
+	liveMap, liveIsMap := live.(map[string]interface{})
+	dryRunMap, dryRunIsMap := dryRun.(map[string]interface{})
+	if liveIsMap && dryRunIsMap {
+		// Walk keys in a stable order so the response is deterministic
+		keys := make([]string, 0, len(liveMap)+len(dryRunMap))
+		for key := range liveMap {
+			keys = append(keys, key)
+		}
+		for key := range dryRunMap {
+			if _, ok := liveMap[key]; !ok {
+				keys = append(keys, key)
+			}
+		}
+		sort.Strings(keys)
+		for _, key := range keys {
+			diffValues(joinPath(path, key), liveMap[key], dryRunMap[key], diffs)
+		}
+		return
+	}
+
+	liveList, liveIsList := live.([]interface{})
+	dryRunList, dryRunIsList := dryRun.([]interface{})
+	if liveIsList && dryRunIsList {
+		for i := 0; i < len(liveList) || i < len(dryRunList); i++ {
+			var liveItem, dryRunItem interface{}
+			if i < len(liveList) {
+				liveItem = liveList[i]
+			}
+			if i < len(dryRunList) {
+				dryRunItem = dryRunList[i]
+			}
+			diffValues(fmt.Sprintf("%s[%d]", path, i), liveItem, dryRunItem, diffs)
+		}
+		return
+	}
+
+	if !reflect.DeepEqual(live, dryRun) {
+		*diffs = append(*diffs, api.FieldDiff{Path: path, Op: "replace", Live: live, DryRun: dryRun})
+	}
+}
+
+// pruneIgnored returns a copy of a value added or removed at path without its ignored fields,
+// e.g. the status and server-managed metadata of a created object
+func pruneIgnored(path string, value interface{}) interface{} {
+	fields, ok := value.(map[string]interface{})
+	if !ok {
+		return value
+	}
+	pruned := make(map[string]interface{}, len(fields))
+	for key, item := range fields {
+		itemPath := joinPath(path, key)
+		if ignoredDiffPaths[itemPath] {
+			continue
+		}
+		pruned[key] = pruneIgnored(itemPath, item)
+	}
+	return pruned
+}
+
+func joinPath(path, key string) string {
+	if path == "" {
+		return key
+	}
+	return path + "." + key
+}
@@ -0,0 +1,247 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+
+	"github.com/Sanskarzz/k8sgptclient/k8s-agent/api"
+	"github.com/Sanskarzz/k8sgptclient/k8s-agent/pkg/server"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/fields"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+)
+
+// ownedKinds lists the kinds whose events are collected together with their owner
+var ownedKinds = map[string][]schema.GroupVersionKind{
+	"Deployment":  {{Group: "apps", Version: "v1", Kind: "ReplicaSet"}},
+	"ReplicaSet":  {{Version: "v1", Kind: "Pod"}},
+	"StatefulSet": {{Version: "v1", Kind: "Pod"}},
+	"DaemonSet":   {{Version: "v1", Kind: "Pod"}},
+	"CronJob":     {{Group: "batch", Version: "v1", Kind: "Job"}},
+	"Job":         {{Version: "v1", Kind: "Pod"}},
+}
+
+// eventKind returns true for the kinds whose events are served: pods and the owners of ownedKinds
+func eventKind(gvk schema.GroupVersionKind) bool {
+	switch gvk.GroupKind() {
+	case schema.GroupKind{Kind: "Pod"},
+		schema.GroupKind{Group: "apps", Kind: "Deployment"},
+		schema.GroupKind{Group: "apps", Kind: "ReplicaSet"},
+		schema.GroupKind{Group: "apps", Kind: "StatefulSet"},
+		schema.GroupKind{Group: "apps", Kind: "DaemonSet"},
+		schema.GroupKind{Group: "batch", Kind: "CronJob"},
+		schema.GroupKind{Group: "batch", Kind: "Job"}:
+		return true
+	}
+	return false
+}
+
+// Events returns a handler for GET /events/{namespace}/{kind}/{name} endpoint
+func (h *ClientHandler) Events() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		logger := log.FromContext(r.Context()).WithName("events")
+
+		if r.Method != http.MethodGet {
+			err := fmt.Errorf("invalid method: %s, allowed: %s", r.Method, http.MethodGet)
+			logger.Error(err, "Method not allowed")
+			server.WriteError(w, r, http.StatusMethodNotAllowed, "Method not allowed")
+			return
+		}
+
+		// Parse path parameters
+		parts := strings.Split(r.URL.Path, "/")
+		if len(parts) != 5 || parts[2] == "" || parts[3] == "" || parts[4] == "" {
+			err := fmt.Errorf("invalid path: %s, expected: /events/{namespace}/{kind}/{name}", r.URL.Path)
+			logger.Error(err, "Invalid path")
+			server.WriteError(w, r, http.StatusBadRequest, "Invalid path. Expected: /events/{namespace}/{kind}/{name}")
+			return
+		}
+		namespace := parts[2]
+		kind := parts[3]
+		name := parts[4]
+
+		logger = logger.WithValues(
+			"namespace", namespace,
+			"kind", kind,
+			"name", name,
+		)
+		logger.Info("Getting events")
+		if !h.checkNamespace(w, r, logger, namespace) {
+			return
+		}
+
+		// Resolve the kind, accepting kinds and resource names in any case (Deployment, deployment, deployments)
+		gvk, err := h.Client.RESTMapper().KindFor(schema.GroupVersionResource{Resource: strings.ToLower(kind)})
+		if err != nil {
+			logger.Error(err, "Unknown kind")
+			server.WriteError(w, r, http.StatusBadRequest, fmt.Sprintf("Unknown kind %s: %v", kind, err))
+			return
+		}
+		if !eventKind(gvk) {
+			err := fmt.Errorf("unsupported kind: %s", gvk.Kind)
+			logger.Error(err, "Unsupported kind")
+			server.WriteError(w, r, http.StatusBadRequest,
+				fmt.Sprintf("Unsupported kind %s. Allowed: Pod, Deployment, ReplicaSet, StatefulSet, DaemonSet, Job, CronJob", gvk.Kind))
+			return
+		}
+
+		// Get the object from the API server, the cache would start an informer for its kind
+		obj := &metav1.PartialObjectMetadata{}
+		obj.SetGroupVersionKind(gvk)
+		if err := h.Reader.Get(r.Context(), client.ObjectKey{Namespace: namespace, Name: name}, obj); err != nil {
+			if apierrors.IsNotFound(err) {
+				logger.Info("Object not found")
+			} else {
+				logger.Error(err, "Failed to get object")
+			}
+			server.WriteAPIError(w, r, "Failed to get object", err)
+			return
+		}
+
+		// Collect the object and its owned children
+		objects, err := h.ownedObjects(r.Context(), obj)
+		if err != nil {
+			logger.Error(err, "Failed to list owned objects")
+			server.WriteAPIError(w, r, "Failed to list owned objects", err)
+			return
+		}
+
+		// Collect the events of every object
+		var events []corev1.Event
+		for _, object := range objects {
+			list, err := h.Clientset.CoreV1().Events(namespace).List(r.Context(), metav1.ListOptions{
+				FieldSelector: fields.Set{
+					"involvedObject.kind": object.GetObjectKind().GroupVersionKind().Kind,
+					"involvedObject.name": object.GetName(),
+				}.String(),
+			})
+			if err != nil {
+				logger.Error(err, "Failed to list events", "object", object.GetName())
+				server.WriteAPIError(w, r, "Failed to list events", err)
+				return
+			}
+			events = append(events, list.Items...)
+		}
+
+		response := api.ObjectEvents{
+			Kind:      gvk.Kind,
+			Name:      name,
+			Namespace: namespace,
+			Events:    mergeEvents(events),
+		}
+		logger.Info("Successfully collected events", "objects", len(objects), "events", len(response.Events))
+
+		// Set response headers
+		w.Header().Set("Content-Type", "application/json")
+
+		// Write response
+		if err := json.NewEncoder(w).Encode(response); err != nil {
+			logger.Error(err, "Failed to encode response")
+			server.WriteAPIError(w, r, "Failed to encode response", err)
+			return
+		}
+		logger.V(1).Info("Response sent successfully")
+	}
+}
+
+// ownedObjects returns the object followed by the objects it owns, recursively, following ownedKinds
+func (h *ClientHandler) ownedObjects(ctx context.Context, owner *metav1.PartialObjectMetadata) ([]*metav1.PartialObjectMetadata, error) {
+	objects := []*metav1.PartialObjectMetadata{owner}
+	for _, gvk := range ownedKinds[owner.GroupVersionKind().Kind] {
+		list := &metav1.PartialObjectMetadataList{}
+		list.SetGroupVersionKind(gvk.GroupVersion().WithKind(gvk.Kind + "List"))
+		if err := h.Reader.List(ctx, list, client.InNamespace(owner.GetNamespace())); err != nil {
+			return nil, fmt.Errorf("failed to list %s: %w", gvk.Kind, err)
+		}
+		for i := range list.Items {
+			child := &list.Items[i]
+			if !metav1.IsControlledBy(child, owner) {
+				continue
+			}
+			// items of metadata lists do not carry their kind
+			child.SetGroupVersionKind(gvk)
+			children, err := h.ownedObjects(ctx, child)
+			if err != nil {
+				return nil, err
+			}
+			objects = append(objects, children...)
+		}
+	}
+	return objects, nil
+}
+
+// mergeEvents de-duplicates events of the same object, type, reason and message, adding up their counts.
+// The result is sorted by last occurrence.
+func mergeEvents(events []corev1.Event) []api.Event {
+	merged := map[string]*api.Event{}
+	for _, event := range events {
+		first, last := event.FirstTimestamp.Time, event.LastTimestamp.Time
+		// events created through the events.k8s.io API only set eventTime and series
+		if first.IsZero() {
+			first = event.EventTime.Time
+		}
+		if last.IsZero() {
+			last = first
+			if event.Series != nil {
+				last = event.Series.LastObservedTime.Time
+			}
+		}
+		count := event.Count
+		if count == 0 {
+			count = 1
+			if event.Series != nil {
+				count = event.Series.Count
+			}
+		}
+		source := event.Source.Component
+		if source == "" {
+			source = event.ReportingController
+		}
+
+		object := event.InvolvedObject.Kind + "/" + event.InvolvedObject.Name
+		key := strings.Join([]string{object, event.Type, event.Reason, event.Message}, "\x00")
+		if existing, ok := merged[key]; ok {
+			existing.Count += count
+			if first.Before(existing.FirstTimestamp) {
+				existing.FirstTimestamp = first
+			}
+			if last.After(existing.LastTimestamp) {
+				existing.LastTimestamp = last
+			}
+			continue
+		}
+		merged[key] = &api.Event{
+			Object:         object,
+			Type:           event.Type,
+			Reason:         event.Reason,
+			Message:        event.Message,
+			Count:          count,
+			FirstTimestamp: first,
+			LastTimestamp:  last,
+			Source:         source,
+		}
+	}
+
+	result := make([]api.Event, 0, len(merged))
+	for _, event := range merged {
+		result = append(result, *event)
+	}
+	sort.Slice(result, func(i, j int) bool {
+		if !result[i].LastTimestamp.Equal(result[j].LastTimestamp) {
+			return result[i].LastTimestamp.Before(result[j].LastTimestamp)
+		}
+		if result[i].Object != result[j].Object {
+			return result[i].Object < result[j].Object
+		}
+		return result[i].Reason < result[j].Reason
+	})
+	return result
+}
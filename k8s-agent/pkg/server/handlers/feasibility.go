@@ -0,0 +1,198 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+
+	"github.com/Sanskarzz/k8sgptclient/k8s-agent/api"
+	"github.com/Sanskarzz/k8sgptclient/k8s-agent/pkg/feasibility"
+	"github.com/Sanskarzz/k8sgptclient/k8s-agent/pkg/server"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+)
+
+// podTemplatePaths maps the kinds holding a pod template to its location, Pods being their own template
+var podTemplatePaths = map[string][]string{
+	"Pod":                   {},
+	"PodTemplate":           {"template"},
+	"Deployment":            {"spec", "template"},
+	"ReplicaSet":            {"spec", "template"},
+	"ReplicationController": {"spec", "template"},
+	"StatefulSet":           {"spec", "template"},
+	"DaemonSet":             {"spec", "template"},
+	"Job":                   {"spec", "template"},
+	"CronJob":               {"spec", "jobTemplate", "spec", "template"},
+}
+
+// Feasibility returns a handler for POST /feasibility endpoint.
+// It accepts a YAML manifest and reports, for every object with a pod template, whether its pods can be scheduled:
+// the nodes they fit and why the others don't, and the LimitRange and ResourceQuota limits they exceed.
+func (h *ClientHandler) Feasibility() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		logger := log.FromContext(r.Context()).WithName("feasibility")
+
+		if r.Method != http.MethodPost {
+			err := fmt.Errorf("invalid method: %s, allowed: %s", r.Method, http.MethodPost)
+			logger.Error(err, "Method not allowed")
+			server.WriteError(w, r, http.StatusMethodNotAllowed, "Method not allowed")
+			return
+		}
+
+		// Read the YAML content
+		body, err := io.ReadAll(r.Body)
+		if err != nil {
+			logger.Error(err, "Failed to read request body")
+			server.WriteError(w, r, http.StatusBadRequest, fmt.Sprintf("Failed to read request body: %v", err))
+			return
+		}
+		logger.V(2).Info("Received YAML content", "yaml", string(body))
+
+		// Decode the pods of every object with a pod template
+		objects, err := decodeObjects(body)
+		if err != nil {
+			logger.Error(err, "Failed to decode YAML")
+			server.WriteError(w, r, http.StatusBadRequest, fmt.Sprintf("Failed to decode YAML: %v", err))
+			return
+		}
+		var sources []*unstructured.Unstructured
+		var pods []*corev1.Pod
+		for _, obj := range objects {
+			pod, err := templatePod(obj)
+			if err != nil {
+				logger.Error(err, "Invalid pod template", "kind", obj.GetKind(), "name", obj.GetName())
+				server.WriteError(w, r, http.StatusBadRequest, fmt.Sprintf("Invalid pod template of %s %s: %v", obj.GetKind(), obj.GetName(), err))
+				return
+			}
+			if pod == nil {
+				logger.V(1).Info("Skipping object without pod template", "kind", obj.GetKind(), "name", obj.GetName())
+				continue
+			}
+			if pod.Namespace == "" {
+				pod.Namespace = h.defaultNamespace()
+			}
+			if !h.checkNamespace(w, r, logger, pod.Namespace) {
+				return
+			}
+			sources = append(sources, obj)
+			pods = append(pods, pod)
+		}
+		if len(pods) == 0 {
+			err := errors.New("no pod template found in request body")
+			logger.Error(err, "Nothing to check")
+			server.WriteError(w, r, http.StatusBadRequest, err.Error())
+			return
+		}
+		logger.Info("Checking scheduling feasibility", "count", len(pods))
+
+		// Read the nodes and the pods bound to them, straight from the API server as the cache holds neither
+		nodes, err := h.Clientset.CoreV1().Nodes().List(r.Context(), metav1.ListOptions{})
+		if err != nil {
+			logger.Error(err, "Failed to list nodes")
+			server.WriteAPIError(w, r, "Failed to list nodes", err)
+			return
+		}
+		running, err := h.Clientset.CoreV1().Pods(metav1.NamespaceAll).List(r.Context(), metav1.ListOptions{
+			FieldSelector: "status.phase!=Succeeded,status.phase!=Failed",
+		})
+		if err != nil {
+			logger.Error(err, "Failed to list pods")
+			server.WriteAPIError(w, r, "Failed to list pods", err)
+			return
+		}
+
+		// Check every pod against the nodes and the limits of its namespace
+		clusters := map[string]feasibility.Cluster{}
+		reports := make([]api.FeasibilityReport, 0, len(pods))
+		for i, pod := range pods {
+			cluster, ok := clusters[pod.Namespace]
+			if !ok {
+				cluster, err = h.namespaceLimits(r.Context(), pod.Namespace)
+				if err != nil {
+					logger.Error(err, "Failed to get namespace limits", "namespace", pod.Namespace)
+					server.WriteAPIError(w, r, "Failed to get namespace limits", err)
+					return
+				}
+				cluster.Nodes = nodes.Items
+				cluster.Pods = running.Items
+				clusters[pod.Namespace] = cluster
+			}
+
+			report := feasibility.Check(pod, cluster)
+			report.Kind = sources[i].GetKind()
+			report.Name = sources[i].GetName()
+			logger.Info("Checked scheduling feasibility",
+				"kind", report.Kind,
+				"name", report.Name,
+				"namespace", report.Namespace,
+				"feasible", report.Feasible,
+				"message", report.Message,
+			)
+			reports = append(reports, report)
+		}
+
+		// Set response headers
+		w.Header().Set("Content-Type", "application/json")
+
+		// Write response
+		if err := json.NewEncoder(w).Encode(reports); err != nil {
+			logger.Error(err, "Failed to encode response")
+			server.WriteAPIError(w, r, "Failed to encode response", err)
+			return
+		}
+		logger.V(1).Info("Response sent successfully")
+	}
+}
+
+// namespaceLimits reads the LimitRanges and ResourceQuotas of a namespace
+func (h *ClientHandler) namespaceLimits(ctx context.Context, namespace string) (feasibility.Cluster, error) {
+	limitRanges, err := h.Clientset.CoreV1().LimitRanges(namespace).List(ctx, metav1.ListOptions{})
+	if err != nil {
+		return feasibility.Cluster{}, err
+	}
+	quotas, err := h.Clientset.CoreV1().ResourceQuotas(namespace).List(ctx, metav1.ListOptions{})
+	if err != nil {
+		return feasibility.Cluster{}, err
+	}
+	return feasibility.Cluster{
+		LimitRanges:    limitRanges.Items,
+		ResourceQuotas: quotas.Items,
+	}, nil
+}
+
+// templatePod returns the pod created from the pod template of obj, nil when obj has no pod template
+func templatePod(obj *unstructured.Unstructured) (*corev1.Pod, error) {
+	path, ok := podTemplatePaths[obj.GetKind()]
+	if !ok {
+		return nil, nil
+	}
+	content := obj.Object
+	if len(path) > 0 {
+		template, found, err := unstructured.NestedMap(obj.Object, path...)
+		if err != nil {
+			return nil, err
+		}
+		if !found {
+			return nil, fmt.Errorf("%s has no pod template", obj.GetKind())
+		}
+		content = template
+	}
+
+	var template corev1.PodTemplateSpec
+	if err := runtime.DefaultUnstructuredConverter.FromUnstructured(content, &template); err != nil {
+		return nil, err
+	}
+	pod := &corev1.Pod{
+		ObjectMeta: template.ObjectMeta,
+		Spec:       template.Spec,
+	}
+	pod.Name = obj.GetName()
+	pod.Namespace = obj.GetNamespace()
+	return pod, nil
+}
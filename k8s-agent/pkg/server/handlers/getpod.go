@@ -1,11 +1,11 @@
 package handlers
 
 import (
-	"encoding/json"
 	"fmt"
 	"net/http"
 	"strings"
 
+	"github.com/Sanskarzz/k8sgptclient/k8s-agent/pkg/server"
 	corev1 "k8s.io/api/core/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"sigs.k8s.io/controller-runtime/pkg/client"
@@ -21,14 +21,14 @@ func (h *ClientHandler) PodYaml() http.HandlerFunc {
 		if r.Method != http.MethodGet {
 			err := fmt.Errorf("invalid method: %s, allowed: %s", r.Method, http.MethodGet)
 			logger.Error(err, "Method not allowed")
-			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+			server.WriteError(w, r, http.StatusMethodNotAllowed, "Method not allowed")
 			return
 		}
 
 		// Extract path parameters
 		parts := strings.Split(r.URL.Path, "/")
 		if len(parts) != 5 {
-			http.Error(w, "invalid path format", http.StatusBadRequest)
+			server.WriteError(w, r, http.StatusBadRequest, "invalid path format")
 			return
 		}
 
@@ -37,8 +37,11 @@ func (h *ClientHandler) PodYaml() http.HandlerFunc {
 
 		logger.Info("Getting pod", "namespace", namespace, "name", name)
 		if namespace == "" {
-			namespace = "default"
-			logger.V(1).Info("No namespace provided, using default")
+			namespace = h.defaultNamespace()
+			logger.V(1).Info("No namespace provided, using default", "namespace", namespace)
+		}
+		if !h.checkNamespace(w, r, logger, namespace) {
+			return
 		}
 
 		// Get pod
@@ -49,7 +52,7 @@ func (h *ClientHandler) PodYaml() http.HandlerFunc {
 		}, pod)
 		if err != nil {
 			logger.Error(err, "Failed to get pod")
-			http.Error(w, err.Error(), http.StatusInternalServerError)
+			server.WriteAPIError(w, r, "Failed to get pod", err)
 			return
 		}
 
@@ -67,15 +70,16 @@ func (h *ClientHandler) PodYaml() http.HandlerFunc {
 		}
 
 		// Convert to YAML
-		jsonData, err := json.Marshal(simplifiedPod)
+		jsonData, redacted, err := h.marshalRedacted(simplifiedPod)
 		if err != nil {
-			http.Error(w, fmt.Sprintf("failed to marshal pod: %v", err), http.StatusInternalServerError)
+			server.WriteAPIError(w, r, "failed to marshal pod", err)
 			return
 		}
+		logger.V(1).Info("Redacted sensitive values", "count", redacted)
 
 		yamlData, err := yaml.JSONToYAML(jsonData)
 		if err != nil {
-			http.Error(w, fmt.Sprintf("failed to convert to yaml: %v", err), http.StatusInternalServerError)
+			server.WriteAPIError(w, r, "failed to convert to yaml", err)
 			return
 		}
 
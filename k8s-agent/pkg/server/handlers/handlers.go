@@ -1,17 +1,123 @@
 package handlers
 
 import (
+	"github.com/Sanskarzz/k8sgptclient/k8s-agent/pkg/audit"
+	"github.com/Sanskarzz/k8sgptclient/k8s-agent/pkg/policy"
+	"github.com/Sanskarzz/k8sgptclient/k8s-agent/pkg/redact"
+	"github.com/Sanskarzz/k8sgptclient/k8s-agent/pkg/registry"
+	"github.com/Sanskarzz/k8sgptclient/k8s-agent/pkg/snapshot"
+	"k8s.io/client-go/kubernetes"
+	"sigs.k8s.io/controller-runtime/pkg/cache"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 )
 
-// ClientHandler holds the Kubernetes client
+// ClientHandler holds the Kubernetes clients
 type ClientHandler struct {
 	Client client.Client
+	// Reader reads from the API server without the cache, for kinds the cache should not start informers for.
+	// nil uses Client.
+	Reader client.Reader
+	// Clientset serves the APIs not covered by Client, like pod log streams
+	Clientset kubernetes.Interface
+	// Policy restricts what can be applied, nil allows everything
+	Policy *policy.Policy
+	// Snapshots stores the state of objects before they are applied, nil disables snapshots and rollback
+	Snapshots snapshot.Store
+	// Audit records every mutation, nil disables auditing
+	Audit *audit.Log
+	// Informers back the watch streams, nil disables watches
+	Informers cache.Informers
+	// ForceApply makes applies take ownership of fields managed by others by default,
+	// otherwise they fail with the conflicting fields unless the request sets force=true
+	ForceApply bool
+	// Redactor masks sensitive values in the YAML returned to callers and restores them on apply and patch,
+	// nil returns values as they are
+	Redactor *redact.Redactor
+	// Namespaces restricts every endpoint to the objects of these namespaces, cluster scoped objects excluded,
+	// empty serves all namespaces and cluster scoped objects
+	Namespaces []string
+	// Registry verifies images against their registries, nil disables image verification
+	Registry *registry.Client
 }
 
-// NewClientHandler creates a new ClientHandler
-func NewClientHandler(client client.Client) *ClientHandler {
-	return &ClientHandler{
-		Client: client,
+// Option configures a ClientHandler
+type Option func(*ClientHandler)
+
+// WithAPIReader sets the reader reading from the API server without the cache, usually the manager API reader
+func WithAPIReader(reader client.Reader) Option {
+	return func(h *ClientHandler) {
+		h.Reader = reader
+	}
+}
+
+// WithPolicy sets the policy enforced on mutating endpoints
+func WithPolicy(policy *policy.Policy) Option {
+	return func(h *ClientHandler) {
+		h.Policy = policy
+	}
+}
+
+// WithSnapshotStore sets the store used to snapshot objects before they are applied
+func WithSnapshotStore(store snapshot.Store) Option {
+	return func(h *ClientHandler) {
+		h.Snapshots = store
+	}
+}
+
+// WithAuditLog sets the log recording every mutation
+func WithAuditLog(log *audit.Log) Option {
+	return func(h *ClientHandler) {
+		h.Audit = log
+	}
+}
+
+// WithInformers sets the informers backing the watch streams, usually the manager cache
+func WithInformers(informers cache.Informers) Option {
+	return func(h *ClientHandler) {
+		h.Informers = informers
+	}
+}
+
+// WithForceApply sets whether applies are forced unless the request sets the force parameter
+func WithForceApply(force bool) Option {
+	return func(h *ClientHandler) {
+		h.ForceApply = force
+	}
+}
+
+// WithRedactor sets the redactor masking sensitive values in returned objects
+func WithRedactor(redactor *redact.Redactor) Option {
+	return func(h *ClientHandler) {
+		h.Redactor = redactor
+	}
+}
+
+// WithNamespaces restricts the handler to the objects of the given namespaces
+func WithNamespaces(namespaces []string) Option {
+	return func(h *ClientHandler) {
+		h.Namespaces = namespaces
+	}
+}
+
+// WithRegistry sets the client verifying images against their registries
+func WithRegistry(client *registry.Client) Option {
+	return func(h *ClientHandler) {
+		h.Registry = client
+	}
+}
+
+// NewClientHandler creates a new ClientHandler, applies are forced by default
+func NewClientHandler(client client.Client, clientset kubernetes.Interface, opts ...Option) *ClientHandler {
+	h := &ClientHandler{
+		Client:     client,
+		Clientset:  clientset,
+		ForceApply: true,
+	}
+	for _, opt := range opts {
+		opt(h)
+	}
+	if h.Reader == nil {
+		h.Reader = client
 	}
+	return h
 }
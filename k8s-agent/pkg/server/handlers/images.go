@@ -0,0 +1,176 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+
+	"github.com/Sanskarzz/k8sgptclient/k8s-agent/api"
+	"github.com/Sanskarzz/k8sgptclient/k8s-agent/pkg/registry"
+	"github.com/Sanskarzz/k8sgptclient/k8s-agent/pkg/server"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+)
+
+// VerifyImages returns a handler for POST /images/verify endpoint.
+// It accepts a YAML manifest and reports, for every container of the objects with a pod template, whether its
+// image exists in its registry, pulling with the image pull secrets of the pod and of its service account.
+// Tags that are not found come with the closest tags of the repository.
+func (h *ClientHandler) VerifyImages() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		logger := log.FromContext(r.Context()).WithName("verifyimages")
+
+		if r.Method != http.MethodPost {
+			err := fmt.Errorf("invalid method: %s, allowed: %s", r.Method, http.MethodPost)
+			logger.Error(err, "Method not allowed")
+			server.WriteError(w, r, http.StatusMethodNotAllowed, "Method not allowed")
+			return
+		}
+		if h.Registry == nil {
+			server.WriteError(w, r, http.StatusNotImplemented, "Image verification is disabled")
+			return
+		}
+
+		// Read the YAML content
+		body, err := io.ReadAll(r.Body)
+		if err != nil {
+			logger.Error(err, "Failed to read request body")
+			server.WriteError(w, r, http.StatusBadRequest, fmt.Sprintf("Failed to read request body: %v", err))
+			return
+		}
+		logger.V(2).Info("Received YAML content", "yaml", string(body))
+
+		// Decode the pods of every object with a pod template
+		objects, err := decodeObjects(body)
+		if err != nil {
+			logger.Error(err, "Failed to decode YAML")
+			server.WriteError(w, r, http.StatusBadRequest, fmt.Sprintf("Failed to decode YAML: %v", err))
+			return
+		}
+		var sources []*unstructured.Unstructured
+		var pods []*corev1.Pod
+		for _, obj := range objects {
+			pod, err := templatePod(obj)
+			if err != nil {
+				logger.Error(err, "Invalid pod template", "kind", obj.GetKind(), "name", obj.GetName())
+				server.WriteError(w, r, http.StatusBadRequest, fmt.Sprintf("Invalid pod template of %s %s: %v", obj.GetKind(), obj.GetName(), err))
+				return
+			}
+			if pod == nil {
+				logger.V(1).Info("Skipping object without pod template", "kind", obj.GetKind(), "name", obj.GetName())
+				continue
+			}
+			if pod.Namespace == "" {
+				pod.Namespace = h.defaultNamespace()
+			}
+			if !h.checkNamespace(w, r, logger, pod.Namespace) {
+				return
+			}
+			sources = append(sources, obj)
+			pods = append(pods, pod)
+		}
+		if len(pods) == 0 {
+			err := errors.New("no pod template found in request body")
+			logger.Error(err, "Nothing to verify")
+			server.WriteError(w, r, http.StatusBadRequest, err.Error())
+			return
+		}
+		logger.Info("Verifying images", "count", len(pods))
+
+		// Verify the image of every container, once per image and pull secrets
+		verified := map[string]api.ImageReport{}
+		reports := []api.ImageReport{}
+		for i, pod := range pods {
+			keychain, secrets, err := h.pullKeychain(r.Context(), pod)
+			if err != nil {
+				logger.Error(err, "Failed to read image pull secrets", "namespace", pod.Namespace)
+				server.WriteAPIError(w, r, "Failed to read image pull secrets", err)
+				return
+			}
+			for _, containers := range [][]corev1.Container{pod.Spec.InitContainers, pod.Spec.Containers} {
+				for _, container := range containers {
+					key := pod.Namespace + "/" + strings.Join(secrets, ",") + "/" + container.Image
+					report, ok := verified[key]
+					if !ok {
+						report = h.Registry.Verify(r.Context(), container.Image, keychain)
+						verified[key] = report
+					}
+					report.Kind = sources[i].GetKind()
+					report.Name = sources[i].GetName()
+					report.Namespace = pod.Namespace
+					report.Container = container.Name
+					logger.Info("Verified image",
+						"kind", report.Kind,
+						"name", report.Name,
+						"namespace", report.Namespace,
+						"container", report.Container,
+						"image", report.Image,
+						"status", report.Status,
+						"message", report.Message,
+					)
+					reports = append(reports, report)
+				}
+			}
+		}
+
+		// Set response headers
+		w.Header().Set("Content-Type", "application/json")
+
+		// Write response
+		if err := json.NewEncoder(w).Encode(reports); err != nil {
+			logger.Error(err, "Failed to encode response")
+			server.WriteAPIError(w, r, "Failed to encode response", err)
+			return
+		}
+		logger.V(1).Info("Response sent successfully")
+	}
+}
+
+// pullKeychain returns the credentials of the image pull secrets of the pod, followed by the ones of its
+// service account like on admission, and the names of the secrets read. Missing secrets are skipped like
+// the kubelet does, and so are the secrets the agent is not allowed to read.
+func (h *ClientHandler) pullKeychain(ctx context.Context, pod *corev1.Pod) (*registry.Keychain, []string, error) {
+	references := append([]corev1.LocalObjectReference{}, pod.Spec.ImagePullSecrets...)
+	serviceAccountName := pod.Spec.ServiceAccountName
+	if serviceAccountName == "" {
+		serviceAccountName = "default"
+	}
+	serviceAccount, err := h.Clientset.CoreV1().ServiceAccounts(pod.Namespace).Get(ctx, serviceAccountName, metav1.GetOptions{})
+	switch {
+	case err == nil:
+		references = append(references, serviceAccount.ImagePullSecrets...)
+	case !apierrors.IsNotFound(err):
+		return nil, nil, err
+	}
+
+	var secrets []corev1.Secret
+	var names []string
+	for _, ref := range references {
+		secret, err := h.Clientset.CoreV1().Secrets(pod.Namespace).Get(ctx, ref.Name, metav1.GetOptions{})
+		if apierrors.IsNotFound(err) {
+			log.FromContext(ctx).Info("Skipping missing image pull secret", "namespace", pod.Namespace, "secret", ref.Name)
+			continue
+		}
+		if apierrors.IsForbidden(err) {
+			log.FromContext(ctx).Info("Skipping image pull secret the agent is not allowed to read", "namespace", pod.Namespace, "secret", ref.Name)
+			continue
+		}
+		if err != nil {
+			return nil, nil, err
+		}
+		secrets = append(secrets, *secret)
+		names = append(names, secret.Name)
+	}
+	keychain, err := registry.KeychainFromSecrets(secrets)
+	if err != nil {
+		return nil, nil, err
+	}
+	return keychain, names, nil
+}
@@ -4,13 +4,111 @@ import (
 	"encoding/json"
 	"fmt"
 	"net/http"
+	"strconv"
+	"time"
 
+	"github.com/Sanskarzz/k8sgptclient/k8s-agent/api"
+	"github.com/Sanskarzz/k8sgptclient/k8s-agent/pkg/server"
 	corev1 "k8s.io/api/core/v1"
-	"sigs.k8s.io/controller-runtime/pkg/client"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/fields"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/util/duration"
 	"sigs.k8s.io/controller-runtime/pkg/log"
 )
 
-// ListPods returns a handler for GET /pods endpoint
+// podStatusReason computes the status column of a pod the way kubectl get pods does
+func podStatusReason(pod *corev1.Pod) string {
+	reason := string(pod.Status.Phase)
+	if pod.Status.Reason != "" {
+		reason = pod.Status.Reason
+	}
+
+	// Init containers run first, report the one blocking the pod
+	initializing := false
+	for i, container := range pod.Status.InitContainerStatuses {
+		switch {
+		case container.State.Terminated != nil && container.State.Terminated.ExitCode == 0:
+			continue
+		case container.State.Terminated != nil:
+			if container.State.Terminated.Reason != "" {
+				reason = "Init:" + container.State.Terminated.Reason
+			} else if container.State.Terminated.Signal != 0 {
+				reason = fmt.Sprintf("Init:Signal:%d", container.State.Terminated.Signal)
+			} else {
+				reason = fmt.Sprintf("Init:ExitCode:%d", container.State.Terminated.ExitCode)
+			}
+		case container.State.Waiting != nil && container.State.Waiting.Reason != "" && container.State.Waiting.Reason != "PodInitializing":
+			reason = "Init:" + container.State.Waiting.Reason
+		default:
+			reason = fmt.Sprintf("Init:%d/%d", i, len(pod.Spec.InitContainers))
+		}
+		initializing = true
+		break
+	}
+
+	if !initializing {
+		hasRunning := false
+		for i := len(pod.Status.ContainerStatuses) - 1; i >= 0; i-- {
+			container := pod.Status.ContainerStatuses[i]
+			switch {
+			case container.State.Waiting != nil && container.State.Waiting.Reason != "":
+				reason = container.State.Waiting.Reason
+			case container.State.Terminated != nil && container.State.Terminated.Reason != "":
+				reason = container.State.Terminated.Reason
+			case container.State.Terminated != nil && container.State.Terminated.Signal != 0:
+				reason = fmt.Sprintf("Signal:%d", container.State.Terminated.Signal)
+			case container.State.Terminated != nil:
+				reason = fmt.Sprintf("ExitCode:%d", container.State.Terminated.ExitCode)
+			case container.Ready && container.State.Running != nil:
+				hasRunning = true
+			}
+		}
+		// a completed pod may still have running containers shutting down
+		if reason == "Completed" && hasRunning {
+			reason = "Running"
+		}
+	}
+
+	if pod.DeletionTimestamp != nil {
+		if pod.Status.Reason == "NodeLost" {
+			reason = "Unknown"
+		} else {
+			reason = "Terminating"
+		}
+	}
+	return reason
+}
+
+// newPodSummary builds the summary view of a pod
+func newPodSummary(pod *corev1.Pod, now time.Time) api.PodSummary {
+	summary := api.PodSummary{
+		Name:      pod.Name,
+		Namespace: pod.Namespace,
+		Status:    podStatusReason(pod),
+		Node:      pod.Spec.NodeName,
+		IP:        pod.Status.PodIP,
+		Age:       duration.HumanDuration(now.Sub(pod.CreationTimestamp.Time)),
+	}
+
+	ready := 0
+	for _, container := range pod.Status.ContainerStatuses {
+		summary.Restarts += container.RestartCount
+		if container.Ready {
+			ready++
+		}
+	}
+	summary.Ready = fmt.Sprintf("%d/%d", ready, len(pod.Spec.Containers))
+
+	if owner := metav1.GetControllerOf(pod); owner != nil {
+		summary.Owner = owner.Kind + "/" + owner.Name
+	}
+	return summary
+}
+
+// ListPods returns a handler for GET /pods endpoint.
+// It supports namespace, allNamespaces, labelSelector, fieldSelector, limit, continue and view=summary query parameters.
 func (h *ClientHandler) ListPods() http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		logger := log.FromContext(r.Context()).WithName("list-pods")
@@ -19,32 +117,99 @@ func (h *ClientHandler) ListPods() http.HandlerFunc {
 		if r.Method != http.MethodGet {
 			err := fmt.Errorf("invalid method: %s, allowed: %s", r.Method, http.MethodGet)
 			logger.Error(err, "Method not allowed")
-			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+			server.WriteError(w, r, http.StatusMethodNotAllowed, "Method not allowed")
 			return
 		}
+		query := r.URL.Query()
+
 		// Get namespace from query parameter, default to default namespace
-		namespace := r.URL.Query().Get("namespace")
-		if namespace == "" {
-			namespace = "default"
-			logger.V(1).Info("No namespace provided, using default")
+		namespace := query.Get("namespace")
+		if allNamespaces, _ := strconv.ParseBool(query.Get("allNamespaces")); allNamespaces {
+			namespace = metav1.NamespaceAll
+		} else if namespace == "" {
+			namespace = h.defaultNamespace()
+			logger.V(1).Info("No namespace provided, using default", "namespace", namespace)
+		}
+		// All namespaces are the served namespaces when the agent is restricted to some
+		namespaces := []string{namespace}
+		if namespace == metav1.NamespaceAll && len(h.Namespaces) > 0 {
+			namespaces = h.Namespaces
+		} else if !h.checkNamespace(w, r, logger, namespace) {
+			return
 		}
 
-		logger = logger.WithValues("namespace", namespace)
+		// Parse selectors and paging
+		listOpts := metav1.ListOptions{
+			LabelSelector: query.Get("labelSelector"),
+			FieldSelector: query.Get("fieldSelector"),
+			Continue:      query.Get("continue"),
+		}
+		if _, err := labels.Parse(listOpts.LabelSelector); err != nil {
+			logger.Error(err, "Invalid label selector")
+			server.WriteError(w, r, http.StatusBadRequest, fmt.Sprintf("Invalid label selector: %v", err))
+			return
+		}
+		if _, err := fields.ParseSelector(listOpts.FieldSelector); err != nil {
+			logger.Error(err, "Invalid field selector")
+			server.WriteError(w, r, http.StatusBadRequest, fmt.Sprintf("Invalid field selector: %v", err))
+			return
+		}
+		if value := query.Get("limit"); value != "" {
+			limit, err := strconv.ParseInt(value, 10, 64)
+			if err != nil || limit < 0 {
+				logger.Error(err, "Invalid limit parameter", "limit", value)
+				server.WriteError(w, r, http.StatusBadRequest, fmt.Sprintf("Invalid limit parameter: %s", value))
+				return
+			}
+			listOpts.Limit = limit
+		}
+		if len(namespaces) > 1 && (listOpts.Limit > 0 || listOpts.Continue != "") {
+			err := fmt.Errorf("paging across namespaces %v is not supported", namespaces)
+			logger.Error(err, "Invalid paging parameters")
+			server.WriteError(w, r, http.StatusBadRequest, "Paging is not supported with allNamespaces when the agent is restricted to namespaces, list each namespace instead")
+			return
+		}
+		view := query.Get("view")
+		if view != "" && view != "summary" {
+			err := fmt.Errorf("invalid view: %s, allowed: summary", view)
+			logger.Error(err, "Invalid view")
+			server.WriteError(w, r, http.StatusBadRequest, "Invalid view. Allowed: summary")
+			return
+		}
+
+		logger = logger.WithValues(
+			"namespace", namespace,
+			"labelSelector", listOpts.LabelSelector,
+			"fieldSelector", listOpts.FieldSelector,
+			"limit", listOpts.Limit,
+		)
 		logger.Info("Listing pods")
 
-		// List pods in the namespace
-		var podList corev1.PodList
-		if err := h.Client.List(r.Context(), &podList, &client.ListOptions{
-			Namespace: namespace,
-		}); err != nil {
-			logger.Error(err, "Failed to list pods")
-			http.Error(w, err.Error(), http.StatusInternalServerError)
-			return
+		// List pods from the API server, the cache supports neither arbitrary field selectors nor continue tokens
+		podList := &corev1.PodList{}
+		for _, namespace := range namespaces {
+			list, err := h.Clientset.CoreV1().Pods(namespace).List(r.Context(), listOpts)
+			if err != nil {
+				logger.Error(err, "Failed to list pods", "listedNamespace", namespace)
+				if apierrors.IsResourceExpired(err) {
+					// expired continue tokens are a client error, the API server reports them as 410 Gone
+					server.WriteError(w, r, http.StatusBadRequest, fmt.Sprintf("Failed to list pods: %v", err))
+					return
+				}
+				server.WriteAPIError(w, r, "Failed to list pods", err)
+				return
+			}
+			if len(namespaces) == 1 {
+				podList = list
+				break
+			}
+			podList.Items = append(podList.Items, list.Items...)
 		}
 
 		// Log pod count and details
 		logger.Info("Successfully listed pods",
 			"count", len(podList.Items),
+			"more", podList.Continue != "",
 		)
 
 		// Log detailed pod information at debug level
@@ -56,13 +221,40 @@ func (h *ClientHandler) ListPods() http.HandlerFunc {
 			)
 		}
 
+		// Project to the summary view
+		var response interface{} = podList
+		if view == "summary" {
+			now := time.Now()
+			summaries := api.PodSummaryList{
+				Items:    make([]api.PodSummary, 0, len(podList.Items)),
+				Continue: podList.Continue,
+			}
+			for i := range podList.Items {
+				summaries.Items = append(summaries.Items, newPodSummary(&podList.Items[i], now))
+			}
+			response = summaries
+		} else {
+			// the full view holds the env and args of every pod
+			redacted := 0
+			for i := range podList.Items {
+				count, err := h.redactPod(&podList.Items[i])
+				if err != nil {
+					logger.Error(err, "Failed to redact pod", "name", podList.Items[i].Name)
+					server.WriteAPIError(w, r, "Failed to redact pods", err)
+					return
+				}
+				redacted += count
+			}
+			logger.V(1).Info("Redacted sensitive values", "count", redacted)
+		}
+
 		// Set response headers
 		w.Header().Set("Content-Type", "application/json")
 
 		// Write response
-		if err := json.NewEncoder(w).Encode(podList); err != nil {
+		if err := json.NewEncoder(w).Encode(response); err != nil {
 			logger.Error(err, "Failed to encode response")
-			http.Error(w, err.Error(), http.StatusInternalServerError)
+			server.WriteAPIError(w, r, "Failed to encode response", err)
 			return
 		}
 		logger.V(1).Info("Response sent successfully")
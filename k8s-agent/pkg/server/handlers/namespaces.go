@@ -0,0 +1,62 @@
+package handlers
+
+import (
+	"fmt"
+	"net/http"
+	"slices"
+	"strings"
+
+	"github.com/Sanskarzz/k8sgptclient/k8s-agent/pkg/policy"
+	"github.com/Sanskarzz/k8sgptclient/k8s-agent/pkg/server"
+	"github.com/go-logr/logr"
+)
+
+// servesNamespace returns true when the agent serves the objects of namespace, "" standing for cluster
+// scoped objects. An agent restricted to namespaces serves no cluster scoped object.
+func (h *ClientHandler) servesNamespace(namespace string) bool {
+	return len(h.Namespaces) == 0 || slices.Contains(h.Namespaces, namespace)
+}
+
+// defaultNamespace returns the namespace of requests and objects that don't set one: default,
+// or the first served namespace when the agent does not serve default
+func (h *ClientHandler) defaultNamespace() string {
+	if h.servesNamespace("default") {
+		return "default"
+	}
+	return h.Namespaces[0]
+}
+
+// namespaceMessage explains why the objects of namespace are not served
+func (h *ClientHandler) namespaceMessage(namespace string) string {
+	served := strings.Join(h.Namespaces, ", ")
+	if namespace == "" {
+		return fmt.Sprintf("cluster scoped resources are not served, the agent is restricted to namespaces: %s", served)
+	}
+	return fmt.Sprintf("namespace %q is not served, the agent is restricted to namespaces: %s", namespace, served)
+}
+
+// namespaceViolations reports an object outside of the served namespaces like a policy violation
+func (h *ClientHandler) namespaceViolations(namespace string) []policy.Violation {
+	if h.servesNamespace(namespace) {
+		return nil
+	}
+	path := "metadata.namespace"
+	if namespace == "" {
+		path = "kind"
+	}
+	return []policy.Violation{{
+		Rule:    "servedNamespaces",
+		Message: h.namespaceMessage(namespace),
+		Path:    path,
+	}}
+}
+
+// checkNamespace writes a 403 error and returns false when the agent does not serve namespace
+func (h *ClientHandler) checkNamespace(w http.ResponseWriter, r *http.Request, logger logr.Logger, namespace string) bool {
+	if h.servesNamespace(namespace) {
+		return true
+	}
+	logger.Info("Namespace not served", "namespace", namespace, "served", h.Namespaces)
+	server.WriteError(w, r, http.StatusForbidden, "Forbidden: "+h.namespaceMessage(namespace))
+	return false
+}
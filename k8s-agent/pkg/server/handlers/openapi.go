@@ -0,0 +1,23 @@
+package handlers
+
+import (
+	"net/http"
+
+	"github.com/Sanskarzz/k8sgptclient/k8s-agent/api"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+)
+
+// OpenAPI returns a handler for GET /openapi.json endpoint.
+// It serves the OpenAPI specification of the agent API.
+func OpenAPI() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		logger := log.FromContext(r.Context()).WithName("openapi")
+
+		w.Header().Set("Content-Type", "application/json")
+		if _, err := w.Write(api.OpenAPI); err != nil {
+			logger.Error(err, "Failed to write response")
+			return
+		}
+		logger.V(1).Info("Response sent successfully")
+	}
+}
@@ -0,0 +1,57 @@
+package handlers
+
+import (
+	"sort"
+
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+// installOrder lists kinds in the order they must be applied so that objects are created
+// after the namespaces, configuration and RBAC they depend on
+var installOrder = []string{
+	"Namespace",
+	"NetworkPolicy",
+	"ResourceQuota",
+	"LimitRange",
+	"PodDisruptionBudget",
+	"ServiceAccount",
+	"Secret",
+	"ConfigMap",
+	"StorageClass",
+	"PersistentVolume",
+	"PersistentVolumeClaim",
+	"CustomResourceDefinition",
+	"ClusterRole",
+	"ClusterRoleBinding",
+	"Role",
+	"RoleBinding",
+	"Service",
+	"DaemonSet",
+	"Pod",
+	"ReplicationController",
+	"ReplicaSet",
+	"Deployment",
+	"HorizontalPodAutoscaler",
+	"StatefulSet",
+	"Job",
+	"CronJob",
+	"Ingress",
+	"APIService",
+}
+
+// installRank returns the position of a kind in the install order, unknown kinds go last
+func installRank(kind string) int {
+	for i, k := range installOrder {
+		if k == kind {
+			return i
+		}
+	}
+	return len(installOrder)
+}
+
+// sortByInstallOrder sorts objects by kind, keeping the request order for objects of the same rank
+func sortByInstallOrder(objects []*unstructured.Unstructured) {
+	sort.SliceStable(objects, func(i, j int) bool {
+		return installRank(objects[i].GetKind()) < installRank(objects[j].GetKind())
+	})
+}
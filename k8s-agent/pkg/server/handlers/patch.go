@@ -0,0 +1,148 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+
+	"github.com/Sanskarzz/k8sgptclient/k8s-agent/pkg/server"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+)
+
+// patchTypes are the patch content types accepted by the patch endpoint
+var patchTypes = map[string]types.PatchType{
+	string(types.JSONPatchType):           types.JSONPatchType,
+	string(types.MergePatchType):          types.MergePatchType,
+	string(types.StrategicMergePatchType): types.StrategicMergePatchType,
+}
+
+// PatchResource returns a handler for PATCH /resources/{group}/{version}/{resource}/{namespace}/{name} endpoint.
+// The patch type is taken from the Content-Type header: application/json-patch+json, application/merge-patch+json
+// or application/strategic-merge-patch+json. Cluster scoped objects are addressed like in Resource.
+func (h *ClientHandler) PatchResource() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		logger := log.FromContext(r.Context()).WithName("patch-resource")
+
+		if r.Method != http.MethodPatch {
+			err := fmt.Errorf("invalid method: %s, allowed: %s", r.Method, http.MethodPatch)
+			logger.Error(err, "Method not allowed")
+			server.WriteError(w, r, http.StatusMethodNotAllowed, "Method not allowed")
+			return
+		}
+
+		// Resolve the patch type
+		mediaType, _, err := mime.ParseMediaType(r.Header.Get("Content-Type"))
+		patchType, ok := patchTypes[mediaType]
+		if err != nil || !ok {
+			err := fmt.Errorf("unsupported content type: %q", r.Header.Get("Content-Type"))
+			logger.Error(err, "Unsupported media type")
+			server.WriteError(w, r, http.StatusUnsupportedMediaType,
+				fmt.Sprintf("Unsupported content type. Allowed: %s, %s, %s", types.JSONPatchType, types.MergePatchType, types.StrategicMergePatchType))
+			return
+		}
+
+		// Resolve the object
+		gvk, key, logger, ok := h.resolveResource(w, r, logger)
+		if !ok {
+			return
+		}
+		logger = logger.WithValues("patchType", patchType)
+
+		// Read the patch
+		body, err := io.ReadAll(r.Body)
+		if err != nil {
+			logger.Error(err, "Failed to read request body")
+			server.WriteError(w, r, http.StatusBadRequest, fmt.Sprintf("Failed to read request body: %v", err))
+			return
+		}
+		logger.V(2).Info("Received patch", "patch", string(body))
+
+		h.runAction(w, r, logger, action{
+			operation: "patch",
+			done:      "patched",
+			gvk:       gvk,
+			key:       key,
+			mutate: func(live *unstructured.Unstructured) (*unstructured.Unstructured, error) {
+				// put back the values masked in the objects returned to the caller
+				if h.Redactor != nil {
+					var patch interface{}
+					if err := json.Unmarshal(body, &patch); err != nil {
+						return nil, apierrors.NewBadRequest(fmt.Sprintf("invalid %s: %v", patchType, err))
+					}
+					if err := h.restoreRedacted(patch, live); err != nil {
+						return nil, err
+					}
+					if body, err = json.Marshal(patch); err != nil {
+						return nil, err
+					}
+				}
+				// the dry-run returns the object as the API server would persist it, which is what the policy checks
+				desired := live.DeepCopy()
+				if err := h.rawPatch(r.Context(), desired, live, patchType, body, true); err != nil {
+					return nil, err
+				}
+				return desired, nil
+			},
+			run: func(ctx context.Context, live, desired *unstructured.Unstructured, dryRun bool) error {
+				if dryRun {
+					// mutate already dry-ran the patch
+					return nil
+				}
+				return h.rawPatch(ctx, desired, live, patchType, body, false)
+			},
+		})
+	}
+}
+
+// rawPatch sends the patch for obj, locked to the resourceVersion of live so that
+// it fails if the object changed since the policy check
+func (h *ClientHandler) rawPatch(ctx context.Context, obj, live *unstructured.Unstructured, patchType types.PatchType, patch []byte, dryRun bool) error {
+	locked, err := lockPatch(patchType, patch, live.GetResourceVersion())
+	if err != nil {
+		return err
+	}
+	opts := []client.PatchOption{client.FieldOwner(fieldManager)}
+	if dryRun {
+		opts = append(opts, client.DryRunAll)
+	}
+	return h.Client.Patch(ctx, obj, client.RawPatch(patchType, locked), opts...)
+}
+
+// lockPatch adds a resourceVersion precondition to the patch
+func lockPatch(patchType types.PatchType, patch []byte, resourceVersion string) ([]byte, error) {
+	if patchType == types.JSONPatchType {
+		var operations []interface{}
+		if err := json.Unmarshal(patch, &operations); err != nil {
+			return nil, apierrors.NewBadRequest(fmt.Sprintf("invalid JSON patch, expected an array of operations: %v", err))
+		}
+		test := map[string]interface{}{
+			"op":    "test",
+			"path":  "/metadata/resourceVersion",
+			"value": resourceVersion,
+		}
+		return json.Marshal(append([]interface{}{test}, operations...))
+	}
+
+	var object map[string]interface{}
+	if err := json.Unmarshal(patch, &object); err != nil || object == nil {
+		if err == nil {
+			err = errors.New("null patch")
+		}
+		return nil, apierrors.NewBadRequest(fmt.Sprintf("invalid %s, expected a JSON object: %v", patchType, err))
+	}
+	// a resourceVersion set by the caller is kept, the API server checks it the same way
+	if _, found, _ := unstructured.NestedFieldNoCopy(object, "metadata", "resourceVersion"); !found {
+		if err := unstructured.SetNestedField(object, resourceVersion, "metadata", "resourceVersion"); err != nil {
+			return nil, apierrors.NewBadRequest(fmt.Sprintf("invalid %s: %v", patchType, err))
+		}
+	}
+	return json.Marshal(object)
+}
@@ -5,11 +5,13 @@ import (
 	"fmt"
 	"io"
 	"net/http"
+	"net/url"
+	"strconv"
 	"strings"
 
+	"github.com/Sanskarzz/k8sgptclient/k8s-agent/pkg/metrics"
+	"github.com/Sanskarzz/k8sgptclient/k8s-agent/pkg/server"
 	corev1 "k8s.io/api/core/v1"
-	"k8s.io/client-go/kubernetes"
-	"sigs.k8s.io/controller-runtime/pkg/client/config"
 	"sigs.k8s.io/controller-runtime/pkg/log"
 )
 
@@ -23,7 +25,7 @@ func (h *ClientHandler) PodLogs() http.HandlerFunc {
 		if r.Method != http.MethodGet {
 			err := fmt.Errorf("invalid method: %s, allowed: %s", r.Method, http.MethodGet)
 			logger.Error(err, "Method not allowed")
-			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+			server.WriteError(w, r, http.StatusMethodNotAllowed, "Method not allowed")
 			return
 		}
 
@@ -32,7 +34,7 @@ func (h *ClientHandler) PodLogs() http.HandlerFunc {
 		if len(parts) != 5 {
 			err := fmt.Errorf("invalid path: %s, allowed: /pods/{namespace}/{podName}/logs", r.URL.Path)
 			logger.Error(err, "Invalid path")
-			http.Error(w, "Invalid path. Expected: /pods/{namespace}/{podName}/logs", http.StatusBadRequest)
+			server.WriteError(w, r, http.StatusBadRequest, "Invalid path. Expected: /pods/{namespace}/{podName}/logs")
 			return
 		}
 		namespace := parts[2]
@@ -43,48 +45,33 @@ func (h *ClientHandler) PodLogs() http.HandlerFunc {
 			"pod", podName,
 		)
 		logger.Info("Getting pod logs")
-
-		// Get the REST config
-		cfg, err := config.GetConfig()
-		if err != nil {
-			logger.Error(err, "Failed to get kubeconfig")
-			http.Error(w, fmt.Sprintf("Failed to get kubeconfig: %v", err), http.StatusInternalServerError)
+		if !h.checkNamespace(w, r, logger, namespace) {
 			return
 		}
 
-		// Create the clientset
-		clientset, err := kubernetes.NewForConfig(cfg)
+		// Set up the pod logs options
+		podLogOpts, err := parsePodLogOptions(r.URL.Query())
 		if err != nil {
-			logger.Error(err, "Failed to create clientset")
-			http.Error(w, fmt.Sprintf("Failed to create clientset: %v", err), http.StatusInternalServerError)
+			logger.Error(err, "Invalid log options")
+			server.WriteError(w, r, http.StatusBadRequest, fmt.Sprintf("Invalid log options: %v", err))
 			return
 		}
-
-		// Set up the pod logs options
-		podLogOpts := &corev1.PodLogOptions{
-			Follow:    false,
-			Previous:  false,
-			TailLines: nil, // Get all logs
-		}
-
-		// Get container name from query parameter
-		if containerName := r.URL.Query().Get("container"); containerName != "" {
-			podLogOpts.Container = containerName
-			logger = logger.WithValues("container", containerName)
+		if podLogOpts.Container != "" {
+			logger = logger.WithValues("container", podLogOpts.Container)
 			logger.V(1).Info("Container specified in request")
 		}
 
 		// Request the pod logs
-		req := clientset.CoreV1().Pods(namespace).GetLogs(podName, podLogOpts)
+		req := h.Clientset.CoreV1().Pods(namespace).GetLogs(podName, podLogOpts)
 		podLogs, err := req.Stream(r.Context())
 		if err != nil {
 			logger.Error(err, "Failed to get pod logs stream")
-			http.Error(w, fmt.Sprintf("Failed to get pod logs: %v", err), http.StatusInternalServerError)
+			server.WriteAPIError(w, r, "Failed to get pod logs", err)
 			return
 		}
 		defer podLogs.Close()
 
-		logger.Info("Successfully started log streaming")
+		logger.Info("Successfully started log streaming", "follow", podLogOpts.Follow)
 
 		// Set headers for streaming response
 		w.Header().Set("Content-Type", "text/plain")
@@ -94,21 +81,65 @@ func (h *ClientHandler) PodLogs() http.HandlerFunc {
 		reader := bufio.NewReader(podLogs)
 		for {
 			line, err := reader.ReadBytes('\n')
+			if len(line) > 0 {
+				n, err := w.Write(line)
+				metrics.LogBytesTotal.WithLabelValues("pod").Add(float64(n))
+				if err != nil {
+					logger.Error(err, "Failed to write logs")
+					return
+				}
+				if f, ok := w.(http.Flusher); ok {
+					f.Flush()
+				}
+			}
 			if err != nil {
-				if err == io.EOF {
-					break
+				if err != io.EOF {
+					fmt.Fprintf(w, "Error reading logs: %v\n", err)
 				}
-				fmt.Fprintf(w, "Error reading logs: %v\n", err)
 				return
 			}
-			_, err = w.Write(line)
+		}
+	}
+}
+
+// parsePodLogOptions reads the log options from the query parameters:
+// container, follow, previous, timestamps, tailLines, sinceSeconds and limitBytes
+func parsePodLogOptions(query url.Values) (*corev1.PodLogOptions, error) {
+	opts := &corev1.PodLogOptions{
+		Container: query.Get("container"),
+	}
+
+	for name, target := range map[string]*bool{
+		"follow":     &opts.Follow,
+		"previous":   &opts.Previous,
+		"timestamps": &opts.Timestamps,
+	} {
+		if value := query.Get(name); value != "" {
+			parsed, err := strconv.ParseBool(value)
 			if err != nil {
-				fmt.Fprintf(w, "Error writing logs: %v\n", err)
-				return
+				return nil, fmt.Errorf("invalid %s parameter %q, expected a boolean", name, value)
 			}
-			if f, ok := w.(http.Flusher); ok {
-				f.Flush()
+			*target = parsed
+		}
+	}
+
+	for name, target := range map[string]**int64{
+		"tailLines":    &opts.TailLines,
+		"sinceSeconds": &opts.SinceSeconds,
+		"limitBytes":   &opts.LimitBytes,
+	} {
+		if value := query.Get(name); value != "" {
+			parsed, err := strconv.ParseInt(value, 10, 64)
+			if err != nil {
+				return nil, fmt.Errorf("invalid %s parameter %q, expected an integer", name, value)
 			}
+			// the API server accepts tailLines=0 but requires the other limits to be positive
+			if parsed < 0 || (parsed == 0 && name != "tailLines") {
+				return nil, fmt.Errorf("invalid %s parameter %q, must be positive", name, value)
+			}
+			*target = &parsed
 		}
 	}
+
+	return opts, nil
 }
@@ -6,42 +6,13 @@ import (
 	"net/http"
 	"strings"
 
+	"github.com/Sanskarzz/k8sgptclient/k8s-agent/api"
+	"github.com/Sanskarzz/k8sgptclient/k8s-agent/pkg/server"
 	corev1 "k8s.io/api/core/v1"
 	"k8s.io/apimachinery/pkg/types"
 	"sigs.k8s.io/controller-runtime/pkg/log"
 )
 
-// ProbeStatus represents the status of a probe
-type ProbeStatus struct {
-	LastProbeTime      string `json:"lastProbeTime,omitempty"`
-	LastTransitionTime string `json:"lastTransitionTime,omitempty"`
-	Status             bool   `json:"status"`
-	Failure            string `json:"failure,omitempty"`
-	SuccessCount       int32  `json:"successCount"`
-	FailureCount       int32  `json:"failureCount"`
-	Details            string `json:"details"`
-}
-
-// ContainerProbes represents the probe status for a container
-type ContainerProbes struct {
-	ContainerName string      `json:"containerName"`
-	Liveness      ProbeStatus `json:"liveness,omitempty"`
-	Readiness     ProbeStatus `json:"readiness,omitempty"`
-}
-
-// PodStatus represents the status response structure
-type PodStatus struct {
-	Name            string                   `json:"name"`
-	Namespace       string                   `json:"namespace"`
-	Phase           corev1.PodPhase          `json:"phase"`
-	Conditions      []corev1.PodCondition    `json:"conditions"`
-	ContainerStatus []corev1.ContainerStatus `json:"containerStatus"`
-	StartTime       string                   `json:"startTime,omitempty"`
-	PodIP           string                   `json:"podIP,omitempty"`
-	HostIP          string                   `json:"hostIP,omitempty"`
-	ProbeResults    []ContainerProbes        `json:"probeResults,omitempty"`
-}
-
 // formatProbeDetails formats probe configuration details
 func formatProbeDetails(probe *corev1.Probe) string {
 	if probe == nil {
@@ -68,6 +39,81 @@ func formatProbeDetails(probe *corev1.Probe) string {
 	return details
 }
 
+// newPodStatus builds the status response of a pod, including its probe results
+func newPodStatus(pod *corev1.Pod) api.PodStatus {
+	// Create status response
+	status := api.PodStatus{
+		Name:            pod.Name,
+		Namespace:       pod.Namespace,
+		Phase:           pod.Status.Phase,
+		Conditions:      pod.Status.Conditions,
+		ContainerStatus: pod.Status.ContainerStatuses,
+		PodIP:           pod.Status.PodIP,
+		HostIP:          pod.Status.HostIP,
+	}
+
+	if pod.Status.StartTime != nil {
+		status.StartTime = pod.Status.StartTime.String()
+	}
+
+	// Add probe results for each container
+	// Iterate through each container in the pod spec
+	for _, container := range pod.Spec.Containers {
+		// Initialize probe info for this container
+		probes := api.ContainerProbes{
+			ContainerName: container.Name,
+		}
+
+		// Find matching container status from pod status
+		var containerStatus *corev1.ContainerStatus
+		for i := range pod.Status.ContainerStatuses {
+			if pod.Status.ContainerStatuses[i].Name == container.Name {
+				containerStatus = &pod.Status.ContainerStatuses[i]
+				break
+			}
+		}
+
+		if containerStatus != nil {
+			// Liveness probe status
+			if container.LivenessProbe != nil {
+				probes.Liveness = api.ProbeStatus{
+					Status:       containerStatus.Ready,
+					Details:      formatProbeDetails(container.LivenessProbe),
+					SuccessCount: container.LivenessProbe.SuccessThreshold,
+					FailureCount: container.LivenessProbe.FailureThreshold,
+				}
+
+				// Add failure information
+				if containerStatus.LastTerminationState.Terminated != nil {
+					probes.Liveness.Failure = containerStatus.LastTerminationState.Terminated.Message
+					if containerStatus.LastTerminationState.Terminated.FinishedAt.Time.Unix() > 0 {
+						probes.Liveness.LastProbeTime = containerStatus.LastTerminationState.Terminated.FinishedAt.String()
+					}
+				}
+
+				// Add restart count information
+				if containerStatus.RestartCount > 0 {
+					probes.Liveness.FailureCount = containerStatus.RestartCount
+				}
+			}
+
+			// Readiness probe status
+			if container.ReadinessProbe != nil {
+				probes.Readiness = api.ProbeStatus{
+					Status:       containerStatus.Ready,
+					Details:      formatProbeDetails(container.ReadinessProbe),
+					SuccessCount: container.ReadinessProbe.SuccessThreshold,
+					FailureCount: container.ReadinessProbe.FailureThreshold,
+				}
+			}
+		}
+
+		status.ProbeResults = append(status.ProbeResults, probes)
+	}
+
+	return status
+}
+
 // PodStatus returns a handler for GET /pods/{namespace}/{podName}/status endpoint
 func (h *ClientHandler) PodStatus() http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
@@ -77,7 +123,7 @@ func (h *ClientHandler) PodStatus() http.HandlerFunc {
 		if r.Method != http.MethodGet {
 			err := fmt.Errorf("invalid method: %s, allowed: %s", r.Method, http.MethodGet)
 			logger.Error(err, "Method not allowed")
-			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+			server.WriteError(w, r, http.StatusMethodNotAllowed, "Method not allowed")
 			return
 		}
 
@@ -86,7 +132,7 @@ func (h *ClientHandler) PodStatus() http.HandlerFunc {
 		if len(parts) != 5 {
 			err := fmt.Errorf("invalid path: %s, allowed: /pods/{namespace}/{podName}/status", r.URL.Path)
 			logger.Error(err, "Invalid path")
-			http.Error(w, "Invalid path. Expected: /pods/{namespace}/{podName}/status", http.StatusBadRequest)
+			server.WriteError(w, r, http.StatusBadRequest, "Invalid path. Expected: /pods/{namespace}/{podName}/status")
 			return
 		}
 		namespace := parts[2]
@@ -97,6 +143,9 @@ func (h *ClientHandler) PodStatus() http.HandlerFunc {
 			"pod", podName,
 		)
 		logger.Info("Getting pod status")
+		if !h.checkNamespace(w, r, logger, namespace) {
+			return
+		}
 
 		// Get pod
 		var pod corev1.Pod
@@ -105,79 +154,11 @@ func (h *ClientHandler) PodStatus() http.HandlerFunc {
 			Name:      podName,
 		}, &pod); err != nil {
 			logger.Error(err, "Failed to get pod")
-			http.Error(w, fmt.Sprintf("Failed to get pod: %v", err), http.StatusInternalServerError)
+			server.WriteAPIError(w, r, "Failed to get pod", err)
 			return
 		}
 
-		// Create status response
-		status := PodStatus{
-			Name:            pod.Name,
-			Namespace:       pod.Namespace,
-			Phase:           pod.Status.Phase,
-			Conditions:      pod.Status.Conditions,
-			ContainerStatus: pod.Status.ContainerStatuses,
-			PodIP:           pod.Status.PodIP,
-			HostIP:          pod.Status.HostIP,
-		}
-
-		if pod.Status.StartTime != nil {
-			status.StartTime = pod.Status.StartTime.String()
-		}
-
-		// Add probe results for each container
-		// Iterate through each container in the pod spec
-		for _, container := range pod.Spec.Containers {
-			// Initialize probe info for this container
-			probes := ContainerProbes{
-				ContainerName: container.Name,
-			}
-
-			// Find matching container status from pod status
-			var containerStatus *corev1.ContainerStatus
-			for i := range pod.Status.ContainerStatuses {
-				if pod.Status.ContainerStatuses[i].Name == container.Name {
-					containerStatus = &pod.Status.ContainerStatuses[i]
-					break
-				}
-			}
-
-			if containerStatus != nil {
-				// Liveness probe status
-				if container.LivenessProbe != nil {
-					probes.Liveness = ProbeStatus{
-						Status:       containerStatus.Ready,
-						Details:      formatProbeDetails(container.LivenessProbe),
-						SuccessCount: container.LivenessProbe.SuccessThreshold,
-						FailureCount: container.LivenessProbe.FailureThreshold,
-					}
-
-					// Add failure information
-					if containerStatus.LastTerminationState.Terminated != nil {
-						probes.Liveness.Failure = containerStatus.LastTerminationState.Terminated.Message
-						if containerStatus.LastTerminationState.Terminated.FinishedAt.Time.Unix() > 0 {
-							probes.Liveness.LastProbeTime = containerStatus.LastTerminationState.Terminated.FinishedAt.String()
-						}
-					}
-
-					// Add restart count information
-					if containerStatus.RestartCount > 0 {
-						probes.Liveness.FailureCount = containerStatus.RestartCount
-					}
-				}
-
-				// Readiness probe status
-				if container.ReadinessProbe != nil {
-					probes.Readiness = ProbeStatus{
-						Status:       containerStatus.Ready,
-						Details:      formatProbeDetails(container.ReadinessProbe),
-						SuccessCount: container.ReadinessProbe.SuccessThreshold,
-						FailureCount: container.ReadinessProbe.FailureThreshold,
-					}
-				}
-			}
-
-			status.ProbeResults = append(status.ProbeResults, probes)
-		}
+		status := newPodStatus(&pod)
 
 		// Set response headers
 		w.Header().Set("Content-Type", "application/json")
@@ -185,7 +166,7 @@ func (h *ClientHandler) PodStatus() http.HandlerFunc {
 		// Write response
 		if err := json.NewEncoder(w).Encode(status); err != nil {
 			logger.Error(err, "Failed to encode response")
-			http.Error(w, fmt.Sprintf("Failed to encode response: %v", err), http.StatusInternalServerError)
+			server.WriteAPIError(w, r, "Failed to encode response", err)
 			return
 		}
 		logger.V(1).Info("Response sent successfully")
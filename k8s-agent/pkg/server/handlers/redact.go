@@ -0,0 +1,85 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/Sanskarzz/k8sgptclient/k8s-agent/api"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+// marshalRedacted encodes obj to JSON with its sensitive values replaced by tokens when redaction is enabled,
+// it returns the number of replaced values
+func (h *ClientHandler) marshalRedacted(obj interface{}) ([]byte, int, error) {
+	data, err := json.Marshal(obj)
+	if err != nil || h.Redactor == nil {
+		return data, 0, err
+	}
+	var content map[string]interface{}
+	if err := json.Unmarshal(data, &content); err != nil {
+		return nil, 0, err
+	}
+	count := h.Redactor.Redact(content)
+	data, err = json.Marshal(content)
+	return data, count, err
+}
+
+// redactPod replaces the sensitive values of a listed pod in place, with the tokens the pod endpoint issues,
+// it returns the number of replaced values
+func (h *ClientHandler) redactPod(pod *corev1.Pod) (int, error) {
+	if h.Redactor == nil {
+		return 0, nil
+	}
+	// listed items have no kind, which is part of the token
+	pod.APIVersion, pod.Kind = "v1", "Pod"
+	data, count, err := h.marshalRedacted(pod)
+	if err != nil {
+		return 0, err
+	}
+	*pod = corev1.Pod{}
+	return count, json.Unmarshal(data, pod)
+}
+
+// restoreRedacted puts back the values replaced by tokens in value, an object content or a decoded merge patch,
+// from the live object: a token is only restored into the field of the object it was issued for.
+// Unknown tokens fail with a bad request so that they are never applied.
+func (h *ClientHandler) restoreRedacted(value interface{}, live *unstructured.Unstructured) error {
+	if h.Redactor == nil {
+		return nil
+	}
+	var liveContent map[string]interface{}
+	if live != nil {
+		liveContent = live.Object
+	}
+	var err error
+	if operations, ok := value.([]interface{}); ok {
+		// JSON patches are lists of operations
+		err = h.Redactor.RestoreJSONPatch(operations, liveContent)
+	} else {
+		err = h.Redactor.Restore(value, liveContent)
+	}
+	if err != nil {
+		return apierrors.NewBadRequest(fmt.Sprintf("failed to restore redacted values, get the object again: %v", err))
+	}
+	return nil
+}
+
+// diffRedacted diffs the live object and the dry-run result with their sensitive values replaced by tokens
+// when redaction is enabled. Both copies get the tokens of their own values, so changed values show as
+// different tokens and the diff never holds the values restored for the dry-run.
+func (h *ClientHandler) diffRedacted(live, dryRun *unstructured.Unstructured) []api.FieldDiff {
+	if h.Redactor == nil {
+		return diffObjects(live, dryRun)
+	}
+	if live != nil {
+		live = live.DeepCopy()
+		h.Redactor.Redact(live.Object)
+	}
+	if dryRun != nil {
+		dryRun = dryRun.DeepCopy()
+		h.Redactor.Redact(dryRun.Object)
+	}
+	return diffObjects(live, dryRun)
+}
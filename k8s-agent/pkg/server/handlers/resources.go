@@ -0,0 +1,181 @@
+package handlers
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/Sanskarzz/k8sgptclient/k8s-agent/pkg/server"
+	"github.com/go-logr/logr"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+	"sigs.k8s.io/yaml"
+)
+
+// coreGroup is the group path segment addressing the core ("") API group
+const coreGroup = "core"
+
+// trimObject keeps what is needed to reason about and re-apply an object: apiVersion, kind, name, namespace
+// and the content fields (spec, data, rules, ...). Status is only kept when includeStatus is set.
+func trimObject(obj *unstructured.Unstructured, includeStatus bool) *unstructured.Unstructured {
+	trimmed := &unstructured.Unstructured{Object: map[string]interface{}{}}
+	for key, value := range obj.Object {
+		switch key {
+		case "metadata":
+		case "status":
+			if includeStatus {
+				trimmed.Object[key] = value
+			}
+		default:
+			trimmed.Object[key] = value
+		}
+	}
+	trimmed.SetName(obj.GetName())
+	if obj.GetNamespace() != "" {
+		trimmed.SetNamespace(obj.GetNamespace())
+	}
+	return trimmed
+}
+
+// resolveResource resolves the kind and key of the object addressed by a /resources path.
+// It writes the error response and returns false when the path is invalid.
+func (h *ClientHandler) resolveResource(w http.ResponseWriter, r *http.Request, logger logr.Logger) (schema.GroupVersionKind, client.ObjectKey, logr.Logger, bool) {
+	// Parse path parameters
+	parts := strings.Split(r.URL.Path, "/")
+	var namespace, name string
+	switch len(parts) {
+	case 7:
+		namespace, name = parts[5], parts[6]
+	case 6:
+		name = parts[5]
+	default:
+		err := fmt.Errorf("invalid path: %s, expected: /resources/{group}/{version}/{resource}/[{namespace}/]{name}", r.URL.Path)
+		logger.Error(err, "Invalid path")
+		server.WriteError(w, r, http.StatusBadRequest, "Invalid path. Expected: /resources/{group}/{version}/{resource}/[{namespace}/]{name}")
+		return schema.GroupVersionKind{}, client.ObjectKey{}, logger, false
+	}
+	gvr := schema.GroupVersionResource{Group: parts[2], Version: parts[3], Resource: parts[4]}
+	if gvr.Group == coreGroup {
+		gvr.Group = ""
+	}
+
+	logger = logger.WithValues(
+		"resource", gvr.String(),
+		"namespace", namespace,
+		"name", name,
+	)
+
+	// Resolve the kind of the resource
+	gvk, err := h.Client.RESTMapper().KindFor(gvr)
+	if err != nil {
+		logger.Error(err, "Unknown resource")
+		server.WriteAPIError(w, r, fmt.Sprintf("Unknown resource %s", gvr.String()), err)
+		return gvk, client.ObjectKey{}, logger, false
+	}
+	mapping, err := h.Client.RESTMapper().RESTMapping(gvk.GroupKind(), gvk.Version)
+	if err != nil {
+		logger.Error(err, "Failed to get REST mapping")
+		server.WriteAPIError(w, r, "Failed to get REST mapping", err)
+		return gvk, client.ObjectKey{}, logger, false
+	}
+	namespaced := mapping.Scope.Name() == meta.RESTScopeNameNamespace
+	if namespaced && namespace == "" {
+		server.WriteError(w, r, http.StatusBadRequest, fmt.Sprintf("%s is namespaced. Expected: /resources/{group}/{version}/{resource}/{namespace}/{name}", gvr.Resource))
+		return gvk, client.ObjectKey{}, logger, false
+	}
+	if !namespaced && namespace != "" {
+		server.WriteError(w, r, http.StatusBadRequest, fmt.Sprintf("%s is cluster scoped. Expected: /resources/{group}/{version}/{resource}/{name}", gvr.Resource))
+		return gvk, client.ObjectKey{}, logger, false
+	}
+	if !h.checkNamespace(w, r, logger, namespace) {
+		return gvk, client.ObjectKey{}, logger, false
+	}
+	return gvk, client.ObjectKey{Namespace: namespace, Name: name}, logger, true
+}
+
+// Resource returns a handler for GET /resources/{group}/{version}/{resource}/{namespace}/{name} endpoint.
+// Cluster scoped objects are addressed with /resources/{group}/{version}/{resource}/{name}, and the core group is "core".
+func (h *ClientHandler) Resource() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		logger := log.FromContext(r.Context()).WithName("get-resource")
+
+		if r.Method != http.MethodGet {
+			err := fmt.Errorf("invalid method: %s, allowed: %s", r.Method, http.MethodGet)
+			logger.Error(err, "Method not allowed")
+			server.WriteError(w, r, http.StatusMethodNotAllowed, "Method not allowed")
+			return
+		}
+
+		// Parse query parameters
+		format := r.URL.Query().Get("format")
+		if format == "" {
+			format = "yaml"
+		}
+		if format != "yaml" && format != "json" {
+			err := fmt.Errorf("invalid format: %s, allowed: json, yaml", format)
+			logger.Error(err, "Invalid format")
+			server.WriteError(w, r, http.StatusBadRequest, "Invalid format. Allowed: json, yaml")
+			return
+		}
+		includeStatus := false
+		for _, include := range strings.Split(r.URL.Query().Get("include"), ",") {
+			if include == "status" {
+				includeStatus = true
+			}
+		}
+
+		// Resolve the object
+		gvk, key, logger, ok := h.resolveResource(w, r, logger)
+		if !ok {
+			return
+		}
+		// Secrets are never served, readers could otherwise fetch any Secret the agent can read
+		if gvk.GroupKind() == (schema.GroupKind{Kind: "Secret"}) {
+			logger.Info("Refusing to serve secret")
+			server.WriteError(w, r, http.StatusForbidden, "Secrets are not served by /resources")
+			return
+		}
+		logger.Info("Getting resource")
+
+		// Get the object, unstructured objects are read from the API server and not cached
+		obj := &unstructured.Unstructured{}
+		obj.SetGroupVersionKind(gvk)
+		if err := h.Client.Get(r.Context(), key, obj); err != nil {
+			if apierrors.IsNotFound(err) {
+				logger.Info("Resource not found")
+			} else {
+				logger.Error(err, "Failed to get resource")
+			}
+			server.WriteAPIError(w, r, "Failed to get resource", err)
+			return
+		}
+
+		// Encode the trimmed object
+		data, redacted, err := h.marshalRedacted(trimObject(obj, includeStatus).Object)
+		if err != nil {
+			server.WriteAPIError(w, r, "failed to marshal resource", err)
+			return
+		}
+		logger.V(1).Info("Redacted sensitive values", "count", redacted)
+		contentType := "application/json"
+		if format == "yaml" {
+			data, err = yaml.JSONToYAML(data)
+			if err != nil {
+				server.WriteAPIError(w, r, "failed to convert to yaml", err)
+				return
+			}
+			contentType = "application/yaml"
+		}
+
+		w.Header().Set("Content-Type", contentType)
+		if _, err := w.Write(data); err != nil {
+			logger.Error(err, "Failed to write response")
+			return
+		}
+		logger.V(1).Info("Response sent successfully")
+	}
+}
@@ -0,0 +1,191 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/Sanskarzz/k8sgptclient/k8s-agent/api"
+	"github.com/Sanskarzz/k8sgptclient/k8s-agent/pkg/metrics"
+	"github.com/Sanskarzz/k8sgptclient/k8s-agent/pkg/server"
+	"github.com/Sanskarzz/k8sgptclient/k8s-agent/pkg/snapshot"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+)
+
+// Rollback returns a handler for POST /rollback/{revisionID} endpoint.
+// It restores the objects of a revision to the state they had before the apply.
+func (h *ClientHandler) Rollback() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		logger := log.FromContext(r.Context()).WithName("rollback")
+
+		if r.Method != http.MethodPost {
+			err := fmt.Errorf("invalid method: %s, allowed: %s", r.Method, http.MethodPost)
+			logger.Error(err, "Method not allowed")
+			server.WriteError(w, r, http.StatusMethodNotAllowed, "Method not allowed")
+			return
+		}
+
+		if h.Snapshots == nil {
+			logger.Info("Snapshots are disabled")
+			server.WriteError(w, r, http.StatusNotImplemented, "Snapshots are disabled")
+			return
+		}
+
+		// Parse path parameters
+		parts := strings.Split(r.URL.Path, "/")
+		if len(parts) != 3 || parts[2] == "" {
+			err := fmt.Errorf("invalid path: %s, expected: /rollback/{revisionID}", r.URL.Path)
+			logger.Error(err, "Invalid path")
+			server.WriteError(w, r, http.StatusBadRequest, "Invalid path. Expected: /rollback/{revisionID}")
+			return
+		}
+		revisionID := parts[2]
+
+		logger = logger.WithValues("revision", revisionID)
+		logger.Info("Rolling back revision")
+
+		// Get revision
+		revision, err := h.Snapshots.Get(r.Context(), revisionID)
+		if err != nil {
+			if errors.Is(err, snapshot.ErrNotFound) {
+				logger.Info("Revision not found")
+				server.WriteError(w, r, http.StatusNotFound, fmt.Sprintf("Revision %s not found", revisionID))
+				return
+			}
+			logger.Error(err, "Failed to get revision")
+			server.WriteAPIError(w, r, "Failed to get revision", err)
+			return
+		}
+
+		// Objects outside of the served namespaces can't be restored, e.g. when the served namespaces changed since the apply
+		for _, entry := range revision.Objects {
+			if !h.checkNamespace(w, r, logger, entry.Namespace) {
+				return
+			}
+		}
+
+		// Snapshot the current state so the rollback itself can be undone
+		undo := snapshot.NewRevision()
+		objects := make([]*unstructured.Unstructured, len(revision.Objects))
+		currents := make([]*unstructured.Unstructured, len(revision.Objects))
+		for i, entry := range revision.Objects {
+			obj := &unstructured.Unstructured{}
+			obj.SetAPIVersion(entry.APIVersion)
+			obj.SetKind(entry.Kind)
+			obj.SetNamespace(entry.Namespace)
+			obj.SetName(entry.Name)
+			objects[i] = obj
+
+			current := &unstructured.Unstructured{}
+			current.SetGroupVersionKind(obj.GroupVersionKind())
+			if err := h.Client.Get(r.Context(), client.ObjectKeyFromObject(obj), current); err != nil {
+				if !apierrors.IsNotFound(err) {
+					logger.Error(err, "Failed to get live resource", "kind", obj.GetKind(), "name", obj.GetName())
+					server.WriteAPIError(w, r, "Failed to get live resource", err)
+					return
+				}
+				current = nil
+			}
+			currents[i] = current
+			undo.Add(obj, current)
+		}
+
+		// Check the whole revision against the policy before restoring anything, like an apply.
+		// Secrets whose data was left out by the store can't be restored.
+		responses := make([]api.ApplyResponse, len(objects))
+		status := http.StatusOK
+		for i, obj := range objects {
+			entry := &revision.Objects[i]
+			target := obj
+			if entry.Live != nil {
+				target = entry.Live
+			}
+			if entry.DataOmitted {
+				logger.Info("Secret data was not snapshotted", "kind", obj.GetKind(), "name", obj.GetName())
+				responses[i] = newApplyResponse(obj, "failed")
+				code := setResponseError(&responses[i], "Failed to roll back resource",
+					apierrors.NewBadRequest("the snapshot store does not keep the data of Secrets, use the configmap store"))
+				if status == http.StatusOK {
+					status = code
+				}
+				continue
+			}
+			if violations := h.Policy.Evaluate(target, currents[i]); len(violations) > 0 {
+				logger.Info("Resource denied by policy",
+					"kind", obj.GetKind(),
+					"name", obj.GetName(),
+					"namespace", obj.GetNamespace(),
+					"violations", violations,
+				)
+				responses[i] = newApplyResponse(obj, "denied")
+				responses[i].Violations = violations
+				for _, violation := range violations {
+					metrics.PolicyDenialsTotal.WithLabelValues(obj.GetKind(), obj.GetNamespace(), violation.Rule).Inc()
+				}
+				if status == http.StatusOK {
+					status = http.StatusForbidden
+				}
+				continue
+			}
+			responses[i] = newApplyResponse(obj, "skipped")
+		}
+		if status != http.StatusOK {
+			for i, obj := range objects {
+				h.recordAudit(r, "rollback", obj, currents[i], nil, responses[i])
+			}
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(status)
+			if err := json.NewEncoder(w).Encode(responses); err != nil {
+				logger.Error(err, "Failed to encode response")
+			}
+			return
+		}
+		if err := h.Snapshots.Save(r.Context(), undo); err != nil {
+			logger.Error(err, "Failed to save snapshot")
+			server.WriteAPIError(w, r, "Failed to save snapshot", err)
+			return
+		}
+
+		// Restore objects in reverse apply order so workloads go before their dependencies
+		for i := len(objects) - 1; i >= 0; i-- {
+			obj := objects[i]
+			logger := logger.WithValues(
+				"kind", obj.GetKind(),
+				"name", obj.GetName(),
+				"namespace", obj.GetNamespace(),
+			)
+
+			responses[i] = newApplyResponse(obj, "rolled-back")
+			responses[i].RevisionID = undo.ID
+			if err := h.restoreObject(r.Context(), obj, revision.Objects[i].Live); err != nil {
+				logger.Error(err, "Failed to roll back resource")
+				responses[i].Action = "failed"
+				code := setResponseError(&responses[i], "Failed to roll back resource", err)
+				if status == http.StatusOK {
+					status = code
+				}
+				h.recordAudit(r, "rollback", obj, currents[i], nil, responses[i])
+				continue
+			}
+			logger.Info("Successfully rolled back resource")
+			h.recordAudit(r, "rollback", obj, currents[i], revision.Objects[i].Live, responses[i])
+		}
+
+		// Set response headers
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(status)
+
+		// Write response
+		if err := json.NewEncoder(w).Encode(responses); err != nil {
+			logger.Error(err, "Failed to encode response")
+			server.WriteAPIError(w, r, "Failed to encode response", err)
+			return
+		}
+		logger.V(1).Info("Response sent successfully")
+	}
+}
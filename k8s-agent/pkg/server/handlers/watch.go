@@ -0,0 +1,242 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/Sanskarzz/k8sgptclient/k8s-agent/api"
+	"github.com/Sanskarzz/k8sgptclient/k8s-agent/pkg/server"
+	"github.com/go-logr/logr"
+	appsv1 "k8s.io/api/apps/v1"
+	batchv1 "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	toolscache "k8s.io/client-go/tools/cache"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+)
+
+// keepAliveInterval is how often a comment is sent on idle streams so proxies do not close them
+const keepAliveInterval = 30 * time.Second
+
+// Watch event types, as in Kubernetes watches
+const (
+	watchAdded    = "ADDED"
+	watchModified = "MODIFIED"
+	watchDeleted  = "DELETED"
+)
+
+// watchStatus converts an informer object to the status sent to the client, false skips the object
+type watchStatus func(obj client.Object) (interface{}, bool)
+
+// WatchPods returns a handler for GET /watch/pods?namespace={namespace}&labelSelector={selector} endpoint.
+// It streams the status of matching pods as server-sent events, starting with the current pods.
+func (h *ClientHandler) WatchPods() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		logger := log.FromContext(r.Context()).WithName("watch-pods")
+
+		if r.Method != http.MethodGet {
+			err := fmt.Errorf("invalid method: %s, allowed: %s", r.Method, http.MethodGet)
+			logger.Error(err, "Method not allowed")
+			server.WriteError(w, r, http.StatusMethodNotAllowed, "Method not allowed")
+			return
+		}
+
+		// Parse query parameters, an empty namespace watches all namespaces
+		namespace := r.URL.Query().Get("namespace")
+		selector, err := labels.Parse(r.URL.Query().Get("labelSelector"))
+		if err != nil {
+			logger.Error(err, "Invalid label selector")
+			server.WriteError(w, r, http.StatusBadRequest, fmt.Sprintf("Invalid label selector: %v", err))
+			return
+		}
+
+		logger = logger.WithValues(
+			"namespace", namespace,
+			"labelSelector", selector.String(),
+		)
+		logger.Info("Watching pods")
+		// the informers of an agent restricted to namespaces only hold the objects of these namespaces
+		if namespace != "" && !h.checkNamespace(w, r, logger, namespace) {
+			return
+		}
+
+		h.watch(w, r, logger, &corev1.Pod{}, func(obj client.Object) (interface{}, bool) {
+			pod, ok := obj.(*corev1.Pod)
+			if !ok || (namespace != "" && pod.Namespace != namespace) || !selector.Matches(labels.Set(pod.Labels)) {
+				return nil, false
+			}
+			return newPodStatus(pod), true
+		})
+	}
+}
+
+// WatchWorkload returns a handler for GET /watch/{deployments,statefulsets,daemonsets,jobs,cronjobs}/{namespace}/{name}
+// endpoints. It streams the rollout status of the workload as server-sent events, starting with the current status.
+func (h *ClientHandler) WatchWorkload() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		logger := log.FromContext(r.Context()).WithName("watch-workload")
+
+		if r.Method != http.MethodGet {
+			err := fmt.Errorf("invalid method: %s, allowed: %s", r.Method, http.MethodGet)
+			logger.Error(err, "Method not allowed")
+			server.WriteError(w, r, http.StatusMethodNotAllowed, "Method not allowed")
+			return
+		}
+
+		// Parse path parameters
+		parts := strings.Split(r.URL.Path, "/")
+		if len(parts) != 5 {
+			err := fmt.Errorf("invalid path: %s, expected: /watch/{resource}/{namespace}/{name}", r.URL.Path)
+			logger.Error(err, "Invalid path")
+			server.WriteError(w, r, http.StatusBadRequest, "Invalid path. Expected: /watch/{resource}/{namespace}/{name}")
+			return
+		}
+		resource := parts[2]
+		namespace := parts[3]
+		name := parts[4]
+
+		logger = logger.WithValues(
+			"resource", resource,
+			"namespace", namespace,
+			"name", name,
+		)
+		logger.Info("Watching workload")
+		if !h.checkNamespace(w, r, logger, namespace) {
+			return
+		}
+
+		// Pick the informer and the status computation of the resource
+		var obj client.Object
+		var compute func(obj client.Object) interface{}
+		switch resource {
+		case "deployments":
+			obj = &appsv1.Deployment{}
+			compute = func(obj client.Object) interface{} { return rolloutStatus(obj.(*appsv1.Deployment)) }
+		case "statefulsets":
+			obj = &appsv1.StatefulSet{}
+			compute = func(obj client.Object) interface{} { return statefulSetStatus(obj.(*appsv1.StatefulSet)) }
+		case "daemonsets":
+			obj = &appsv1.DaemonSet{}
+			compute = func(obj client.Object) interface{} { return daemonSetStatus(obj.(*appsv1.DaemonSet)) }
+		case "jobs":
+			obj = &batchv1.Job{}
+			compute = func(obj client.Object) interface{} { return jobStatus(obj.(*batchv1.Job)) }
+		case "cronjobs":
+			obj = &batchv1.CronJob{}
+			compute = func(obj client.Object) interface{} { return cronJobStatus(obj.(*batchv1.CronJob)) }
+		default:
+			err := fmt.Errorf("unsupported resource: %s", resource)
+			logger.Error(err, "Unsupported resource")
+			server.WriteError(w, r, http.StatusNotFound, err.Error())
+			return
+		}
+
+		h.watch(w, r, logger, obj, func(obj client.Object) (interface{}, bool) {
+			if obj.GetNamespace() != namespace || obj.GetName() != name {
+				return nil, false
+			}
+			return compute(obj), true
+		})
+	}
+}
+
+// watch streams the changes of the objects of the informer of obj as server-sent events until the client goes away.
+// Every event is named after its type and carries a WatchEvent.
+func (h *ClientHandler) watch(w http.ResponseWriter, r *http.Request, logger logr.Logger, obj client.Object, status watchStatus) {
+	if h.Informers == nil {
+		logger.Info("Watches are disabled")
+		server.WriteError(w, r, http.StatusNotImplemented, "Watches are disabled")
+		return
+	}
+
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		err := fmt.Errorf("response writer does not support flushing")
+		logger.Error(err, "Streaming not supported")
+		server.WriteError(w, r, http.StatusInternalServerError, "Streaming not supported")
+		return
+	}
+
+	informer, err := h.Informers.GetInformer(r.Context(), obj)
+	if err != nil {
+		logger.Error(err, "Failed to get informer")
+		server.WriteAPIError(w, r, "Failed to get informer", err)
+		return
+	}
+
+	// Informers call handlers from their own goroutine, hand events over to the request goroutine.
+	// Each handler has its own unbounded queue, so blocking here does not slow down other handlers.
+	events := make(chan api.WatchEvent)
+	send := func(eventType string, obj interface{}) {
+		// deleted objects may only be known by their last state
+		if tombstone, ok := obj.(toolscache.DeletedFinalStateUnknown); ok {
+			obj = tombstone.Obj
+		}
+		object, ok := obj.(client.Object)
+		if !ok {
+			return
+		}
+		payload, ok := status(object)
+		if !ok {
+			return
+		}
+		select {
+		case events <- api.WatchEvent{Type: eventType, Object: payload}:
+		case <-r.Context().Done():
+		}
+	}
+	registration, err := informer.AddEventHandler(toolscache.ResourceEventHandlerFuncs{
+		AddFunc:    func(obj interface{}) { send(watchAdded, obj) },
+		UpdateFunc: func(_, obj interface{}) { send(watchModified, obj) },
+		DeleteFunc: func(obj interface{}) { send(watchDeleted, obj) },
+	})
+	if err != nil {
+		logger.Error(err, "Failed to register event handler")
+		server.WriteAPIError(w, r, "Failed to watch", err)
+		return
+	}
+	defer func() {
+		if err := informer.RemoveEventHandler(registration); err != nil {
+			logger.Error(err, "Failed to remove event handler")
+		}
+	}()
+
+	// Set headers for event stream response
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("X-Accel-Buffering", "no")
+	w.WriteHeader(http.StatusOK)
+	flusher.Flush()
+
+	logger.Info("Successfully started watch stream")
+
+	keepAlive := time.NewTicker(keepAliveInterval)
+	defer keepAlive.Stop()
+	for {
+		select {
+		case <-r.Context().Done():
+			logger.Info("Watch stream closed")
+			return
+		case <-keepAlive.C:
+			if _, err := fmt.Fprint(w, ": keep-alive\n\n"); err != nil {
+				logger.Error(err, "Failed to write keep-alive")
+				return
+			}
+		case event := <-events:
+			data, err := json.Marshal(event)
+			if err != nil {
+				logger.Error(err, "Failed to encode event")
+				continue
+			}
+			if _, err := fmt.Fprintf(w, "event: %s\ndata: %s\n\n", event.Type, data); err != nil {
+				logger.Error(err, "Failed to write event")
+				return
+			}
+		}
+		flusher.Flush()
+	}
+}
@@ -0,0 +1,251 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/Sanskarzz/k8sgptclient/k8s-agent/api"
+	"github.com/Sanskarzz/k8sgptclient/k8s-agent/pkg/server"
+	appsv1 "k8s.io/api/apps/v1"
+	batchv1 "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+)
+
+// statefulSetStatus computes the rollout status of a statefulset the way kubectl rollout status does
+func statefulSetStatus(sts *appsv1.StatefulSet) api.WorkloadStatus {
+	status := api.WorkloadStatus{
+		Kind:               "StatefulSet",
+		Name:               sts.Name,
+		Namespace:          sts.Namespace,
+		Generation:         sts.Generation,
+		ObservedGeneration: sts.Status.ObservedGeneration,
+		Replicas:           1,
+		UpdatedReplicas:    sts.Status.UpdatedReplicas,
+		ReadyReplicas:      sts.Status.ReadyReplicas,
+		AvailableReplicas:  sts.Status.AvailableReplicas,
+	}
+	if sts.Spec.Replicas != nil {
+		status.Replicas = *sts.Spec.Replicas
+	}
+
+	switch {
+	case sts.Spec.UpdateStrategy.Type != appsv1.RollingUpdateStatefulSetStrategyType:
+		// pods are only replaced when deleted, there is no rollout to wait for
+		status.Done = true
+		status.Message = fmt.Sprintf("statefulset %q uses the %s update strategy, rollout status is not tracked", sts.Name, sts.Spec.UpdateStrategy.Type)
+	case sts.Status.ObservedGeneration == 0 || sts.Generation > sts.Status.ObservedGeneration:
+		status.Message = "Waiting for statefulset spec update to be observed..."
+	case sts.Status.ReadyReplicas < status.Replicas:
+		status.Message = fmt.Sprintf("Waiting for %d pods to be ready...", status.Replicas-sts.Status.ReadyReplicas)
+	case sts.Spec.UpdateStrategy.RollingUpdate != nil && sts.Spec.UpdateStrategy.RollingUpdate.Partition != nil &&
+		*sts.Spec.UpdateStrategy.RollingUpdate.Partition > 0:
+		partition := *sts.Spec.UpdateStrategy.RollingUpdate.Partition
+		if sts.Status.UpdatedReplicas < status.Replicas-partition {
+			status.Message = fmt.Sprintf("Waiting for partitioned roll out to finish: %d out of %d new pods have been updated...",
+				sts.Status.UpdatedReplicas, status.Replicas-partition)
+		} else {
+			status.Done = true
+			status.Message = fmt.Sprintf("partitioned roll out complete: %d new pods have been updated...", sts.Status.UpdatedReplicas)
+		}
+	case sts.Status.UpdateRevision != sts.Status.CurrentRevision:
+		status.Message = fmt.Sprintf("waiting for statefulset rolling update to complete %d pods at revision %s...",
+			sts.Status.UpdatedReplicas, sts.Status.UpdateRevision)
+	default:
+		status.Done = true
+		status.Message = fmt.Sprintf("statefulset rolling update complete %d pods at revision %s...",
+			sts.Status.CurrentReplicas, sts.Status.CurrentRevision)
+	}
+	return status
+}
+
+// daemonSetStatus computes the rollout status of a daemonset the way kubectl rollout status does
+func daemonSetStatus(ds *appsv1.DaemonSet) api.WorkloadStatus {
+	status := api.WorkloadStatus{
+		Kind:               "DaemonSet",
+		Name:               ds.Name,
+		Namespace:          ds.Namespace,
+		Generation:         ds.Generation,
+		ObservedGeneration: ds.Status.ObservedGeneration,
+		Replicas:           ds.Status.DesiredNumberScheduled,
+		UpdatedReplicas:    ds.Status.UpdatedNumberScheduled,
+		ReadyReplicas:      ds.Status.NumberReady,
+		AvailableReplicas:  ds.Status.NumberAvailable,
+	}
+
+	switch {
+	case ds.Spec.UpdateStrategy.Type != appsv1.RollingUpdateDaemonSetStrategyType:
+		// pods are only replaced when deleted, there is no rollout to wait for
+		status.Done = true
+		status.Message = fmt.Sprintf("daemon set %q uses the %s update strategy, rollout status is not tracked", ds.Name, ds.Spec.UpdateStrategy.Type)
+	case ds.Generation > ds.Status.ObservedGeneration:
+		status.Message = "Waiting for daemon set spec update to be observed..."
+	case ds.Status.UpdatedNumberScheduled < ds.Status.DesiredNumberScheduled:
+		status.Message = fmt.Sprintf("Waiting for daemon set %q rollout to finish: %d out of %d new pods have been updated...",
+			ds.Name, ds.Status.UpdatedNumberScheduled, ds.Status.DesiredNumberScheduled)
+	case ds.Status.NumberAvailable < ds.Status.DesiredNumberScheduled:
+		status.Message = fmt.Sprintf("Waiting for daemon set %q rollout to finish: %d of %d updated pods are available...",
+			ds.Name, ds.Status.NumberAvailable, ds.Status.DesiredNumberScheduled)
+	default:
+		status.Done = true
+		status.Message = fmt.Sprintf("daemon set %q successfully rolled out", ds.Name)
+	}
+	return status
+}
+
+// jobStatus computes the completion status of a job from its Complete and Failed conditions
+func jobStatus(job *batchv1.Job) api.WorkloadStatus {
+	status := api.WorkloadStatus{
+		Kind:       "Job",
+		Name:       job.Name,
+		Namespace:  job.Namespace,
+		Generation: job.Generation,
+		Replicas:   1,
+		Active:     job.Status.Active,
+		Succeeded:  job.Status.Succeeded,
+		FailedPods: job.Status.Failed,
+	}
+	if job.Spec.Completions != nil {
+		status.Replicas = *job.Spec.Completions
+	}
+	// the pod template of a job is immutable, changes go through its cronjob
+	if owner := metav1.GetControllerOf(job); owner != nil {
+		status.Owner = owner.Kind + "/" + owner.Name
+	}
+	if job.Status.Ready != nil {
+		status.ReadyReplicas = *job.Status.Ready
+	}
+
+	for _, condition := range job.Status.Conditions {
+		if condition.Status != corev1.ConditionTrue {
+			continue
+		}
+		switch condition.Type {
+		case batchv1.JobComplete:
+			status.Done = true
+			status.Message = fmt.Sprintf("job %q completed", job.Name)
+			return status
+		case batchv1.JobFailed:
+			status.Failed = true
+			status.Message = fmt.Sprintf("job %q failed: %s: %s", job.Name, condition.Reason, condition.Message)
+			return status
+		}
+	}
+	status.Message = fmt.Sprintf("Waiting for job %q to complete: %d active, %d succeeded, %d failed...",
+		job.Name, job.Status.Active, job.Status.Succeeded, job.Status.Failed)
+	return status
+}
+
+// cronJobStatus reports the schedule of a cronjob. Changes to a cronjob only take effect on the next
+// scheduled job, so there is nothing to wait for and a cronjob is always done.
+func cronJobStatus(cronJob *batchv1.CronJob) api.WorkloadStatus {
+	status := api.WorkloadStatus{
+		Kind:       "CronJob",
+		Name:       cronJob.Name,
+		Namespace:  cronJob.Namespace,
+		Generation: cronJob.Generation,
+		Active:     int32(len(cronJob.Status.Active)),
+		Done:       true,
+	}
+
+	switch {
+	case cronJob.Spec.Suspend != nil && *cronJob.Spec.Suspend:
+		status.Message = fmt.Sprintf("cronjob %q is suspended", cronJob.Name)
+	case cronJob.Status.LastScheduleTime == nil:
+		status.Message = fmt.Sprintf("cronjob %q has not been scheduled yet, schedule %q", cronJob.Name, cronJob.Spec.Schedule)
+	default:
+		status.Message = fmt.Sprintf("cronjob %q last scheduled at %s, %d active jobs",
+			cronJob.Name, cronJob.Status.LastScheduleTime.UTC().Format("2006-01-02T15:04:05Z"), len(cronJob.Status.Active))
+	}
+	return status
+}
+
+// WorkloadStatus returns a handler for GET /{statefulsets,daemonsets,jobs,cronjobs}/{namespace}/{name}/status endpoints
+func (h *ClientHandler) WorkloadStatus() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		logger := log.FromContext(r.Context()).WithName("workload-status")
+
+		if r.Method != http.MethodGet {
+			err := fmt.Errorf("invalid method: %s, allowed: %s", r.Method, http.MethodGet)
+			logger.Error(err, "Method not allowed")
+			server.WriteError(w, r, http.StatusMethodNotAllowed, "Method not allowed")
+			return
+		}
+
+		// Parse path parameters
+		parts := strings.Split(r.URL.Path, "/")
+		if len(parts) != 5 {
+			err := fmt.Errorf("invalid path: %s, expected: /{resource}/{namespace}/{name}/status", r.URL.Path)
+			logger.Error(err, "Invalid path")
+			server.WriteError(w, r, http.StatusBadRequest, "Invalid path. Expected: /{resource}/{namespace}/{name}/status")
+			return
+		}
+		resource := parts[1]
+		key := types.NamespacedName{
+			Namespace: parts[2],
+			Name:      parts[3],
+		}
+
+		logger = logger.WithValues(
+			"resource", resource,
+			"namespace", key.Namespace,
+			"name", key.Name,
+		)
+		logger.Info("Getting workload status")
+		if !h.checkNamespace(w, r, logger, key.Namespace) {
+			return
+		}
+
+		// Get the workload and compute its status
+		var obj client.Object
+		var compute func() api.WorkloadStatus
+		switch resource {
+		case "statefulsets":
+			sts := &appsv1.StatefulSet{}
+			obj, compute = sts, func() api.WorkloadStatus { return statefulSetStatus(sts) }
+		case "daemonsets":
+			ds := &appsv1.DaemonSet{}
+			obj, compute = ds, func() api.WorkloadStatus { return daemonSetStatus(ds) }
+		case "jobs":
+			job := &batchv1.Job{}
+			obj, compute = job, func() api.WorkloadStatus { return jobStatus(job) }
+		case "cronjobs":
+			cronJob := &batchv1.CronJob{}
+			obj, compute = cronJob, func() api.WorkloadStatus { return cronJobStatus(cronJob) }
+		default:
+			err := fmt.Errorf("unsupported resource: %s", resource)
+			logger.Error(err, "Unsupported resource")
+			server.WriteError(w, r, http.StatusNotFound, err.Error())
+			return
+		}
+		if err := h.Client.Get(r.Context(), key, obj); err != nil {
+			if apierrors.IsNotFound(err) {
+				logger.Info("Workload not found")
+			} else {
+				logger.Error(err, "Failed to get workload")
+			}
+			server.WriteAPIError(w, r, "Failed to get workload", err)
+			return
+		}
+
+		status := compute()
+		logger.V(1).Info("Computed workload status", "done", status.Done, "failed", status.Failed)
+
+		// Set response headers
+		w.Header().Set("Content-Type", "application/json")
+
+		// Write response
+		if err := json.NewEncoder(w).Encode(status); err != nil {
+			logger.Error(err, "Failed to encode response")
+			server.WriteAPIError(w, r, "Failed to encode response", err)
+			return
+		}
+		logger.V(1).Info("Response sent successfully")
+	}
+}
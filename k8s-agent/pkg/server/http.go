@@ -48,7 +48,11 @@ func RunHttp(ctx context.Context, server *http.Server, certFile, keyFile string)
 		serve := func() error {
 			logger.Info("HTTP Server starting")
 
-			if certFile != "" && keyFile != "" {
+			if server.TLSConfig != nil && server.TLSConfig.GetCertificate != nil {
+				logger.Info("Starting HTTPS server with reloadable certificate")
+				// certificates are provided by the tls config
+				return server.ListenAndServeTLS("", "")
+			} else if certFile != "" && keyFile != "" {
 				logger.Info("Starting HTTPS server",
 					"certFile", certFile,
 					"keyFile", keyFile,
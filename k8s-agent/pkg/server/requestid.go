@@ -0,0 +1,36 @@
+package server
+
+import (
+	"context"
+	"net/http"
+
+	"k8s.io/apimachinery/pkg/util/rand"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+)
+
+// RequestIDHeader is the header carrying the request ID, callers may set it to correlate requests
+const RequestIDHeader = "X-Request-ID"
+
+type requestIDKey struct{}
+
+// RequestIDFromContext returns the ID of the request being served, if any
+func RequestIDFromContext(ctx context.Context) string {
+	id, _ := ctx.Value(requestIDKey{}).(string)
+	return id
+}
+
+// WithRequestID assigns an ID to every request, reusing the caller provided X-Request-ID if any.
+// The ID is returned in the response headers and added to the request logger.
+func WithRequestID(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		id := r.Header.Get(RequestIDHeader)
+		if id == "" || len(id) > 128 {
+			id = rand.String(16)
+		}
+		w.Header().Set(RequestIDHeader, id)
+
+		ctx := context.WithValue(r.Context(), requestIDKey{}, id)
+		ctx = log.IntoContext(ctx, log.FromContext(ctx).WithValues("requestID", id))
+		next.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
@@ -0,0 +1,197 @@
+package server
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"net/http"
+	"os"
+	"sync"
+	"time"
+
+	"sigs.k8s.io/controller-runtime/pkg/certwatcher"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+)
+
+// caReloadInterval is how often the client CA bundle is checked for changes
+const caReloadInterval = 10 * time.Second
+
+// TLSOptions configures https serving
+type TLSOptions struct {
+	// CertFile and KeyFile are the serving certificate and key
+	CertFile string
+	KeyFile  string
+	// ClientCAFile is the CA bundle used to verify client certificates, empty to disable mTLS
+	ClientCAFile string
+}
+
+// Enabled returns true if a serving certificate is configured
+func (o TLSOptions) Enabled() bool {
+	return o.CertFile != "" && o.KeyFile != ""
+}
+
+// MutualTLS returns true if client certificates are verified
+func (o TLSOptions) MutualTLS() bool {
+	return o.Enabled() && o.ClientCAFile != ""
+}
+
+// NewTLSConfig creates a tls config serving the configured certificate. The certificate and the client CA
+// bundle are reloaded when the files change on disk (e.g. when cert-manager rotates a mounted secret)
+// until the context is cancelled.
+func NewTLSConfig(ctx context.Context, opts TLSOptions) (*tls.Config, error) {
+	logger := log.FromContext(ctx).WithName("tls")
+
+	// watch serving certificate
+	certWatcher, err := certwatcher.New(opts.CertFile, opts.KeyFile)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load serving certificate: %w", err)
+	}
+	go func() {
+		if err := certWatcher.Start(ctx); err != nil {
+			logger.Error(err, "Serving certificate watcher stopped with error")
+		}
+	}()
+
+	config := &tls.Config{
+		MinVersion:     tls.VersionTLS12,
+		GetCertificate: certWatcher.GetCertificate,
+	}
+	if opts.ClientCAFile == "" {
+		return config, nil
+	}
+
+	// watch client CA bundle
+	caWatcher := &caWatcher{file: opts.ClientCAFile}
+	if err := caWatcher.load(); err != nil {
+		return nil, fmt.Errorf("failed to load client CA: %w", err)
+	}
+	go caWatcher.start(ctx)
+
+	// Client certificates are verified when presented but not required at the TLS level so that
+	// kubelet probes keep working, API routes enforce them with RequireClientCert.
+	config.GetConfigForClient = func(*tls.ClientHelloInfo) (*tls.Config, error) {
+		return &tls.Config{
+			MinVersion:     tls.VersionTLS12,
+			GetCertificate: certWatcher.GetCertificate,
+			ClientAuth:     tls.VerifyClientCertIfGiven,
+			ClientCAs:      caWatcher.pool(),
+		}, nil
+	}
+	return config, nil
+}
+
+// NewForwardTLSConfig creates the tls config used to forward requests to another agent. Agents share their serving
+// certificate, so the peer certificate is verified for serverName against the system roots and the client CA bundle,
+// and the serving certificate is presented as client certificate when mutual TLS is enabled.
+func NewForwardTLSConfig(ctx context.Context, opts TLSOptions, serverName string) (*tls.Config, error) {
+	logger := log.FromContext(ctx).WithName("tls")
+
+	roots, err := x509.SystemCertPool()
+	if err != nil {
+		roots = x509.NewCertPool()
+	}
+	if opts.ClientCAFile != "" {
+		data, err := os.ReadFile(opts.ClientCAFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load client CA: %w", err)
+		}
+		if !roots.AppendCertsFromPEM(data) {
+			return nil, fmt.Errorf("no certificates found in %s", opts.ClientCAFile)
+		}
+	}
+	config := &tls.Config{
+		MinVersion: tls.VersionTLS12,
+		RootCAs:    roots,
+		ServerName: serverName,
+	}
+	if !opts.MutualTLS() {
+		return config, nil
+	}
+
+	// watch serving certificate
+	certWatcher, err := certwatcher.New(opts.CertFile, opts.KeyFile)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load serving certificate: %w", err)
+	}
+	go func() {
+		if err := certWatcher.Start(ctx); err != nil {
+			logger.Error(err, "Forwarding certificate watcher stopped with error")
+		}
+	}()
+	config.GetClientCertificate = func(*tls.CertificateRequestInfo) (*tls.Certificate, error) {
+		return certWatcher.GetCertificate(nil)
+	}
+	return config, nil
+}
+
+// RequireClientCert rejects requests that did not present a client certificate verified against the client CA
+func RequireClientCert(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.TLS == nil || len(r.TLS.VerifiedChains) == 0 {
+			log.FromContext(r.Context()).WithName("tls").Info("Missing client certificate", "path", r.URL.Path)
+			WriteError(w, r, http.StatusUnauthorized, "Client certificate required")
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// caWatcher keeps a CA pool in sync with a PEM bundle on disk
+type caWatcher struct {
+	file string
+
+	mu       sync.RWMutex
+	current  []byte
+	certPool *x509.CertPool
+}
+
+func (c *caWatcher) pool() *x509.CertPool {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.certPool
+}
+
+func (c *caWatcher) load() error {
+	data, err := os.ReadFile(c.file)
+	if err != nil {
+		return err
+	}
+
+	c.mu.RLock()
+	unchanged := bytes.Equal(data, c.current)
+	c.mu.RUnlock()
+	if unchanged {
+		return nil
+	}
+
+	pool := x509.NewCertPool()
+	if !pool.AppendCertsFromPEM(data) {
+		return fmt.Errorf("no certificates found in %s", c.file)
+	}
+
+	c.mu.Lock()
+	c.current = data
+	c.certPool = pool
+	c.mu.Unlock()
+	return nil
+}
+
+func (c *caWatcher) start(ctx context.Context) {
+	logger := log.FromContext(ctx).WithName("tls").WithValues("file", c.file)
+
+	ticker := time.NewTicker(caReloadInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			// keep serving the previous bundle if the new one can't be loaded
+			if err := c.load(); err != nil {
+				logger.Error(err, "Failed to reload client CA")
+			}
+		}
+	}
+}
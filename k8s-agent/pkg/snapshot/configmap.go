@@ -0,0 +1,150 @@
+package snapshot
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sort"
+
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+const (
+	// revisionLabel marks the ConfigMaps holding revisions
+	revisionLabel = "k8sgptclient.io/revision"
+	// revisionKey is the ConfigMap data key holding the revision
+	revisionKey = "revision.json"
+	// configMapPrefix is the name prefix of the ConfigMaps holding revisions
+	configMapPrefix = "k8sgptclient-revision-"
+)
+
+// ConfigMapStore stores each revision in a ConfigMap of the given namespace. Revisions holding the data of Secrets
+// are stored in Secrets instead, so that only the callers allowed to read Secrets can read them.
+type ConfigMapStore struct {
+	client    client.Client
+	reader    client.Reader
+	namespace string
+	retention int
+}
+
+// NewConfigMapStore creates a new ConfigMapStore. Reads go through reader (usually the manager API reader) so
+// that ConfigMaps are not cached cluster wide. Only the latest retention revisions are kept, 0 keeps everything.
+func NewConfigMapStore(client client.Client, reader client.Reader, namespace string, retention int) *ConfigMapStore {
+	return &ConfigMapStore{
+		client:    client,
+		reader:    reader,
+		namespace: namespace,
+		retention: retention,
+	}
+}
+
+// Save stores the revision and prunes revisions beyond retention
+func (s *ConfigMapStore) Save(ctx context.Context, revision *Revision) error {
+	data, err := json.Marshal(revision)
+	if err != nil {
+		return fmt.Errorf("failed to marshal revision: %w", err)
+	}
+	meta := metav1.ObjectMeta{
+		Name:      configMapPrefix + revision.ID,
+		Namespace: s.namespace,
+		Labels: map[string]string{
+			revisionLabel: "true",
+		},
+	}
+	if revision.HasSecretData() {
+		secret := &corev1.Secret{
+			ObjectMeta: meta,
+			Type:       corev1.SecretTypeOpaque,
+			Data: map[string][]byte{
+				revisionKey: data,
+			},
+		}
+		if err := s.client.Create(ctx, secret); err != nil {
+			return fmt.Errorf("failed to create revision secret: %w", err)
+		}
+		return s.prune(ctx)
+	}
+	configMap := &corev1.ConfigMap{
+		ObjectMeta: meta,
+		Data: map[string]string{
+			revisionKey: string(data),
+		},
+	}
+	if err := s.client.Create(ctx, configMap); err != nil {
+		return fmt.Errorf("failed to create revision configmap: %w", err)
+	}
+	return s.prune(ctx)
+}
+
+// Get returns the revision with the given ID
+func (s *ConfigMapStore) Get(ctx context.Context, id string) (*Revision, error) {
+	key := client.ObjectKey{Namespace: s.namespace, Name: configMapPrefix + id}
+	var data []byte
+	var configMap corev1.ConfigMap
+	err := s.reader.Get(ctx, key, &configMap)
+	switch {
+	case err == nil:
+		data = []byte(configMap.Data[revisionKey])
+	case apierrors.IsNotFound(err):
+		// revisions holding Secret data are Secrets
+		var secret corev1.Secret
+		if err := s.reader.Get(ctx, key, &secret); err != nil {
+			if apierrors.IsNotFound(err) {
+				return nil, ErrNotFound
+			}
+			return nil, fmt.Errorf("failed to get revision secret: %w", err)
+		}
+		data = secret.Data[revisionKey]
+	default:
+		return nil, fmt.Errorf("failed to get revision configmap: %w", err)
+	}
+	var revision Revision
+	if err := json.Unmarshal(data, &revision); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal revision: %w", err)
+	}
+	return &revision, nil
+}
+
+// prune deletes the oldest revisions beyond retention, ConfigMaps and Secrets alike
+func (s *ConfigMapStore) prune(ctx context.Context) error {
+	if s.retention <= 0 {
+		return nil
+	}
+	var configMaps corev1.ConfigMapList
+	if err := s.reader.List(ctx, &configMaps,
+		client.InNamespace(s.namespace),
+		client.HasLabels{revisionLabel},
+	); err != nil {
+		return fmt.Errorf("failed to list revision configmaps: %w", err)
+	}
+	var secrets corev1.SecretList
+	if err := s.reader.List(ctx, &secrets,
+		client.InNamespace(s.namespace),
+		client.HasLabels{revisionLabel},
+	); err != nil {
+		return fmt.Errorf("failed to list revision secrets: %w", err)
+	}
+	revisions := make([]client.Object, 0, len(configMaps.Items)+len(secrets.Items))
+	for i := range configMaps.Items {
+		revisions = append(revisions, &configMaps.Items[i])
+	}
+	for i := range secrets.Items {
+		revisions = append(revisions, &secrets.Items[i])
+	}
+	if len(revisions) <= s.retention {
+		return nil
+	}
+	// revision IDs start with their creation time, so names sort chronologically
+	sort.Slice(revisions, func(i, j int) bool {
+		return revisions[i].GetName() < revisions[j].GetName()
+	})
+	for _, revision := range revisions[:len(revisions)-s.retention] {
+		if err := client.IgnoreNotFound(s.client.Delete(ctx, revision)); err != nil {
+			return fmt.Errorf("failed to prune revision %s: %w", revision.GetName(), err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,107 @@
+package snapshot
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// fileSuffix is the extension of revision files
+const fileSuffix = ".json"
+
+// FileStore stores each revision as a JSON file in a local directory
+type FileStore struct {
+	dir       string
+	retention int
+
+	mu sync.Mutex
+}
+
+// NewFileStore creates a new FileStore, creating the directory if needed.
+// Only the latest retention revisions are kept, 0 keeps everything.
+func NewFileStore(dir string, retention int) (*FileStore, error) {
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		return nil, fmt.Errorf("failed to create snapshot directory: %w", err)
+	}
+	return &FileStore{
+		dir:       dir,
+		retention: retention,
+	}, nil
+}
+
+// Save stores the revision and prunes revisions beyond retention. The data of Secrets is left out
+// so that it never lands in plain text on disk, these Secrets can't be rolled back.
+func (s *FileStore) Save(_ context.Context, revision *Revision) error {
+	data, err := json.Marshal(revision.WithoutSecretData())
+	if err != nil {
+		return fmt.Errorf("failed to marshal revision: %w", err)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	// write to a temporary file first so a crash never leaves a partial revision
+	path := filepath.Join(s.dir, revision.ID+fileSuffix)
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0o600); err != nil {
+		return fmt.Errorf("failed to write revision: %w", err)
+	}
+	if err := os.Rename(tmp, path); err != nil {
+		return fmt.Errorf("failed to write revision: %w", err)
+	}
+	return s.prune()
+}
+
+// Get returns the revision with the given ID
+func (s *FileStore) Get(_ context.Context, id string) (*Revision, error) {
+	// IDs come from the request path, never let them escape the directory
+	if id == "" || strings.ContainsAny(id, `/\`) || strings.Contains(id, "..") {
+		return nil, ErrNotFound
+	}
+	data, err := os.ReadFile(filepath.Join(s.dir, id+fileSuffix))
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return nil, ErrNotFound
+		}
+		return nil, fmt.Errorf("failed to read revision: %w", err)
+	}
+	var revision Revision
+	if err := json.Unmarshal(data, &revision); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal revision: %w", err)
+	}
+	return &revision, nil
+}
+
+// prune deletes the oldest revisions beyond retention
+func (s *FileStore) prune() error {
+	if s.retention <= 0 {
+		return nil
+	}
+	entries, err := os.ReadDir(s.dir)
+	if err != nil {
+		return fmt.Errorf("failed to list revisions: %w", err)
+	}
+	var names []string
+	for _, entry := range entries {
+		if !entry.IsDir() && strings.HasSuffix(entry.Name(), fileSuffix) {
+			names = append(names, entry.Name())
+		}
+	}
+	if len(names) <= s.retention {
+		return nil
+	}
+	// revision IDs start with their creation time, so names sort chronologically
+	sort.Strings(names)
+	for _, name := range names[:len(names)-s.retention] {
+		if err := os.Remove(filepath.Join(s.dir, name)); err != nil && !errors.Is(err, os.ErrNotExist) {
+			return fmt.Errorf("failed to prune revision: %w", err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,95 @@
+package snapshot
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/util/rand"
+)
+
+// ErrNotFound is returned when a revision does not exist in the store
+var ErrNotFound = errors.New("revision not found")
+
+// Revision holds the state of the objects of an apply request before they were applied
+type Revision struct {
+	ID        string    `json:"id"`
+	CreatedAt time.Time `json:"createdAt"`
+	Objects   []Object  `json:"objects"`
+}
+
+// Object identifies an applied object and holds its state before the apply
+type Object struct {
+	APIVersion string `json:"apiVersion"`
+	Kind       string `json:"kind"`
+	Namespace  string `json:"namespace,omitempty"`
+	Name       string `json:"name"`
+	// Live is the object before the apply, nil if the apply created it
+	Live *unstructured.Unstructured `json:"live,omitempty"`
+	// DataOmitted is true when the store left out the data of a Secret, which then can't be restored
+	DataOmitted bool `json:"dataOmitted,omitempty"`
+}
+
+// IsSecret returns true when the object is a Secret, whose data must not be stored in plain text
+func (o *Object) IsSecret() bool {
+	return o.APIVersion == "v1" && o.Kind == "Secret"
+}
+
+// Store persists revisions
+type Store interface {
+	Save(ctx context.Context, revision *Revision) error
+	Get(ctx context.Context, id string) (*Revision, error)
+}
+
+// NewRevision creates a revision with a new ID, sortable by creation time
+func NewRevision() *Revision {
+	now := time.Now().UTC()
+	return &Revision{
+		ID:        fmt.Sprintf("%s-%s", now.Format("20060102-150405"), rand.String(5)),
+		CreatedAt: now,
+	}
+}
+
+// Add records the state of obj before it is applied, live is nil if obj does not exist yet
+func (r *Revision) Add(obj, live *unstructured.Unstructured) {
+	entry := Object{
+		APIVersion: obj.GetAPIVersion(),
+		Kind:       obj.GetKind(),
+		Namespace:  obj.GetNamespace(),
+		Name:       obj.GetName(),
+	}
+	if live != nil {
+		entry.Live = live.DeepCopy()
+		// managed fields are not needed to restore the object and can be large
+		entry.Live.SetManagedFields(nil)
+	}
+	r.Objects = append(r.Objects, entry)
+}
+
+// HasSecretData returns true when the revision holds the data of a Secret
+func (r *Revision) HasSecretData() bool {
+	for i := range r.Objects {
+		if r.Objects[i].IsSecret() && r.Objects[i].Live != nil && !r.Objects[i].DataOmitted {
+			return true
+		}
+	}
+	return false
+}
+
+// WithoutSecretData returns a copy of the revision without the data and stringData of its Secrets
+func (r *Revision) WithoutSecretData() *Revision {
+	copied := *r
+	copied.Objects = make([]Object, len(r.Objects))
+	for i, entry := range r.Objects {
+		if entry.IsSecret() && entry.Live != nil {
+			entry.Live = entry.Live.DeepCopy()
+			unstructured.RemoveNestedField(entry.Live.Object, "data")
+			unstructured.RemoveNestedField(entry.Live.Object, "stringData")
+			entry.DataOmitted = true
+		}
+		copied.Objects[i] = entry
+	}
+	return &copied
+}
@@ -3,6 +3,7 @@ module github.com/Sanskarzz/k8sgptclient/k8sgpt-remediation
 go 1.23.4
 
 require (
+	github.com/Sanskarzz/k8sgptclient/k8s-agent/api v0.0.0
 	github.com/fatih/color v1.18.0
 	github.com/google/gnostic v0.7.0
 	github.com/gptscript-ai/go-gptscript v0.9.5
@@ -11,6 +12,8 @@ require (
 	github.com/schollz/progressbar/v3 v3.18.0
 	github.com/spf13/cobra v1.9.1
 	github.com/spf13/viper v1.19.0
+	k8s.io/api v0.32.2
+	k8s.io/apimachinery v0.32.2
 	sigs.k8s.io/controller-runtime v0.19.3
 )
 
@@ -293,9 +296,7 @@ require (
 	gopkg.in/yaml.v2 v2.4.0 // indirect
 	gopkg.in/yaml.v3 v3.0.1 // indirect
 	helm.sh/helm/v3 v3.16.3 // indirect
-	k8s.io/api v0.32.2 // indirect
 	k8s.io/apiextensions-apiserver v0.32.1 // indirect
-	k8s.io/apimachinery v0.32.2 // indirect
 	k8s.io/apiserver v0.32.1 // indirect
 	k8s.io/cli-runtime v0.31.1 // indirect
 	k8s.io/client-go v0.32.2 // indirect
@@ -314,3 +315,5 @@ require (
 	sigs.k8s.io/structured-merge-diff/v4 v4.4.2 // indirect
 	sigs.k8s.io/yaml v1.4.0 // indirect
 )
+
+replace github.com/Sanskarzz/k8sgptclient/k8s-agent/api => ../k8s-agent/api
@@ -10,6 +10,7 @@ import (
 	"sync"
 	"time"
 
+	"github.com/Sanskarzz/k8sgptclient/k8s-agent/api/agentclient"
 	"github.com/Sanskarzz/k8sgptclient/k8sgpt-remediation/pkg/ai"
 	"github.com/Sanskarzz/k8sgptclient/k8sgpt-remediation/pkg/gptscript"
 	"github.com/fatih/color"
@@ -412,6 +413,9 @@ func Command() *cobra.Command {
 	var (
 		httpAddress    string
 		agentURL       string
+		agentTokenFile string
+		agentTimeout   time.Duration
+		agentTLS       gptscript.AgentTLSOptions
 		backend        string
 		model          string
 		password       string
@@ -426,6 +430,7 @@ func Command() *cobra.Command {
 		withDoc        bool
 		withStats      bool
 		configFile     string
+		onConflict     string
 	)
 
 	command := &cobra.Command{
@@ -433,13 +438,33 @@ func Command() *cobra.Command {
 		Short: "Run k8sgptclient remediation-server",
 		RunE: func(cmd *cobra.Command, args []string) error {
 
+			conflictPolicy := gptscript.ConflictPolicy(onConflict)
+			if conflictPolicy != gptscript.ConflictForce && conflictPolicy != gptscript.ConflictSkip {
+				return fmt.Errorf("invalid --on-conflict %q, expected one of force, skip", onConflict)
+			}
+
 			ticker := time.NewTicker(1 * time.Minute)
 			defer ticker.Stop()
 
 			log.Printf("Starting remediation server on %s", httpAddress)
 			log.Printf("K8s agent URL: %s", agentURL)
+			// Initialize the k8s agent http client
+			agentHTTPClient, err := gptscript.NewAgentHTTPClient(cmd.Context(), agentTLS)
+			if err != nil {
+				return fmt.Errorf("failed to create agent http client: %v", err)
+			}
+			agent, err := agentclient.New(agentclient.Options{
+				URL:        agentURL,
+				HTTPClient: agentHTTPClient,
+				TokenFile:  agentTokenFile,
+				Timeout:    agentTimeout,
+			})
+			if err != nil {
+				return fmt.Errorf("failed to create agent client: %v", err)
+			}
+
 			// Initialize remediation generator
-			remediator, err := gptscript.NewRemediationGenerator(apiKey, agentURL)
+			remediator, err := gptscript.NewRemediationGenerator(apiKey, agent, conflictPolicy)
 			if err != nil {
 				log.Printf("Failed to initialize remediation generator: %v", err)
 			}
@@ -490,9 +515,15 @@ func Command() *cobra.Command {
 	// Add all required flags
 	command.Flags().StringVar(&httpAddress, "http-address", ":9090", "The address the remediation server binds to")
 	command.Flags().StringVar(&agentURL, "agent-url", "http://k8s-agent.k8sgptclient.svc.cluster.local:8080", "K8s agent service URL")
+	command.Flags().StringVar(&agentTLS.CAFile, "agent-ca-file", "", "CA bundle used to verify the K8s agent serving certificate")
+	command.Flags().StringVar(&agentTLS.CertFile, "agent-client-cert-file", "", "Client certificate presented to the K8s agent for mutual TLS (reloaded on change)")
+	command.Flags().StringVar(&agentTLS.KeyFile, "agent-client-key-file", "", "Client private key presented to the K8s agent for mutual TLS (reloaded on change)")
+	command.Flags().StringVar(&agentTokenFile, "agent-token-file", "/var/run/secrets/k8sgptclient/agent-token", "Projected service account token used to authenticate to the K8s agent (empty to disable)")
+	command.Flags().DurationVar(&agentTimeout, "agent-timeout", agentclient.DefaultTimeout, "Timeout of K8s agent requests, watch and log streams excluded")
+	command.Flags().StringVar(&onConflict, "on-conflict", string(gptscript.ConflictForce), "What to do with remediations changing fields owned by other field managers (Argo CD, Helm, HPAs, kubectl): force or skip")
 	command.Flags().StringVar(&backend, "backend", "openai", "AI backend to use (openai, azure, etc)")
 	command.Flags().StringVar(&language, "language", "english", "Language for analysis output")
-	command.Flags().StringSliceVar(&filters, "filters", []string{"Deployment", "Pod"}, "Resource types to analyze")
+	command.Flags().StringSliceVar(&filters, "filters", []string{"Deployment", "Pod", "StatefulSet", "CronJob"}, "Resource types to analyze")
 	command.Flags().StringVar(&namespace, "namespace", "", "Kubernetes namespace to analyze (empty for all)")
 	command.Flags().StringVar(&labelSelector, "selector", "", "Label selector to filter resources")
 	command.Flags().BoolVar(&noCache, "no-cache", true, "Disable caching of analysis results")
@@ -0,0 +1,65 @@
+package gptscript
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+
+	"sigs.k8s.io/controller-runtime/pkg/certwatcher"
+)
+
+// AgentTLSOptions configures how the remediation server connects to the k8s agent over https
+type AgentTLSOptions struct {
+	// CAFile is the CA bundle used to verify the agent serving certificate, empty to use the system roots
+	CAFile string
+	// CertFile and KeyFile are the client certificate presented to the agent for mutual TLS
+	CertFile string
+	KeyFile  string
+}
+
+// NewAgentHTTPClient creates the http client used to call the k8s agent. The client certificate is
+// reloaded when the files change on disk until the context is cancelled. The client has no timeout,
+// the agent client bounds requests itself so that watch and log streams are not cut short.
+func NewAgentHTTPClient(ctx context.Context, opts AgentTLSOptions) (*http.Client, error) {
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.TLSClientConfig = &tls.Config{
+		MinVersion: tls.VersionTLS12,
+	}
+
+	// Trust the agent CA
+	if opts.CAFile != "" {
+		caData, err := os.ReadFile(opts.CAFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read agent CA: %v", err)
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(caData) {
+			return nil, fmt.Errorf("no certificates found in %s", opts.CAFile)
+		}
+		transport.TLSClientConfig.RootCAs = pool
+	}
+
+	// Present a client certificate
+	if opts.CertFile != "" || opts.KeyFile != "" {
+		watcher, err := certwatcher.New(opts.CertFile, opts.KeyFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load agent client certificate: %v", err)
+		}
+		go func() {
+			if err := watcher.Start(ctx); err != nil {
+				log.Printf("Agent client certificate watcher stopped with error: %v", err)
+			}
+		}()
+		transport.TLSClientConfig.GetClientCertificate = func(*tls.CertificateRequestInfo) (*tls.Certificate, error) {
+			return watcher.GetCertificate(nil)
+		}
+	}
+
+	return &http.Client{
+		Transport: transport,
+	}, nil
+}
@@ -2,79 +2,54 @@ package gptscript
 
 import (
 	"context"
-	"encoding/json"
 	"fmt"
-	"io/ioutil"
 	"log"
-	"net/http"
+	"sort"
 	"strings"
 	"time"
 
+	"github.com/Sanskarzz/k8sgptclient/k8s-agent/api"
+	"github.com/Sanskarzz/k8sgptclient/k8s-agent/api/agentclient"
 	"github.com/gptscript-ai/go-gptscript"
 	"github.com/k8sgpt-ai/k8sgpt/pkg/common"
+	appsv1 "k8s.io/api/apps/v1"
+	batchv1 "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime/schema"
 )
 
-type RemediationGenerator struct {
-	agentURL string
-	g        *gptscript.GPTScript
-}
-
-type PodStatus struct {
-	Name            string            `json:"name"`
-	Namespace       string            `json:"namespace"`
-	Phase           string            `json:"phase"`
-	Conditions      []PodCondition    `json:"conditions"`
-	ContainerStatus []ContainerStatus `json:"containerStatus"`
-	StartTime       string            `json:"startTime"`
-	PodIP           string            `json:"podIP"`
-	HostIP          string            `json:"hostIP"`
-	ProbeResults    []ProbeResult     `json:"probeResults"`
+// workloadKinds lists the kinds the remediation server can fix, with the resource addressing them on the k8s agent
+var workloadKinds = map[string]schema.GroupVersionResource{
+	"Pod":         corev1.SchemeGroupVersion.WithResource("pods"),
+	"Deployment":  appsv1.SchemeGroupVersion.WithResource("deployments"),
+	"StatefulSet": appsv1.SchemeGroupVersion.WithResource("statefulsets"),
+	"DaemonSet":   appsv1.SchemeGroupVersion.WithResource("daemonsets"),
+	"Job":         batchv1.SchemeGroupVersion.WithResource("jobs"),
+	"CronJob":     batchv1.SchemeGroupVersion.WithResource("cronjobs"),
 }
 
-type PodCondition struct {
-	Type               string `json:"type"`
-	Status             string `json:"status"`
-	LastProbeTime      string `json:"lastProbeTime"`
-	LastTransitionTime string `json:"lastTransitionTime"`
-	Reason             string `json:"reason,omitempty"`
-	Message            string `json:"message,omitempty"`
-}
-
-type ContainerStatus struct {
-	Name         string `json:"name"`
-	State        State  `json:"state"`
-	LastState    State  `json:"lastState"`
-	Ready        bool   `json:"ready"`
-	RestartCount int32  `json:"restartCount"`
-	Image        string `json:"image"`
-	ImageID      string `json:"imageID"`
-	Started      bool   `json:"started"`
-}
+// ConflictPolicy tells what to do with a remediation changing fields owned by other field managers,
+// e.g. replicas managed by an HPA or fields managed by Argo CD or Helm
+type ConflictPolicy string
 
-type State struct {
-	Waiting *WaitingState `json:"waiting,omitempty"`
-}
-
-type WaitingState struct {
-	Reason  string `json:"reason"`
-	Message string `json:"message"`
-}
-
-type ProbeResult struct {
-	ContainerName string      `json:"containerName"`
-	Liveness      ProbeStatus `json:"liveness"`
-	Readiness     ProbeStatus `json:"readiness"`
-}
+const (
+	// ConflictForce applies the remediation anyway, taking ownership of the conflicting fields
+	ConflictForce ConflictPolicy = "force"
+	// ConflictSkip leaves the resource unchanged
+	ConflictSkip ConflictPolicy = "skip"
+)
 
-type ProbeStatus struct {
-	Status       bool   `json:"status"`
-	SuccessCount int32  `json:"successCount"`
-	FailureCount int32  `json:"failureCount"`
-	Details      string `json:"details"`
+type RemediationGenerator struct {
+	// agent calls the k8s agent API
+	agent *agentclient.Client
+	// onConflict tells what to do with remediations conflicting with other field managers
+	onConflict ConflictPolicy
+	g          *gptscript.GPTScript
 }
 
-func NewRemediationGenerator(apiKey string, agentURL string) (*RemediationGenerator, error) {
-	log.Printf("Initializing RemediationGenerator with agent URL: %s", agentURL)
+func NewRemediationGenerator(apiKey string, agent *agentclient.Client, onConflict ConflictPolicy) (*RemediationGenerator, error) {
+	log.Printf("Initializing RemediationGenerator")
 	g, err := gptscript.NewGPTScript(gptscript.GlobalOptions{
 		OpenAIAPIKey: apiKey,
 	})
@@ -83,15 +58,21 @@ func NewRemediationGenerator(apiKey string, agentURL string) (*RemediationGenera
 	}
 
 	return &RemediationGenerator{
-		agentURL: agentURL,
-		g:        g,
+		agent:      agent,
+		onConflict: onConflict,
+		g:          g,
 	}, nil
 }
 
 func (r *RemediationGenerator) GenerateRemediation(ctx context.Context, result common.Result) (string, error) {
 	log.Printf("Starting remediation generation for resource: Kind=%s, Name=%s", result.Kind, result.Name)
+	kind, namespace, name, err := r.resolveTarget(ctx, result)
+	if err != nil {
+		log.Printf("Unsupported resource: %v", err)
+		return "", err
+	}
 	// Get resource YAML from k8s agent
-	resourceYAML, err := r.getResourceYAML(result)
+	resourceYAML, err := r.getResourceYAML(ctx, kind, namespace, name)
 	if err != nil {
 		log.Printf("Error getting resource YAML: %v", err)
 		return "", fmt.Errorf("failed to get resource YAML: %v", err)
@@ -104,6 +85,36 @@ func (r *RemediationGenerator) GenerateRemediation(ctx context.Context, result c
 		errorMsgs += err.Text + "\n"
 	}
 	log.Printf("Collected error messages:\n%s", errorMsgs)
+
+	// Events often explain failures better than the analysis, e.g. scheduling failures
+	events, err := r.getResourceEvents(ctx, kind, namespace, name)
+	if err != nil {
+		log.Printf("Error getting resource events, continuing without them: %v", err)
+	}
+	if events == "" {
+		events = "No events found\n"
+	}
+	log.Printf("Collected events:\n%s", events)
+
+	// Tell what resources the pods could get when they can't be scheduled, e.g. too large requests
+	feasibility, err := r.describeFeasibility(ctx, resourceYAML)
+	if err != nil {
+		log.Printf("Error checking scheduling feasibility, continuing without it: %v", err)
+	}
+	if feasibility == "" {
+		feasibility = "The pods can be scheduled\n"
+	}
+	log.Printf("Collected scheduling feasibility:\n%s", feasibility)
+
+	// Tell which images don't exist and the closest tags, e.g. for ImagePullBackOff
+	images, err := r.describeImages(ctx, resourceYAML)
+	if err != nil {
+		log.Printf("Error verifying images, continuing without it: %v", err)
+	}
+	if images == "" {
+		images = "The images exist\n"
+	}
+	log.Printf("Collected image verification:\n%s", images)
 	// Create GPTScript tool
 	log.Printf("Creating GPTScript tool for remediation")
 
@@ -115,6 +126,15 @@ Current YAML:
 Issues Detected:
 %s
 
+Recent Events:
+%s
+
+Scheduling Feasibility:
+%s
+
+Image Verification:
+%s
+
 Analysis Solution:
 %s
 
@@ -123,7 +143,7 @@ Please only provide the corrected YAML.
 Format the response as valid Kubernetes YAML.
 
 Do not include any triple backticks and yaml word in the output. Just provide correct YAML`,
-		result.Kind, resourceYAML, errorMsgs, result.Details)
+		kind, resourceYAML, errorMsgs, events, feasibility, images, result.Details)
 
 	// Run GPTScript evaluation
 	log.Printf("Starting GPTScript evaluation")
@@ -132,8 +152,6 @@ Do not include any triple backticks and yaml word in the output. Just provide co
 		Description:  "Generates remediation YAML for Kubernetes resources",
 		Instructions: prompt,
 	}
-	// Run GPTScript evaluation
-	log.Printf("Starting GPTScript evaluation")
 	run, err := r.g.Evaluate(ctx, gptscript.Options{}, tool)
 	if err != nil {
 		log.Printf("Error during GPTScript evaluation: %v", err)
@@ -149,6 +167,12 @@ Do not include any triple backticks and yaml word in the output. Just provide co
 	log.Printf("Successfully generated remediation YAML")
 	log.Printf("Generated remediation YAML:\n%s\n", remediationYAML)
 
+	// Preview the change with a server-side dry-run before touching the cluster
+	if err := r.previewRemediationYAML(ctx, remediationYAML); err != nil {
+		log.Printf("Failed to preview remediation YAML: %v", err)
+		return remediationYAML, fmt.Errorf("failed to preview remediation: %v", err)
+	}
+
 	if err := r.applyRemediationYAML(ctx, remediationYAML); err != nil {
 		log.Printf("Failed to apply remediation YAML: %v", err)
 		return remediationYAML, fmt.Errorf("failed to apply remediation: %v", err)
@@ -158,73 +182,236 @@ Do not include any triple backticks and yaml word in the output. Just provide co
 	return remediationYAML, nil
 }
 
-func (r *RemediationGenerator) applyRemediationYAML(ctx context.Context, yaml string) error {
-	url := fmt.Sprintf("%s/apply", r.agentURL)
+func (r *RemediationGenerator) previewRemediationYAML(ctx context.Context, yaml string) error {
+	// Send request
+	// The preview is never forced so that fields owned by other managers are reported
+	log.Printf("Sending dry-run apply request")
+	applyResps, err := r.agent.Apply(ctx, []byte(yaml), agentclient.ApplyOptions{DryRun: true, Force: &[]bool{false}[0]})
+	if conflicts := agentclient.Conflicts(applyResps); len(conflicts) > 0 && agentclient.IsConflict(err) {
+		for _, conflict := range conflicts {
+			log.Printf("Remediation changes %s managed by %s", conflict.Field, conflict.Manager)
+		}
+		if r.onConflict != ConflictForce {
+			return fmt.Errorf("remediation skipped, it conflicts with other field managers: %v", err)
+		}
+		log.Printf("Forcing remediation despite %d conflict(s)", len(conflicts))
+		// Preview the forced apply instead
+		applyResps, err = r.agent.Apply(ctx, []byte(yaml), agentclient.ApplyOptions{DryRun: true, Force: &[]bool{true}[0]})
+	}
+	if err != nil {
+		return fmt.Errorf("dry-run failed: %v", err)
+	}
+
+	// A remediation leaving the pods unschedulable would only trade one pending pod for another
+	if err := r.checkFeasibility(ctx, yaml); err != nil {
+		return err
+	}
+
+	// Guessed image names and tags are verified before they can cause an ImagePullBackOff
+	if err := r.checkImages(ctx, yaml); err != nil {
+		return err
+	}
 
-	// Create request
-	req, err := http.NewRequestWithContext(ctx, "POST", url, strings.NewReader(yaml))
+	// Log which fields the remediation would change, values are left out as they may hold credentials
+	for _, applyResp := range applyResps {
+		log.Printf("Dry-run for %s %s/%s would change %d field(s):",
+			applyResp.Kind, applyResp.Namespace, applyResp.Name, len(applyResp.Diff))
+		for _, change := range applyResp.Diff {
+			switch change.Op {
+			case "add":
+				log.Printf("  + %s", change.Path)
+			case "remove":
+				log.Printf("  - %s", change.Path)
+			default:
+				log.Printf("  ~ %s", change.Path)
+			}
+		}
+	}
+
+	return nil
+}
+
+// describeFeasibility describes why the pods of the objects of yaml can't be scheduled and the largest requests
+// they could get, empty when they can be scheduled
+func (r *RemediationGenerator) describeFeasibility(ctx context.Context, yaml string) (string, error) {
+	reports, err := r.agent.Feasibility(ctx, []byte(yaml))
 	if err != nil {
-		return fmt.Errorf("failed to create request: %v", err)
+		return "", err
+	}
+	var b strings.Builder
+	for _, report := range reports {
+		if report.Feasible {
+			continue
+		}
+		fmt.Fprintf(&b, "%s %s/%s: %s\n", report.Kind, report.Namespace, report.Name, report.Message)
+		for _, node := range report.Nodes {
+			if len(node.Reasons) > 0 {
+				fmt.Fprintf(&b, "  node %s: %s\n", node.Name, strings.Join(node.Reasons, "; "))
+			}
+		}
+		if len(report.MaxRequests) > 0 {
+			fmt.Fprintf(&b, "  largest requests a pod can get: %s\n", formatResources(report.MaxRequests))
+		}
 	}
+	return b.String(), nil
+}
 
-	// Set headers
-	req.Header.Set("Content-Type", "application/yaml")
+// checkFeasibility fails when the pods of the objects of yaml can't be scheduled. Failures to run the check
+// are only logged, the agent may not be allowed to read the nodes.
+func (r *RemediationGenerator) checkFeasibility(ctx context.Context, yaml string) error {
+	log.Printf("Sending scheduling feasibility request")
+	reports, err := r.agent.Feasibility(ctx, []byte(yaml))
+	if err != nil {
+		log.Printf("Error checking scheduling feasibility, continuing without it: %v", err)
+		return nil
+	}
+	var failures []string
+	for _, report := range reports {
+		log.Printf("Scheduling feasibility of %s %s/%s: feasible=%t requests=%s",
+			report.Kind, report.Namespace, report.Name, report.Feasible, formatResources(report.Requests))
+		if !report.Feasible {
+			failures = append(failures, fmt.Sprintf("%s %s/%s: %s", report.Kind, report.Namespace, report.Name, report.Message))
+		}
+	}
+	if len(failures) > 0 {
+		return fmt.Errorf("remediation pods can't be scheduled: %s", strings.Join(failures, "; "))
+	}
+	return nil
+}
 
-	// Send request
-	log.Printf("Sending apply request to: %s", url)
-	resp, err := http.DefaultClient.Do(req)
+// describeImages describes the images of the objects of yaml that don't exist, with the closest tags,
+// empty when they all exist
+func (r *RemediationGenerator) describeImages(ctx context.Context, yaml string) (string, error) {
+	reports, err := r.agent.VerifyImages(ctx, []byte(yaml))
 	if err != nil {
-		return fmt.Errorf("failed to send request: %v", err)
+		return "", err
 	}
-	defer resp.Body.Close()
+	var b strings.Builder
+	for _, report := range reports {
+		if report.Status == api.ImageFound {
+			continue
+		}
+		fmt.Fprintf(&b, "%s %s/%s container %s image %s (%s): %s\n", report.Kind, report.Namespace, report.Name,
+			report.Container, report.Image, report.Status, report.Message)
+		if len(report.ClosestTags) > 0 {
+			fmt.Fprintf(&b, "  closest existing tags: %s\n", strings.Join(report.ClosestTags, ", "))
+		}
+	}
+	return b.String(), nil
+}
 
-	// Read response
-	body, err := ioutil.ReadAll(resp.Body)
+// checkImages fails when an image of the objects of yaml is invalid or doesn't exist. Images whose registry
+// can't be queried, and failures to run the check, are only logged.
+func (r *RemediationGenerator) checkImages(ctx context.Context, yaml string) error {
+	log.Printf("Sending image verification request")
+	reports, err := r.agent.VerifyImages(ctx, []byte(yaml))
 	if err != nil {
-		return fmt.Errorf("failed to read response: %v", err)
+		log.Printf("Error verifying images, continuing without it: %v", err)
+		return nil
 	}
+	var failures []string
+	for _, report := range reports {
+		log.Printf("Image %s of %s %s/%s container %s: %s %s",
+			report.Image, report.Kind, report.Namespace, report.Name, report.Container, report.Status, report.Message)
+		switch report.Status {
+		case api.ImageNotFound, api.ImageInvalid:
+			failure := fmt.Sprintf("container %s image %s: %s", report.Container, report.Image, report.Message)
+			if len(report.ClosestTags) > 0 {
+				failure += fmt.Sprintf(" (closest tags: %s)", strings.Join(report.ClosestTags, ", "))
+			}
+			failures = append(failures, failure)
+		}
+	}
+	if len(failures) > 0 {
+		return fmt.Errorf("remediation images can't be pulled: %s", strings.Join(failures, "; "))
+	}
+	return nil
+}
 
-	// Check response status
-	if resp.StatusCode != http.StatusOK {
-		return fmt.Errorf("apply failed with status %d: %s", resp.StatusCode, string(body))
+// formatResources formats a resource list sorted by name, e.g. cpu=500m, memory=1Gi
+func formatResources(list corev1.ResourceList) string {
+	names := make([]string, 0, len(list))
+	for name := range list {
+		names = append(names, string(name))
 	}
+	sort.Strings(names)
+	parts := make([]string, 0, len(names))
+	for _, name := range names {
+		quantity := list[corev1.ResourceName(name)]
+		parts = append(parts, name+"="+quantity.String())
+	}
+	return strings.Join(parts, ", ")
+}
 
-	// Parse response
-	var applyResp struct {
-		Kind      string `json:"kind"`
-		Name      string `json:"name"`
-		Namespace string `json:"namespace"`
-		Action    string `json:"action"`
+func (r *RemediationGenerator) applyRemediationYAML(ctx context.Context, yaml string) error {
+	// Apply all documents or none of them
+	log.Printf("Sending apply request")
+	applyResps, err := r.agent.Apply(ctx, []byte(yaml), agentclient.ApplyOptions{
+		Atomic: true,
+		Force:  &[]bool{r.onConflict == ConflictForce}[0],
+	})
+	if err != nil {
+		return fmt.Errorf("apply failed: %v", err)
 	}
 
-	if err := json.Unmarshal(body, &applyResp); err != nil {
-		return fmt.Errorf("failed to parse response: %v", err)
+	for _, applyResp := range applyResps {
+		log.Printf("Apply response: Kind=%s, Name=%s/%s, Action=%s",
+			applyResp.Kind, applyResp.Namespace, applyResp.Name, applyResp.Action)
+
+		// Only workloads have pods to wait for
+		if _, ok := workloadKinds[applyResp.Kind]; !ok {
+			continue
+		}
+
+		// Wait for pod status, undo the remediation if it made things worse
+		if err := r.waitForPodStatus(ctx, applyResp.Namespace, applyResp.Name, applyResp.Kind); err != nil {
+			if applyResp.RevisionID != "" {
+				if rollbackErr := r.rollbackRemediation(ctx, applyResp.RevisionID); rollbackErr != nil {
+					log.Printf("Failed to roll back remediation: %v", rollbackErr)
+				}
+			}
+			return fmt.Errorf("pod status check failed: %v", err)
+		}
 	}
 
-	log.Printf("Apply response: Kind=%s, Name=%s/%s, Action=%s",
-		applyResp.Kind, applyResp.Namespace, applyResp.Name, applyResp.Action)
+	return nil
+}
 
-	// Wait for pod status
-	if err := r.waitForPodStatus(ctx, applyResp.Namespace, applyResp.Name, applyResp.Kind); err != nil {
-		return fmt.Errorf("pod status check failed: %v", err)
+func (r *RemediationGenerator) rollbackRemediation(ctx context.Context, revisionID string) error {
+	// Send request
+	log.Printf("Sending rollback request for revision %s", revisionID)
+	if _, err := r.agent.Rollback(ctx, revisionID); err != nil {
+		return fmt.Errorf("rollback failed: %v", err)
 	}
 
+	log.Printf("Successfully rolled back revision %s", revisionID)
 	return nil
 }
 
 func (r *RemediationGenerator) waitForPodStatus(ctx context.Context, namespace, name, kind string) error {
 	log.Printf("Starting pod status check for %s: %s/%s", kind, namespace, name)
-	// For deployments, we need to get the pod name from the deployment
-	if kind == "Deployment" {
-		return r.waitForDeploymentPods(ctx, namespace, name)
+	// For pods, directly check the pod status
+	if kind == "Pod" {
+		return r.waitForPod(ctx, namespace, name)
 	}
 
-	// For pods, directly check the pod status
-	return r.waitForPod(ctx, namespace, name)
+	// For workloads, wait for the rollout or the job to finish
+	return r.waitForRollout(ctx, kind, namespace, name)
 }
 
-func (r *RemediationGenerator) waitForDeploymentPods(ctx context.Context, namespace, deployName string) error {
-	log.Printf("Checking status of pods for deployment %s/%s", namespace, deployName)
+// checkRollout reports whether a workload finished rolling out, and fails when the rollout failed
+func checkRollout(kind, namespace, name string, done, failed bool, message string) (bool, error) {
+	log.Printf("%s %s/%s: %s", kind, namespace, name, message)
+
+	if failed {
+		return false, fmt.Errorf("%s rollout failed: %s", kind, message)
+	}
+	return done, nil
+}
+
+// pollRollout polls the workload status until the rollout finished
+func (r *RemediationGenerator) pollRollout(ctx context.Context, kind, namespace, name string) error {
+	log.Printf("Checking rollout status of %s %s/%s", kind, namespace, name)
 
 	timeout := time.After(5 * time.Minute)
 	ticker := time.NewTicker(5 * time.Second)
@@ -235,61 +422,83 @@ func (r *RemediationGenerator) waitForDeploymentPods(ctx context.Context, namesp
 		case <-ctx.Done():
 			return fmt.Errorf("context cancelled")
 		case <-timeout:
-			return fmt.Errorf("timeout waiting for deployment pods")
+			return fmt.Errorf("timeout waiting for %s rollout", kind)
 		case <-ticker.C:
-			// Get pod names for deployment
-			url := fmt.Sprintf("%s/deployments/%s/%s/pods", r.agentURL, namespace, deployName)
-			resp, err := http.Get(url)
-			if err != nil {
-				log.Printf("Error getting deployment pods: %v", err)
-				continue
-			}
-
-			var deployPods struct {
-				Name      string   `json:"name"`
-				Namespace string   `json:"namespace"`
-				PodNames  []string `json:"podNames"`
-			}
-
-			body, err := ioutil.ReadAll(resp.Body)
-			resp.Body.Close()
-			if err != nil {
-				log.Printf("Error reading response: %v", err)
-				continue
+			var done, failed bool
+			var message string
+			if kind == "Deployment" {
+				status, err := r.agent.DeploymentStatus(ctx, namespace, name)
+				if agentclient.IsNotFound(err) {
+					return fmt.Errorf("%s %s/%s was deleted", kind, namespace, name)
+				}
+				if err != nil {
+					log.Printf("Error getting %s status: %v", kind, err)
+					continue
+				}
+				done, failed, message = status.Done, status.Failed, status.Message
+			} else {
+				status, err := r.agent.WorkloadStatus(ctx, workloadKinds[kind].Resource, namespace, name)
+				if agentclient.IsNotFound(err) {
+					return fmt.Errorf("%s %s/%s was deleted", kind, namespace, name)
+				}
+				if err != nil {
+					log.Printf("Error getting %s status: %v", kind, err)
+					continue
+				}
+				done, failed, message = status.Done, status.Failed, status.Message
 			}
 
-			if err := json.Unmarshal(body, &deployPods); err != nil {
-				log.Printf("Error parsing deployment pods: %v", err)
-				continue
-			}
-
-			if len(deployPods.PodNames) == 0 {
-				log.Printf("No pods found for deployment %s/%s", namespace, deployName)
-				continue
+			if done, err := checkRollout(kind, namespace, name, done, failed, message); done || err != nil {
+				return err
 			}
+		}
+	}
+}
 
-			// Check each pod's status
-			allPodsReady := true
-			for _, podName := range deployPods.PodNames {
-				if err := r.waitForPod(ctx, namespace, podName); err != nil {
-					// If pod check fails, log and continue checking other pods
-					log.Printf("Pod %s status check failed: %v", podName, err)
-					continue
-				}
-				// If pod is ready, we can return success
-				log.Printf("Pod %s is ready", podName)
-				return nil
-			}
+// checkPod reports whether a pod is running with all containers ready, and fails when the pod failed
+func checkPod(status api.PodStatus) (bool, error) {
+	// Log detailed status
+	log.Printf("Pod %s status:", status.Name)
+	log.Printf("  Phase: %s", status.Phase)
+
+	// Check container statuses
+	for _, container := range status.ContainerStatus {
+		log.Printf("  Container %s:", container.Name)
+		log.Printf("    Ready: %v", container.Ready)
+		if container.State.Waiting != nil {
+			log.Printf("    Waiting: %s - %s",
+				container.State.Waiting.Reason,
+				container.State.Waiting.Message)
+		}
+	}
 
-			if allPodsReady {
-				log.Printf("All pods for deployment %s/%s are ready", namespace, deployName)
-				return nil
+	// If pod is running and all containers are ready, we're done
+	if status.Phase == corev1.PodRunning {
+		allContainersReady := true
+		for _, container := range status.ContainerStatus {
+			if !container.Ready {
+				allContainersReady = false
+				break
 			}
 		}
+		if allContainersReady {
+			log.Printf("Pod %s is ready and running", status.Name)
+			return true, nil
+		}
 	}
+
+	// If pod is in a terminal failed state, return error
+	if status.Phase == corev1.PodFailed {
+		return false, fmt.Errorf("pod failed: %s", status.Phase)
+	}
+
+	// For other states (Pending, ContainerCreating, etc.), continue waiting
+	log.Printf("Pod %s is in %s state, waiting...", status.Name, status.Phase)
+	return false, nil
 }
 
-func (r *RemediationGenerator) waitForPod(ctx context.Context, namespace, podName string) error {
+// pollPod polls the pod status until the pod is ready
+func (r *RemediationGenerator) pollPod(ctx context.Context, namespace, podName string) error {
 	log.Printf("Checking status for pod %s/%s", namespace, podName)
 
 	timeout := time.After(5 * time.Minute)
@@ -303,116 +512,126 @@ func (r *RemediationGenerator) waitForPod(ctx context.Context, namespace, podNam
 		case <-timeout:
 			return fmt.Errorf("timeout waiting for pod")
 		case <-ticker.C:
-			url := fmt.Sprintf("%s/pods/%s/%s/status", r.agentURL, namespace, podName)
-			resp, err := http.Get(url)
-			if err != nil {
-				log.Printf("Error getting pod status: %v", err)
-				continue
+			status, err := r.agent.PodStatus(ctx, namespace, podName)
+			if agentclient.IsNotFound(err) {
+				return fmt.Errorf("pod %s/%s was deleted", namespace, podName)
 			}
-
-			body, err := ioutil.ReadAll(resp.Body)
-			resp.Body.Close()
 			if err != nil {
-				log.Printf("Error reading pod status: %v", err)
+				log.Printf("Error getting pod status: %v", err)
 				continue
 			}
 
-			var status PodStatus
-			if err := json.Unmarshal(body, &status); err != nil {
-				log.Printf("Error parsing pod status: %v", err)
-				continue
-			}
-
-			// Log detailed status
-			log.Printf("Pod %s status:", podName)
-			log.Printf("  Phase: %s", status.Phase)
-
-			// Check container statuses
-			for _, container := range status.ContainerStatus {
-				log.Printf("  Container %s:", container.Name)
-				log.Printf("    Ready: %v", container.Ready)
-				if container.State.Waiting != nil {
-					log.Printf("    Waiting: %s - %s",
-						container.State.Waiting.Reason,
-						container.State.Waiting.Message)
-				}
-			}
-			//log.Printf("Pod %s is still pending, waiting...", podName)
-
-			// If pod is running and all containers are ready, we're done
-			if status.Phase == "Running" {
-				allContainersReady := true
-				for _, container := range status.ContainerStatus {
-					if !container.Ready {
-						allContainersReady = false
-						break
-					}
-				}
-				if allContainersReady {
-					log.Printf("Pod %s is ready and running", podName)
-					return nil
-				}
+			if done, err := checkPod(*status); done || err != nil {
+				return err
 			}
+		}
+	}
+}
 
-			// If pod is in a terminal failed state, return error
-			if status.Phase == "Failed" {
-				return fmt.Errorf("pod failed: %s", status.Phase)
-			}
+// targetResource returns the resource a remediation applies to: the owner of the reported resource
+// when k8sgpt found one (e.g. the StatefulSet of a pod), the reported resource itself otherwise
+func targetResource(result common.Result) (kind, namespace, name string, err error) {
+	// Get namespace from resource name (format: "namespace/name")
+	parts := strings.Split(result.Name, "/")
+	if len(parts) != 2 {
+		return "", "", "", fmt.Errorf("invalid resource name format: %s", result.Name)
+	}
+	kind, namespace, name = result.Kind, parts[0], parts[1]
 
-			// For other states (Pending, ContainerCreating, etc.), continue polling
-			log.Printf("Pod %s is in %s state, waiting...", podName, status.Phase)
+	// Get owner kind and name from ParentObject (format: "Kind/name")
+	if result.ParentObject != "" {
+		parentParts := strings.Split(result.ParentObject, "/")
+		if len(parentParts) != 2 {
+			return "", "", "", fmt.Errorf("invalid parent object format: %s", result.ParentObject)
 		}
+		kind, name = parentParts[0], parentParts[1]
 	}
+
+	if _, ok := workloadKinds[kind]; !ok {
+		return "", "", "", fmt.Errorf("unsupported kind %s for %s", kind, result.Name)
+	}
+	return kind, namespace, name, nil
 }
 
-func (r *RemediationGenerator) getResourceYAML(result common.Result) (string, error) {
-	var url string
-	if result.ParentObject != "" {
-		// It's a deployment issue
-		log.Printf("Processing deployment resource with ParentObject: %s", result.ParentObject)
-		// Get namespace from pod name (format: "namespace/pod-name")
-		parts := strings.Split(result.Name, "/")
-		if len(parts) != 2 {
-			log.Printf("Invalid pod name format: %s", result.Name)
-			return "", fmt.Errorf("invalid pod name format: %s", result.Name)
+// resolveTarget returns the resource a remediation applies to, following the jobs k8sgpt doesn't resolve.
+// The pod template of a job is immutable, so the pods of a job are remediated through the cronjob owning it,
+// and jobs without a cronjob are refused.
+func (r *RemediationGenerator) resolveTarget(ctx context.Context, result common.Result) (kind, namespace, name string, err error) {
+	kind, namespace, name, err = targetResource(result)
+	if err != nil {
+		return "", "", "", err
+	}
+
+	if kind == "Pod" {
+		pods, err := r.agent.ListPods(ctx, agentclient.ListPodsOptions{Namespace: namespace, FieldSelector: "metadata.name=" + name})
+		if err != nil {
+			return "", "", "", fmt.Errorf("failed to get pod %s/%s from agent: %v", namespace, name, err)
+		}
+		if len(pods.Items) == 0 {
+			return "", "", "", fmt.Errorf("pod %s/%s not found", namespace, name)
+		}
+		owner := metav1.GetControllerOf(&pods.Items[0])
+		if owner == nil || owner.Kind != "Job" {
+			return kind, namespace, name, nil
 		}
-		namespace := parts[0]
+		log.Printf("Pod %s/%s is owned by job %s", namespace, name, owner.Name)
+		kind, name = "Job", owner.Name
+	}
 
-		// Get deployment name from ParentObject (format: "Deployment/name")
-		deployParts := strings.Split(result.ParentObject, "/")
-		if len(deployParts) != 2 {
-			log.Printf("Invalid parent object format: %s", result.ParentObject)
-			return "", fmt.Errorf("invalid parent object format: %s", result.ParentObject)
+	if kind == "Job" {
+		status, err := r.agent.WorkloadStatus(ctx, workloadKinds[kind].Resource, namespace, name)
+		if err != nil {
+			return "", "", "", fmt.Errorf("failed to get job %s/%s from agent: %v", namespace, name, err)
+		}
+		ownerKind, ownerName, _ := strings.Cut(status.Owner, "/")
+		if ownerKind != "CronJob" {
+			return "", "", "", fmt.Errorf("job %s/%s has an immutable pod template and no cronjob, it can't be remediated in place", namespace, name)
 		}
-		deployName := deployParts[1]
+		log.Printf("Job %s/%s is owned by cronjob %s", namespace, name, ownerName)
+		kind, name = ownerKind, ownerName
+	}
+	return kind, namespace, name, nil
+}
 
-		url = fmt.Sprintf("%s/deployments/%s/%s/yaml", r.agentURL, namespace, deployName)
-		log.Printf("Fetching deployment YAML from: %s", url)
-	} else {
+func (r *RemediationGenerator) getResourceYAML(ctx context.Context, kind, namespace, name string) (string, error) {
+	var yaml []byte
+	var err error
+	switch kind {
+	case "Pod":
 		// It's a standalone pod
-		log.Printf("Processing standalone pod: %s", result.Name)
-		url = fmt.Sprintf("%s/pods/%s/yaml", r.agentURL, result.Name)
+		log.Printf("Processing standalone pod: %s/%s", namespace, name)
+		yaml, err = r.agent.PodYAML(ctx, namespace, name)
+	case "Deployment":
+		// It's a deployment issue
+		log.Printf("Processing deployment resource: %s/%s", namespace, name)
+		yaml, err = r.agent.DeploymentYAML(ctx, namespace, name)
+	default:
+		// Other workloads are read through the generic resource endpoint
+		log.Printf("Processing %s resource %s/%s", kind, namespace, name)
+		yaml, err = r.agent.ResourceYAML(ctx, workloadKinds[kind], namespace, name)
 	}
-	resp, err := http.Get(url)
 	if err != nil {
 		log.Printf("Error fetching YAML from agent: %v", err)
 		return "", fmt.Errorf("failed to get resource YAML from agent: %v", err)
 	}
-	defer resp.Body.Close()
 
-	if resp.StatusCode != http.StatusOK {
-		log.Printf("Agent returned non-200 status code: %d", resp.StatusCode)
-		return "", fmt.Errorf("agent returned status %d", resp.StatusCode)
-	}
+	log.Printf("Successfully retrieved YAML from agent")
+	return string(yaml), nil
+}
 
-	yaml, err := ioutil.ReadAll(resp.Body)
+// getResourceEvents returns the events of the resource and of the objects it owns, one per line
+func (r *RemediationGenerator) getResourceEvents(ctx context.Context, kind, namespace, name string) (string, error) {
+	log.Printf("Fetching events of %s %s/%s", kind, namespace, name)
+	objectEvents, err := r.agent.Events(ctx, namespace, kind, name)
 	if err != nil {
-		log.Printf("Error reading agent response: %v", err)
-		return "", fmt.Errorf("failed to read agent response: %v", err)
+		return "", fmt.Errorf("failed to get events from agent: %v", err)
 	}
 
-	log.Printf("Successfully retrieved YAML from agent")
-	return string(yaml), nil
+	var events strings.Builder
+	for _, event := range objectEvents.Events {
+		fmt.Fprintf(&events, "%s %s %s (x%d): %s\n", event.Object, event.Type, event.Reason, event.Count, event.Message)
+	}
+	return events.String(), nil
 }
 
 func (r *RemediationGenerator) Close() {
@@ -0,0 +1,89 @@
+package gptscript
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/Sanskarzz/k8sgptclient/k8s-agent/api/agentclient"
+)
+
+// watchTimeout bounds how long a remediated resource is watched before giving up
+const watchTimeout = 5 * time.Minute
+
+// watchResult interprets the end of a watch: the handler error when it failed, a polling fallback
+// when the agent could not serve or keep the stream, nil when the handler was done
+func watchResult(ctx context.Context, err, handleErr error, what string, poll func() error) error {
+	switch {
+	case handleErr != nil:
+		return handleErr
+	case err == nil:
+		return nil
+	case errors.Is(err, context.DeadlineExceeded) && ctx.Err() == nil:
+		return fmt.Errorf("timeout waiting for %s", what)
+	case errors.Is(err, context.Canceled):
+		return fmt.Errorf("context cancelled")
+	}
+	log.Printf("Falling back to polling %s status: %v", what, err)
+	return poll()
+}
+
+// waitForPod waits until the pod is ready, reacting to status changes as soon as the agent streams them
+func (r *RemediationGenerator) waitForPod(ctx context.Context, namespace, podName string) error {
+	log.Printf("Watching status for pod %s/%s", namespace, podName)
+
+	watchCtx, cancel := context.WithTimeout(ctx, watchTimeout)
+	defer cancel()
+
+	var handleErr error
+	err := r.agent.WatchPods(watchCtx, namespace, "", func(event agentclient.PodEvent) (bool, error) {
+		if event.Object.Name != podName {
+			return false, nil
+		}
+		if event.Type == agentclient.Deleted {
+			handleErr = fmt.Errorf("pod %s/%s was deleted", namespace, podName)
+			return false, handleErr
+		}
+		var done bool
+		done, handleErr = checkPod(event.Object)
+		return done, handleErr
+	})
+	return watchResult(ctx, err, handleErr, "pod", func() error {
+		return r.pollPod(ctx, namespace, podName)
+	})
+}
+
+// waitForRollout waits until the workload finished rolling out, reacting to status changes as soon as the agent streams them
+func (r *RemediationGenerator) waitForRollout(ctx context.Context, kind, namespace, name string) error {
+	log.Printf("Watching rollout status of %s %s/%s", kind, namespace, name)
+
+	watchCtx, cancel := context.WithTimeout(ctx, watchTimeout)
+	defer cancel()
+
+	// handle checks every status change, it is shared by deployments and other workloads
+	var handleErr error
+	handle := func(eventType string, done, failed bool, message string) (bool, error) {
+		if eventType == agentclient.Deleted {
+			handleErr = fmt.Errorf("%s %s/%s was deleted", kind, namespace, name)
+			return false, handleErr
+		}
+		done, handleErr = checkRollout(kind, namespace, name, done, failed, message)
+		return done, handleErr
+	}
+
+	var err error
+	if kind == "Deployment" {
+		err = r.agent.WatchDeployment(watchCtx, namespace, name, func(event agentclient.DeploymentEvent) (bool, error) {
+			return handle(event.Type, event.Object.Done, event.Object.Failed, event.Object.Message)
+		})
+	} else {
+		err = r.agent.WatchWorkload(watchCtx, workloadKinds[kind].Resource, namespace, name, func(event agentclient.WorkloadEvent) (bool, error) {
+			return handle(event.Type, event.Object.Done, event.Object.Failed, event.Object.Message)
+		})
+	}
+	return watchResult(ctx, err, handleErr, kind+" rollout", func() error {
+		return r.pollRollout(ctx, kind, namespace, name)
+	})
+}